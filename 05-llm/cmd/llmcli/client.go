@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const maxErrorBody = 4 << 10
+
+// client talks to the llm proxy over its HTTP API.
+type client struct {
+	baseURL *url.URL
+	apiKey  string
+	http    *http.Client
+}
+
+// proxyError is returned when the proxy responds with an error status.
+type proxyError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *proxyError) Error() string {
+	return fmt.Sprintf("proxy responded with %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+type usage struct {
+	PromptTokens     int `json:"promptTokens"`
+	CompletionTokens int `json:"completionTokens"`
+}
+
+// result is the outcome of a one-shot prompt, printed as is with -json.
+type result struct {
+	Response string `json:"response"`
+	Model    string `json:"model"`
+	Template string `json:"template,omitempty"`
+	Version  int    `json:"version,omitempty"`
+	Usage    *usage `json:"usage,omitempty"`
+	// Stopped is set when the generation was interrupted in the REPL.
+	Stopped bool `json:"stopped,omitempty"`
+}
+
+func (c *client) newRequest(ctx context.Context, method, path string, query url.Values, in any) (*http.Request, error) {
+	u := c.baseURL.JoinPath(path)
+	u.RawQuery = query.Encode()
+
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	return req, nil
+}
+
+// do sends the request and converts error statuses into *proxyError.
+func (c *client) do(req *http.Request) (*http.Response, error) {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach proxy: %w", err)
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	perr := &proxyError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(b))}
+	// API errors are JSON, the prompt endpoint replies with plain text.
+	var body struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(b, &body) == nil && body.Message != "" {
+		perr.Message = body.Message
+	}
+	return nil, perr
+}
+
+func (c *client) doJSON(ctx context.Context, method, path string, in, out any) error {
+	req, err := c.newRequest(ctx, method, path, nil, in)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	err = json.NewDecoder(resp.Body).Decode(out)
+	if err != nil {
+		return fmt.Errorf("failed to parse proxy response: %w", err)
+	}
+	return nil
+}
+
+// prompt sends a one-shot prompt. When stream is set the response is
+// copied to w as it is generated.
+func (c *client) prompt(ctx context.Context, w io.Writer, prompt, model string, stream bool) (result, error) {
+	query := url.Values{"q": {prompt}}
+	if model != "" {
+		query.Set("model", model)
+	}
+	if stream {
+		query.Set("stream", strconv.FormatBool(stream))
+	}
+
+	req, err := c.newRequest(ctx, http.MethodGet, "/", query, nil)
+	if err != nil {
+		return result{}, err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return result{}, err
+	}
+	defer resp.Body.Close()
+
+	var content strings.Builder
+	out := io.Writer(&content)
+	if stream {
+		out = io.MultiWriter(&content, w)
+	}
+	_, err = io.Copy(out, resp.Body)
+	res := result{Response: content.String(), Model: resp.Header.Get("X-Model")}
+	if err != nil {
+		// The partial response is returned, it may have been printed already.
+		return res, fmt.Errorf("failed to read proxy response: %w", err)
+	}
+	return res, nil
+}
+
+// ask sends a prompt with a system prompt through a session, which is deleted afterwards.
+func (c *client) ask(ctx context.Context, prompt, model, system string) (result, error) {
+	var sess struct {
+		ID string `json:"id"`
+	}
+	err := c.doJSON(ctx, http.MethodPost, "/sessions", map[string]string{"model": model, "system": system}, &sess)
+	if err != nil {
+		return result{}, err
+	}
+	defer c.doJSON(context.WithoutCancel(ctx), http.MethodDelete, "/sessions/"+sess.ID, nil, nil)
+
+	var out struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		Model string `json:"model"`
+		Usage usage  `json:"usage"`
+	}
+	err = c.doJSON(ctx, http.MethodPost, "/sessions/"+sess.ID+"/messages", map[string]string{"content": prompt}, &out)
+	if err != nil {
+		return result{}, err
+	}
+
+	return result{Response: out.Message.Content, Model: out.Model, Usage: &out.Usage}, nil
+}
+
+// runTemplate renders the named prompt template on the proxy and runs it.
+func (c *client) runTemplate(ctx context.Context, name string, version int, variables map[string]any, model string) (result, error) {
+	in := struct {
+		Variables map[string]any `json:"variables"`
+		Version   int            `json:"version,omitempty"`
+		Model     string         `json:"model,omitempty"`
+	}{variables, version, model}
+
+	var out result
+	err := c.doJSON(ctx, http.MethodPost, "/templates/"+url.PathEscape(name)+"/run", in, &out)
+	return out, err
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/eval"
+)
+
+// evalTarget sends the eval cases to the proxy the same way the one-shot
+// prompts are sent.
+type evalTarget struct {
+	c *client
+}
+
+func (t evalTarget) Generate(ctx context.Context, c eval.Case, model string) (string, error) {
+	var res result
+	var err error
+	switch {
+	case c.Template != "":
+		variables := maps.Clone(c.Variables)
+		if c.Prompt != "" {
+			if variables == nil {
+				variables = map[string]any{}
+			}
+			variables["input"] = c.Prompt
+		}
+		res, err = t.c.runTemplate(ctx, c.Template, c.Version, variables, model)
+	case c.System != "":
+		res, err = t.c.ask(ctx, c.Prompt, model, c.System)
+	default:
+		res, err = t.c.prompt(ctx, io.Discard, c.Prompt, model, false)
+	}
+	return res.Response, err
+}
+
+// runEval runs the eval subcommand. The exit code is non-zero when any case
+// fails, so that it can gate CI.
+func runEval(args []string, stdout, stderr io.Writer) int {
+	var proxyURL, key, models, junit string
+	var timeout time.Duration
+	flags := flag.NewFlagSet("llmcli eval", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: llmcli eval [flags] suite.yaml")
+		flags.PrintDefaults()
+	}
+	proxyFlags(flags, &proxyURL, &key)
+	flags.StringVar(&models, "models", "", "comma-separated models to evaluate instead of the suite's")
+	flags.StringVar(&junit, "junit", "", "write a JUnit XML report to the file")
+	flags.DurationVar(&timeout, "timeout", 5*time.Minute, "timeout of a single case")
+
+	err := flags.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	c, err := newClient(proxyURL, key)
+	if err != nil {
+		fmt.Fprintf(stderr, "llmcli: %v\n", err)
+		return exitUsage
+	}
+	suite, err := eval.Load(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "llmcli: %v\n", err)
+		return exitUsage
+	}
+
+	var modelList []string
+	for _, m := range strings.Split(models, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			modelList = append(modelList, m)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	report := eval.Run(ctx, suite, evalTarget{c}, modelList, timeout)
+
+	err = report.WriteTable(stdout)
+	if err == nil && junit != "" {
+		err = writeJUnit(junit, report)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "llmcli: %v\n", err)
+		return exitError
+	}
+	if !report.Passed() {
+		return exitError
+	}
+	return exitOK
+}
+
+func writeJUnit(path string, report eval.Report) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to write JUnit report: %w", err)
+	}
+	err = report.WriteJUnit(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write JUnit report: %w", err)
+	}
+	return nil
+}
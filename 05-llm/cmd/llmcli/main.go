@@ -0,0 +1,212 @@
+// Command llmcli talks to the llm proxy from the terminal.
+//
+// With a prompt given as arguments, with -file or on standard input it sends
+// a one-shot prompt and prints the response as it is generated. Without a
+// prompt on a terminal it starts an interactive chat.
+//
+//	llmcli [flags] [prompt]
+//
+// The eval subcommand runs a suite of prompts and reports the pass rates
+// per model, see the eval package for the suite format.
+//
+//	llmcli eval [flags] suite.yaml
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+const (
+	defaultURL = "http://localhost:8080"
+
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+type options struct {
+	url       string
+	key       string
+	model     string
+	system    string
+	template  string
+	version   int
+	variables map[string]any
+	file      string
+	json      bool
+	noStream  bool
+}
+
+// run executes the command and returns its exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 0 && args[0] == "eval" {
+		return runEval(args[1:], stdout, stderr)
+	}
+
+	opts := options{variables: map[string]any{}}
+	flags := flag.NewFlagSet("llmcli", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: llmcli [flags] [prompt]")
+		flags.PrintDefaults()
+	}
+	proxyFlags(flags, &opts.url, &opts.key)
+	flags.StringVar(&opts.model, "model", "", "model to use instead of the proxy's default")
+	flags.StringVar(&opts.system, "system", "", "system prompt")
+	flags.StringVar(&opts.template, "template", "", "run the named prompt template, the prompt is passed as the input variable")
+	flags.IntVar(&opts.version, "version", 0, "template version, the latest by default")
+	flags.Func("var", "template variable as key=value, may be repeated", func(s string) error {
+		key, value, ok := strings.Cut(s, "=")
+		if !ok || key == "" {
+			return errors.New("expected key=value")
+		}
+		opts.variables[key] = value
+		return nil
+	})
+	flags.StringVar(&opts.file, "file", "", "read the prompt from the file, - for standard input")
+	flags.BoolVar(&opts.json, "json", false, "print the result as JSON")
+	flags.BoolVar(&opts.noStream, "no-stream", false, "print the response once it is complete")
+
+	err := flags.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		return exitUsage
+	}
+
+	c, err := newClient(opts.url, opts.key)
+	if err != nil {
+		fmt.Fprintf(stderr, "llmcli: %v\n", err)
+		return exitUsage
+	}
+
+	prompt, err := readPrompt(flags.Args(), opts.file, stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "llmcli: %v\n", err)
+		return exitUsage
+	}
+
+	if prompt == "" && opts.template == "" {
+		if !isTerminal(stdin) {
+			fmt.Fprintln(stderr, "llmcli: prompt is empty")
+			return exitUsage
+		}
+		err = c.repl(context.Background(), stdin, stdout, stderr, opts.model, opts.system, opts.json)
+	} else {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		err = oneShot(ctx, c, stdout, prompt, opts)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "llmcli: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// oneShot sends a single prompt and prints the result.
+func oneShot(ctx context.Context, c *client, stdout io.Writer, prompt string, opts options) error {
+	var res result
+	var err error
+	streamed := false
+	switch {
+	case opts.template != "":
+		if prompt != "" {
+			opts.variables["input"] = prompt
+		}
+		res, err = c.runTemplate(ctx, opts.template, opts.version, opts.variables, opts.model)
+	case opts.system != "":
+		res, err = c.ask(ctx, prompt, opts.model, opts.system)
+	default:
+		streamed = !opts.json && !opts.noStream
+		res, err = c.prompt(ctx, stdout, prompt, opts.model, streamed)
+	}
+	if err != nil {
+		if streamed && res.Response != "" {
+			fmt.Fprintln(stdout)
+		}
+		return err
+	}
+
+	if opts.json {
+		return json.NewEncoder(stdout).Encode(res)
+	}
+	if !streamed {
+		fmt.Fprint(stdout, res.Response)
+	}
+	if !strings.HasSuffix(res.Response, "\n") {
+		fmt.Fprintln(stdout)
+	}
+	return nil
+}
+
+// readPrompt joins the prompt arguments with the contents of the file.
+// Standard input is read when it is not a terminal and there are no arguments.
+func readPrompt(args []string, file string, stdin io.Reader) (string, error) {
+	parts := []string{strings.Join(args, " ")}
+
+	if file == "" && len(args) == 0 && !isTerminal(stdin) {
+		file = "-"
+	}
+	var b []byte
+	var err error
+	switch file {
+	case "":
+	case "-":
+		b, err = io.ReadAll(stdin)
+	default:
+		b, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read prompt: %w", err)
+	}
+	parts = append(parts, string(b))
+
+	return strings.TrimSpace(strings.Join(parts, "\n\n")), nil
+}
+
+// isTerminal reports whether r is an interactive terminal.
+func isTerminal(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// proxyFlags defines the flags selecting the proxy.
+func proxyFlags(flags *flag.FlagSet, proxyURL, key *string) {
+	flags.StringVar(proxyURL, "url", envOr("LLM_PROXY_URL", defaultURL), "proxy URL")
+	flags.StringVar(key, "key", os.Getenv("LLM_API_KEY"), "API key")
+}
+
+func newClient(proxyURL, key string) (*client, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid proxy URL %q", proxyURL)
+	}
+	return &client{baseURL: u, apiKey: key, http: http.DefaultClient}, nil
+}
+
+func envOr(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
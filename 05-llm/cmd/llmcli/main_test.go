@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/api"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/models"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/templates"
+)
+
+// failMidway is the prompt whose streamed reply fails after the first chunk.
+const failMidway = "fail midway"
+
+type midStreamFailure struct {
+	*backend.Fake
+}
+
+func (b midStreamFailure) Stream(ctx context.Context, req backend.ChatRequest, fn func(backend.Chunk) error) (backend.Response, error) {
+	if req.Messages[len(req.Messages)-1].Content != failMidway {
+		return b.Fake.Stream(ctx, req, fn)
+	}
+	if err := fn(backend.Chunk{Content: "partial "}); err != nil {
+		return backend.Response{}, err
+	}
+	return backend.Response{}, errors.New("upstream connection reset")
+}
+
+func newTestProxy(t *testing.T) string {
+	t.Helper()
+
+	router, err := models.NewRouter(models.Config{
+		Default: "llama3.2",
+		Allowed: []string{"llama3.2", "llama3.2:1b"},
+		Aliases: map[string]string{"fast": "llama3.2:1b"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lib, err := templates.Load(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lib.Add("summary", "Summarize in {{.words}} words: {{.input}}"); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(api.New(
+		api.WithRouter(router),
+		api.WithBackend(midStreamFailure{backend.NewFake()}),
+		api.WithTemplates(lib),
+	))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestRun(t *testing.T) {
+	proxyURL := newTestProxy(t)
+	promptFile := filepath.Join(t.TempDir(), "prompt.txt")
+	if err := os.WriteFile(promptFile, []byte("from file\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		args           []string
+		stdin          string
+		expectedCode   int
+		expectedStdout string
+		expectedStderr string
+	}{
+		{
+			name:           "Prompt arguments",
+			args:           []string{"hello", "world"},
+			expectedStdout: "echo: hello world\n",
+		},
+		{
+			name:           "Prompt from stdin",
+			stdin:          "piped prompt\n",
+			expectedStdout: "echo: piped prompt\n",
+		},
+		{
+			name:           "Prompt from file",
+			args:           []string{"-file", promptFile, "-no-stream"},
+			expectedStdout: "echo: from file\n",
+		},
+		{
+			name:           "JSON output",
+			args:           []string{"-json", "-model", "fast", "hi"},
+			expectedStdout: `{"response":"echo: hi","model":"llama3.2:1b"}` + "\n",
+		},
+		{
+			name:           "System prompt",
+			args:           []string{"-json", "-system", "Be brief.", "hi"},
+			expectedStdout: `{"response":"echo: hi","model":"llama3.2","usage":{"promptTokens":3,"completionTokens":2}}` + "\n",
+		},
+		{
+			name:           "Template",
+			args:           []string{"-template", "summary", "-var", "words=five", "some", "text"},
+			expectedStdout: "echo: Summarize in five words: some text\n",
+		},
+		{
+			name:           "Model not allowed",
+			args:           []string{"-model", "gpt-4", "hi"},
+			expectedCode:   exitError,
+			expectedStderr: "llmcli: proxy responded with 400 Bad Request:",
+		},
+		{
+			name:           "Stream failing midway",
+			args:           []string{failMidway},
+			expectedCode:   exitError,
+			expectedStdout: "partial \n",
+			expectedStderr: "llmcli: failed to read proxy response: unexpected EOF",
+		},
+		{
+			name:           "Unknown template",
+			args:           []string{"-template", "missing", "hi"},
+			expectedCode:   exitError,
+			expectedStderr: "llmcli: proxy responded with 404 Not Found:",
+		},
+		{
+			name:           "Empty prompt",
+			expectedCode:   exitUsage,
+			expectedStderr: "llmcli: prompt is empty",
+		},
+		{
+			name:           "Invalid variable",
+			args:           []string{"-template", "summary", "-var", "words"},
+			expectedCode:   exitUsage,
+			expectedStderr: "expected key=value",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			args := append([]string{"-url", proxyURL}, tt.args...)
+			code := run(args, strings.NewReader(tt.stdin), &stdout, &stderr)
+
+			if code != tt.expectedCode {
+				t.Errorf("exit code %d, want %d (stderr %q)", code, tt.expectedCode, stderr.String())
+			}
+			if stdout.String() != tt.expectedStdout {
+				t.Errorf("stdout %q, want %q", stdout.String(), tt.expectedStdout)
+			}
+			if !strings.Contains(stderr.String(), tt.expectedStderr) {
+				t.Errorf("stderr %q does not contain %q", stderr.String(), tt.expectedStderr)
+			}
+		})
+	}
+}
+
+func TestRunUnreachableProxy(t *testing.T) {
+	srv := httptest.NewServer(nil)
+	srv.Close()
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-url", srv.URL, "hi"}, strings.NewReader(""), &stdout, &stderr)
+	if code != exitError || !strings.HasPrefix(stderr.String(), "llmcli: failed to reach proxy") {
+		t.Errorf("unexpected exit code %d with %q", code, stderr.String())
+	}
+}
+
+func TestREPL(t *testing.T) {
+	u, _ := url.Parse(newTestProxy(t))
+	c := &client{baseURL: u}
+
+	var out bytes.Buffer
+	in := strings.NewReader("first\n\nsecond message\n/exit\nignored\n")
+	err := c.repl(context.Background(), in, &out, io.Discard, "fast", "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"response":"echo: first","model":"llama3.2:1b","usage":{"promptTokens":1,"completionTokens":2}}
+{"response":"echo: second message","model":"llama3.2:1b","usage":{"promptTokens":5,"completionTokens":3}}
+`
+	if out.String() != expected {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
+
+func TestREPLErrors(t *testing.T) {
+	// The proxy answers every message with the next script of frames.
+	scripts := [][]frame{
+		{{Type: "error", Error: "a reply is already being generated", Code: 400}},
+		{{Type: "token", Content: "echo:"}, {Type: "error", Error: "error requesting llm server", Code: 502}},
+		{{Type: "done", Content: "never sent"}},
+	}
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for _, script := range scripts {
+			var msg frame
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			for _, f := range script {
+				conn.WriteJSON(f)
+			}
+		}
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	c := &client{baseURL: u}
+	var out, errOut bytes.Buffer
+	err := c.repl(context.Background(), strings.NewReader("first\nsecond\nthird\n"), &out, &errOut, "", "", false)
+
+	var proxyErr *proxyError
+	if !errors.As(err, &proxyErr) || proxyErr.StatusCode != 502 {
+		t.Errorf("expected the reply failing midway to end the chat with 502, got %v", err)
+	}
+	if !strings.Contains(errOut.String(), "400 Bad Request: a reply is already being generated") {
+		t.Errorf("failed message was not reported, got %q", errOut.String())
+	}
+	if strings.Contains(out.String(), "never sent") {
+		t.Errorf("chat went on after the failed reply: %q", out.String())
+	}
+}
+
+func TestRunEval(t *testing.T) {
+	proxyURL := newTestProxy(t)
+	dir := t.TempDir()
+
+	passing := `
+name: echo
+models: [llama3.2, fast]
+cases:
+  - name: prompt
+    prompt: answer 42
+    checks:
+      - {type: exact, value: "echo: answer 42"}
+      - {type: number, value: 42}
+  - name: system
+    system: Be brief.
+    prompt: hi
+    checks:
+      - {type: contains, keywords: [HI], ignore_case: true}
+  - name: template
+    template: summary
+    prompt: some text
+    variables: {words: five}
+    checks:
+      - {type: regex, pattern: "five words: some text$"}
+`
+	failing := `
+cases:
+  - name: prompt
+    prompt: hi
+    checks:
+      - {type: exact, value: hello}
+  - name: template
+    template: missing
+    checks:
+      - {type: exact, value: hello}
+`
+
+	tests := []struct {
+		name           string
+		suite          string
+		args           []string
+		expectedCode   int
+		expectedStdout []string
+		expectedJUnit  string
+	}{
+		{
+			name:           "Passing suite",
+			suite:          passing,
+			expectedStdout: []string{"llama3.2  3       0       0       100.0%", "fast      3       0       0       100.0%"},
+		},
+		{
+			name:           "Models override",
+			suite:          passing,
+			args:           []string{"-models", "llama3.2:1b"},
+			expectedStdout: []string{"llama3.2:1b  3       0       0       100.0%"},
+		},
+		{
+			name:         "Failing suite",
+			suite:        failing,
+			args:         []string{"-junit", filepath.Join(dir, "junit.xml")},
+			expectedCode: exitError,
+			expectedStdout: []string{
+				`FAIL  default/prompt: exact: expected "hello", got "echo: hi"`,
+				"ERROR default/template: proxy responded with 404 Not Found",
+				"default  0       1       1       0.0%",
+			},
+			expectedJUnit: `<testsuites tests="2" failures="1" errors="1"`,
+		},
+		{
+			name:         "Invalid suite",
+			suite:        "cases: []",
+			expectedCode: exitUsage,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, fmt.Sprintf("suite-%d.yaml", i))
+			if err := os.WriteFile(path, []byte(tt.suite), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			var stdout, stderr bytes.Buffer
+			args := append(append([]string{"eval", "-url", proxyURL}, tt.args...), path)
+			code := run(args, strings.NewReader(""), &stdout, &stderr)
+
+			if code != tt.expectedCode {
+				t.Errorf("exit code %d, want %d (stderr %q)", code, tt.expectedCode, stderr.String())
+			}
+			for _, line := range tt.expectedStdout {
+				if !strings.Contains(stdout.String(), line) {
+					t.Errorf("stdout does not contain %q:\n%s", line, stdout.String())
+				}
+			}
+			if tt.expectedJUnit != "" {
+				b, err := os.ReadFile(filepath.Join(dir, "junit.xml"))
+				if err != nil || !strings.Contains(string(b), tt.expectedJUnit) {
+					t.Errorf("unexpected JUnit report %s: %v", b, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// frame is a message of the proxy's /ws protocol.
+type frame struct {
+	Type    string `json:"type"`
+	Content string `json:"content,omitempty"`
+	Model   string `json:"model,omitempty"`
+	Usage   *usage `json:"usage,omitempty"`
+	Stopped bool   `json:"stopped,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Code    int    `json:"code,omitempty"`
+}
+
+// repl runs an interactive chat over the proxy's WebSocket endpoint. The
+// proxy keeps the conversation history for the lifetime of the connection.
+// Ctrl-C stops the reply being generated; Ctrl-D or /exit quits. A message
+// failing before its reply starts is reported to errOut and the conversation
+// goes on, while a reply failing midway ends it with the error.
+func (c *client) repl(ctx context.Context, in io.Reader, out, errOut io.Writer, model, system string, jsonOutput bool) error {
+	u := *c.baseURL
+	u.Scheme = strings.Replace(u.Scheme, "http", "ws", 1)
+	u = *u.JoinPath("/ws")
+	query := url.Values{}
+	if model != "" {
+		query.Set("model", model)
+	}
+	if system != "" {
+		query.Set("system", system)
+	}
+	u.RawQuery = query.Encode()
+
+	header := http.Header{}
+	if c.apiKey != "" {
+		header.Set("X-API-Key", c.apiKey)
+	}
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, u.String(), header)
+	if err != nil {
+		if resp != nil {
+			return &proxyError{StatusCode: resp.StatusCode, Message: "failed to open chat connection"}
+		}
+		return fmt.Errorf("failed to reach proxy: %w", err)
+	}
+	defer conn.Close()
+
+	// Frames are read in the background so that the proxy's pings are
+	// answered while we wait for the user to type.
+	frames := make(chan frame)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(frames)
+		for {
+			var f frame
+			err := conn.ReadJSON(&f)
+			if err != nil {
+				readErr <- err
+				return
+			}
+			frames <- f
+		}
+	}()
+
+	var writeMu sync.Mutex
+	send := func(f frame) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return conn.WriteJSON(f)
+	}
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	lines := make(chan string)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	if !jsonOutput {
+		fmt.Fprintln(out, "Type a message, /exit or Ctrl-D to quit, Ctrl-C to stop a reply.")
+	}
+	for {
+		if !jsonOutput {
+			fmt.Fprint(out, "> ")
+		}
+
+		var line string
+		select {
+		case l, ok := <-lines:
+			if !ok {
+				return nil
+			}
+			line = strings.TrimSpace(l)
+		case <-interrupts:
+			fmt.Fprintln(out)
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if line == "" {
+			continue
+		}
+		if line == "/exit" || line == "/quit" {
+			return nil
+		}
+
+		err := send(frame{Type: "message", Content: line})
+		if err != nil {
+			return fmt.Errorf("failed to send message: %w", err)
+		}
+
+		// Print the reply until it is done.
+		streamed := false
+		for complete := false; !complete; {
+			select {
+			case f, ok := <-frames:
+				if !ok {
+					return fmt.Errorf("chat connection closed: %w", <-readErr)
+				}
+				complete, err = printFrame(out, f, jsonOutput)
+				var proxyErr *proxyError
+				if errors.As(err, &proxyErr) && !streamed {
+					fmt.Fprintf(errOut, "llmcli: %v\n", err)
+					err = nil
+				}
+				if err != nil {
+					return err
+				}
+				streamed = streamed || f.Type == "token"
+			case <-interrupts:
+				send(frame{Type: "stop"})
+			}
+		}
+	}
+}
+
+// printFrame writes the frame to out and reports whether the reply is
+// complete. An error frame completes the reply with a *proxyError.
+func printFrame(out io.Writer, f frame, jsonOutput bool) (bool, error) {
+	switch f.Type {
+	case "token":
+		if !jsonOutput {
+			fmt.Fprint(out, f.Content)
+		}
+		return false, nil
+	case "done":
+		if jsonOutput {
+			return true, json.NewEncoder(out).Encode(result{Response: f.Content, Model: f.Model, Usage: f.Usage, Stopped: f.Stopped})
+		}
+		if f.Stopped {
+			fmt.Fprint(out, " [stopped]")
+		}
+		fmt.Fprintln(out)
+		return true, nil
+	case "error":
+		fmt.Fprintln(out)
+		return true, &proxyError{StatusCode: f.Code, Message: f.Error}
+	default:
+		return false, errors.New("unexpected message from proxy: " + f.Type)
+	}
+}
@@ -0,0 +1,398 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/audit"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/auth"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/batch"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/cache"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/errs"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/guard"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/models"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/queue"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/rag"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/session"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/structured"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/templates"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/tools"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/vision"
+)
+
+const (
+	maxBodySize = 1 << 20
+
+	// PriorityHeader selects the queue lane: "interactive" (default) or "batch".
+	PriorityHeader = "X-Priority"
+	// QueueTimeoutHeader shortens the time the request may wait for a free backend slot.
+	QueueTimeoutHeader = "X-Queue-Timeout"
+	// RequestIDHeader identifies the request in the audit log. A missing ID is generated.
+	RequestIDHeader = "X-Request-ID"
+
+	maxRequestIDLength = 128
+)
+
+// ModelHeader is used by clients to choose a model and by the proxy
+// to report the model which served the request.
+const ModelHeader = "X-Model"
+
+type API struct {
+	auth       *auth.Manager
+	router     *models.Router
+	backend    backend.Backend
+	sessions   *session.Store
+	window     session.Window
+	cache      *cache.Cache
+	embedModel string
+	retriever  *rag.Retriever
+	structured *structured.Generator
+	retries    int
+	tools      *tools.Runner
+	templates  *templates.Library
+	batches    *batch.Manager
+	vision     *vision.Config
+	guarded    bool
+	timeout    time.Duration
+	maxTimeout time.Duration
+	conns      *Connections
+	mux        *http.ServeMux
+	probes     *http.ServeMux
+}
+
+type ErrorResponse struct {
+	StatusCode int    `json:"statusCode"`
+	Message    string `json:"message"`
+	Details    any    `json:"details,omitempty"`
+}
+
+func New(options ...func(*API)) *API {
+	api := &API{
+		mux:      http.NewServeMux(),
+		probes:   http.NewServeMux(),
+		conns:    NewConnections(),
+		sessions: session.NewStore(0),
+		retries:  defaultStructuredRetries,
+	}
+	for _, option := range options {
+		option(api)
+	}
+	api.structured = structured.NewGenerator(api.backend, api.retries)
+	api.registerEndpoints()
+	api.registerProbes()
+	return api
+}
+
+func (api *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if probe, pattern := api.probes.Handler(r); pattern != "" {
+		probe.ServeHTTP(w, r)
+		return
+	}
+
+	requestID := r.Header.Get(RequestIDHeader)
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		requestID = rand.Text()
+	}
+	w.Header().Set(RequestIDHeader, requestID)
+	r = r.WithContext(audit.WithRequestID(r.Context(), requestID))
+
+	r, cancel, err := api.withDeadline(r)
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	defer cancel()
+
+	priority := r.Header.Get(PriorityHeader)
+	timeout := r.Header.Get(QueueTimeoutHeader)
+	if priority != "" || timeout != "" {
+		var d time.Duration
+		if timeout != "" {
+			var err error
+			d, err = time.ParseDuration(timeout)
+			if err == nil && d < 0 {
+				err = fmt.Errorf("timeout must not be negative")
+			}
+			if err != nil {
+				api.WriteError(w, r, errs.NewErrBadRequest(fmt.Sprintf("invalid %s: %v", QueueTimeoutHeader, err)))
+				return
+			}
+		}
+		r = r.WithContext(queue.WithPriority(r.Context(), queue.ParsePriority(priority), d))
+	}
+
+	if api.auth != nil {
+		var ok bool
+		r, ok = api.authenticate(w, r)
+		if !ok {
+			return
+		}
+	}
+
+	if api.guarded {
+		ctx, report := guard.WithReport(r.Context())
+		r = r.WithContext(ctx)
+		w = &guardWriter{ResponseWriter: w, report: report}
+		defer logFindings(r, report)
+	}
+
+	api.mux.ServeHTTP(w, r)
+}
+
+func (api *API) WriteJSON(w http.ResponseWriter, r *http.Request, response any) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+func (api *API) WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	resp := ErrorResponse{StatusCode: statusCode(err), Message: err.Error()}
+	w.Header().Set("Content-Type", "application/json")
+
+	log.Printf("Error handling %s %s: %v", r.Method, r.URL.Path, err)
+	switch e := err.(type) {
+	case errs.ErrUnavailable:
+		setRetryAfter(w, e.RetryAfter())
+	case errs.ErrTooManyRequests:
+		setRetryAfter(w, e.RetryAfter())
+	case errs.ErrUnprocessable:
+		resp.Details = e.Details()
+	}
+	if resp.StatusCode == http.StatusInternalServerError {
+		resp.Message = "Internal Server Error"
+	}
+
+	w.WriteHeader(resp.StatusCode)
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// StatusClientClosedRequest is the non-standard status, introduced by nginx,
+// of requests the client cancelled. It keeps client disconnects apart from
+// the deadlines reported with 504.
+const StatusClientClosedRequest = 499
+
+// statusCode returns the HTTP status of an API error.
+func statusCode(err error) int {
+	switch err.(type) {
+	case errs.ErrBadRequest:
+		return http.StatusBadRequest
+	case errs.ErrNotFound:
+		return http.StatusNotFound
+	case errs.ErrBadGateway:
+		return http.StatusBadGateway
+	case errs.ErrUnavailable:
+		return http.StatusServiceUnavailable
+	case errs.ErrUnauthorized:
+		return http.StatusUnauthorized
+	case errs.ErrTooManyRequests:
+		return http.StatusTooManyRequests
+	case errs.ErrGatewayTimeout:
+		return http.StatusGatewayTimeout
+	case errs.ErrClientClosed:
+		return StatusClientClosedRequest
+	case errs.ErrUnprocessable:
+		return http.StatusUnprocessableEntity
+	case errs.ErrTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case errs.ErrUnsupportedMediaType:
+		return http.StatusUnsupportedMediaType
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (api *API) registerEndpoints() {
+	api.mux.HandleFunc("/", api.promptHandler)
+	api.mux.Handle("GET /debug/vars", expvar.Handler())
+
+	api.mux.HandleFunc("POST /sessions", api.createSessionHandler)
+	api.mux.HandleFunc("GET /sessions/{id}", api.getSessionHandler)
+	api.mux.HandleFunc("DELETE /sessions/{id}", api.deleteSessionHandler)
+	api.mux.HandleFunc("POST /sessions/{id}/messages", api.sessionMessageHandler)
+
+	api.mux.HandleFunc("GET /ws", api.wsHandler)
+
+	api.mux.HandleFunc("POST /structured", api.structuredHandler)
+	if api.tools != nil {
+		api.mux.HandleFunc("GET /tools", api.listToolsHandler)
+		api.mux.HandleFunc("POST /tools/chat", api.toolChatHandler)
+	}
+	if api.templates != nil {
+		api.mux.HandleFunc("GET /templates", api.listTemplatesHandler)
+		api.mux.HandleFunc("GET /templates/{name}", api.getTemplateHandler)
+		api.mux.HandleFunc("PUT /templates/{name}", api.putTemplateHandler)
+		api.mux.HandleFunc("POST /templates/{name}/rollback", api.rollbackTemplateHandler)
+		api.mux.HandleFunc("POST /templates/{name}/run", api.runTemplateHandler)
+	}
+	if api.batches != nil {
+		api.mux.HandleFunc("POST /batch", api.createBatchHandler)
+		api.mux.HandleFunc("GET /batch/{id}", api.getBatchHandler)
+		api.mux.HandleFunc("GET /batch/{id}/results", api.batchResultsHandler)
+	}
+	if api.vision != nil {
+		api.mux.HandleFunc("POST /vision", api.visionHandler)
+	}
+	api.mux.HandleFunc("POST /embed", api.embedHandler)
+	if api.retriever != nil {
+		api.mux.HandleFunc("POST /documents", api.createDocumentHandler)
+		api.mux.HandleFunc("GET /documents", api.listDocumentsHandler)
+		api.mux.HandleFunc("DELETE /documents/{id}", api.deleteDocumentHandler)
+		api.mux.HandleFunc("POST /rag", api.ragHandler)
+	}
+}
+
+// backendError converts an error returned by the llm backend into an API error.
+func backendError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return errs.NewErrGatewayTimeout("request deadline exceeded while waiting for llm server")
+	}
+	if errors.Is(err, context.Canceled) {
+		return errs.NewErrClientClosed("request cancelled by the client")
+	}
+	var blocked *guard.BlockedError
+	if errors.As(err, &blocked) {
+		return errs.NewErrUnprocessable(blocked.Error(), blocked.Finding)
+	}
+	var overload queue.OverloadError
+	if errors.As(err, &overload) {
+		return errs.NewErrUnavailable(overload.Error(), overload.RetryAfter)
+	}
+	if errors.Is(err, backend.ErrNoUpstream) || errors.Is(err, backend.ErrModelUnavailable) {
+		return errs.NewErrUnavailable(err.Error(), 10*time.Second)
+	}
+	return errs.NewErrBadGateway(fmt.Sprintf("error requesting llm server: %v", err))
+}
+
+func setRetryAfter(w http.ResponseWriter, d time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(d.Seconds()))))
+}
+
+// decodeJSON parses the request body into v. An empty body leaves v untouched.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return errs.NewErrBadRequest(fmt.Sprintf("invalid request body: %v", err))
+	}
+	return nil
+}
+
+func WithRouter(router *models.Router) func(*API) {
+	return func(api *API) {
+		api.router = router
+	}
+}
+
+func WithBackend(b backend.Backend) func(*API) {
+	return func(api *API) {
+		api.backend = b
+	}
+}
+
+func WithSessions(store *session.Store, window session.Window) func(*API) {
+	return func(api *API) {
+		api.sessions = store
+		api.window = window
+	}
+}
+
+func WithCache(c *cache.Cache) func(*API) {
+	return func(api *API) {
+		api.cache = c
+	}
+}
+
+// WithAuth requires clients to present an API key known to manager.
+func WithAuth(manager *auth.Manager) func(*API) {
+	return func(api *API) {
+		api.auth = manager
+	}
+}
+
+func WithEmbedModel(model string) func(*API) {
+	return func(api *API) {
+		api.embedModel = model
+	}
+}
+
+func WithRetriever(retriever *rag.Retriever) func(*API) {
+	return func(api *API) {
+		api.retriever = retriever
+	}
+}
+
+// WithStructuredRetries limits how many times invalid structured output is sent back for repair.
+func WithStructuredRetries(n int) func(*API) {
+	return func(api *API) {
+		api.retries = n
+	}
+}
+
+// WithTools enables the tool calling endpoints.
+func WithTools(runner *tools.Runner) func(*API) {
+	return func(api *API) {
+		api.tools = runner
+	}
+}
+
+// WithTemplates enables the prompt template endpoints.
+func WithTemplates(lib *templates.Library) func(*API) {
+	return func(api *API) {
+		api.templates = lib
+	}
+}
+
+// WithBatches enables the batch job endpoints.
+func WithBatches(m *batch.Manager) func(*API) {
+	return func(api *API) {
+		api.batches = m
+	}
+}
+
+// WithVision enables the image prompt endpoint.
+func WithVision(cfg vision.Config) func(*API) {
+	return func(api *API) {
+		api.vision = &cfg
+	}
+}
+
+// WithGuardrails reports guardrail findings of the backend wrapped with guard.Backend.
+func WithGuardrails() func(*API) {
+	return func(api *API) {
+		api.guarded = true
+	}
+}
+
+// WithConnections tracks the WebSocket connections in conns, which may be
+// shared by the APIs serving before and after a reload.
+func WithConnections(conns *Connections) func(*API) {
+	return func(api *API) {
+		api.conns = conns
+	}
+}
+
+// WithRequestTimeout sets the default request deadline and the maximum
+// a client may ask for with RequestTimeoutHeader. Zero means no limit.
+func WithRequestTimeout(timeout, maxTimeout time.Duration) func(*API) {
+	return func(api *API) {
+		api.timeout = timeout
+		api.maxTimeout = maxTimeout
+	}
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/auth"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/cache"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/models"
+)
+
+func newTestAPI(t *testing.T, options ...func(*API)) *API {
+	t.Helper()
+
+	router, err := models.NewRouter(models.Config{
+		Default: "llama3.2",
+		Allowed: []string{"llama3.2", "llama3.2:1b"},
+		Aliases: map[string]string{"fast": "llama3.2:1b"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return New(append([]func(*API){WithRouter(router), WithBackend(backend.NewFake())}, options...)...)
+}
+
+func TestPromptHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		url            string
+		header         http.Header
+		expectedStatus int
+		expectedBody   string
+		expectedModel  string
+	}{
+		{
+			name:           "Empty prompt",
+			url:            "/",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "Prompt is empty\n",
+		},
+		{
+			name:           "Default model",
+			url:            "/?q=hello",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "echo: hello",
+			expectedModel:  "llama3.2",
+		},
+		{
+			name:           "Model alias in query",
+			url:            "/?q=hello&model=fast",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "echo: hello",
+			expectedModel:  "llama3.2:1b",
+		},
+		{
+			name:           "Model in header",
+			url:            "/?q=hello",
+			header:         http.Header{ModelHeader: {"llama3.2:1b"}},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "echo: hello",
+			expectedModel:  "llama3.2:1b",
+		},
+		{
+			name:           "Model not allowed",
+			url:            "/?q=hello&model=mistral",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "model is not allowed: mistral\n",
+		},
+		{
+			name:           "Streaming",
+			url:            "/?q=hello+world&stream=1",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "echo: hello world",
+			expectedModel:  "llama3.2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for key, values := range tt.header {
+				req.Header[http.CanonicalHeaderKey(key)] = values
+			}
+
+			rr := httptest.NewRecorder()
+			newTestAPI(t).ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v",
+					status, tt.expectedStatus)
+			}
+
+			if rr.Body.String() != tt.expectedBody {
+				t.Errorf("handler returned unexpected body: got %q want %q",
+					rr.Body.String(), tt.expectedBody)
+			}
+
+			if model := rr.Header().Get(ModelHeader); model != tt.expectedModel {
+				t.Errorf("handler returned unexpected model: got %q want %q",
+					model, tt.expectedModel)
+			}
+		})
+	}
+}
+
+func TestPromptCache(t *testing.T) {
+	api := newTestAPI(t, WithCache(cache.New(time.Minute, 10, 0)))
+
+	tests := []struct {
+		url           string
+		expectedCache string
+	}{
+		{url: "/?q=hello&temperature=0", expectedCache: "MISS"},
+		{url: "/?q=hello&temperature=0", expectedCache: "HIT"},
+		{url: "/?q=hello&temperature=0&stream=1", expectedCache: "HIT"},
+		{url: "/?q=hello&temperature=0&seed=1", expectedCache: "MISS"},
+		{url: "/?q=hello", expectedCache: "BYPASS"},
+		{url: "/?q=hello&temperature=0.7", expectedCache: "BYPASS"},
+		{url: "/?q=hello&temperature=0.7&cache=1", expectedCache: "MISS"},
+		{url: "/?q=hello&temperature=0.7&cache=1", expectedCache: "HIT"},
+		{url: "/?q=hello&temperature=0&cache=0", expectedCache: "BYPASS"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.url, nil)
+		rr := httptest.NewRecorder()
+		api.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: unexpected status %d", tt.url, rr.Code)
+		}
+		if got := rr.Header().Get(CacheHeader); got != tt.expectedCache {
+			t.Errorf("%s: got %s=%q, want %q", tt.url, CacheHeader, got, tt.expectedCache)
+		}
+		if rr.Body.String() != "echo: hello" {
+			t.Errorf("%s: unexpected body %q", tt.url, rr.Body.String())
+		}
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	manager, err := auth.NewManager(auth.Config{Clients: []auth.ClientConfig{
+		{Name: "team-a", KeyHash: auth.HashKey("secret"), DailyTokens: 5},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := newTestAPI(t, WithAuth(manager), WithBackend(auth.NewBackend(backend.NewFake(), manager)))
+
+	tests := []struct {
+		name           string
+		header         http.Header
+		expectedStatus int
+		expectedQuota  string
+	}{
+		{name: "Missing key", expectedStatus: http.StatusUnauthorized},
+		{name: "Wrong key", header: http.Header{APIKeyHeader: {"wrong"}}, expectedStatus: http.StatusUnauthorized},
+		{name: "Bearer token", header: http.Header{"Authorization": {"Bearer secret"}}, expectedStatus: http.StatusOK, expectedQuota: "5"},
+		{name: "Quota exceeded", header: http.Header{APIKeyHeader: {"secret"}}, expectedStatus: http.StatusTooManyRequests, expectedQuota: "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/?q=one+two+three", nil)
+			for key, values := range tt.header {
+				req.Header[http.CanonicalHeaderKey(key)] = values
+			}
+			rr := httptest.NewRecorder()
+			api.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, tt.expectedStatus)
+			}
+			if got := rr.Header().Get(QuotaDailyHeader); got != tt.expectedQuota {
+				t.Errorf("unexpected %s: got %q want %q", QuotaDailyHeader, got, tt.expectedQuota)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/auth"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/errs"
+)
+
+const (
+	APIKeyHeader = "X-API-Key"
+
+	QuotaDailyHeader   = "X-Quota-Remaining-Daily"
+	QuotaMonthlyHeader = "X-Quota-Remaining-Monthly"
+)
+
+// authenticate checks the client's API key, rate limit and quotas. On success
+// it returns the request with the client name stored in its context.
+func (api *API) authenticate(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	key := r.Header.Get(APIKeyHeader)
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		key = bearer
+	}
+
+	name, err := api.auth.Authenticate(key)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="llm"`)
+		api.WriteError(w, r, errs.NewErrUnauthorized(err.Error()))
+		return r, false
+	}
+
+	remaining, err := api.auth.Allow(name)
+	setQuotaHeaders(w, remaining)
+
+	var rateErr auth.RateLimitError
+	var quotaErr auth.QuotaError
+	switch {
+	case errors.As(err, &rateErr):
+		api.WriteError(w, r, errs.NewErrTooManyRequests(err.Error(), rateErr.RetryAfter))
+		return r, false
+	case errors.As(err, &quotaErr):
+		api.WriteError(w, r, errs.NewErrTooManyRequests(err.Error(), quotaErr.RetryAfter))
+		return r, false
+	case err != nil:
+		api.WriteError(w, r, err)
+		return r, false
+	}
+
+	return r.WithContext(auth.WithClient(r.Context(), name)), true
+}
+
+func setQuotaHeaders(w http.ResponseWriter, remaining auth.Remaining) {
+	if remaining.Daily >= 0 {
+		w.Header().Set(QuotaDailyHeader, strconv.FormatInt(remaining.Daily, 10))
+	}
+	if remaining.Monthly >= 0 {
+		w.Header().Set(QuotaMonthlyHeader, strconv.FormatInt(remaining.Monthly, 10))
+	}
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/auth"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/batch"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/errs"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/models"
+)
+
+const maxBatchBodySize = 32 << 20
+
+// createBatchHandler accepts a JSONL list of prompts and queues them as a job.
+func (api *API) createBatchHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBatchBodySize)
+
+	items, err := batch.ParseItems(r.Body)
+	if err != nil {
+		api.WriteError(w, r, errs.NewErrBadRequest(fmt.Sprintf("invalid batch: %v", err)))
+		return
+	}
+
+	for i := range items {
+		model := items[i].Model
+		if model == "" {
+			model = r.Header.Get(ModelHeader)
+		}
+		model, err = api.router.Resolve(model, items[i].Prompt, r.Header)
+		if errors.Is(err, models.ErrNotAllowed) {
+			api.WriteError(w, r, errs.NewErrBadRequest(fmt.Sprintf("item %d: %v", i, err)))
+			return
+		}
+		if err != nil {
+			api.WriteError(w, r, err)
+			return
+		}
+		items[i].Model = model
+	}
+
+	job, err := api.batches.Submit(auth.ClientFromContext(r.Context()), items)
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", "/batch/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	api.WriteJSON(w, r, job)
+}
+
+func (api *API) getBatchHandler(w http.ResponseWriter, r *http.Request) {
+	job, err := api.batchJob(r)
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+
+	api.WriteJSON(w, r, job)
+}
+
+// batchResultsHandler streams the results written so far as JSONL.
+func (api *API) batchResultsHandler(w http.ResponseWriter, r *http.Request) {
+	job, err := api.batchJob(r)
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+
+	results, err := api.batches.Results(job.ID)
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	defer results.Close()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	_, err = io.Copy(w, results)
+	if err != nil {
+		log.Printf("Error streaming batch results: %v", err)
+	}
+}
+
+// batchJob returns the job from the request path. Jobs of other clients are reported as missing.
+func (api *API) batchJob(r *http.Request) (batch.Job, error) {
+	job, err := api.batches.Get(r.PathValue("id"))
+	if err == nil && job.Client != auth.ClientFromContext(r.Context()) {
+		err = batch.ErrNotFound
+	}
+	if errors.Is(err, batch.ErrNotFound) {
+		return job, errs.NewErrNotFound(err.Error())
+	}
+	return job, err
+}
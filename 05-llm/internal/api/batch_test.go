@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/batch"
+)
+
+func TestBatchHandlers(t *testing.T) {
+	fake := backend.NewFake()
+	batches, err := batch.Open(fake, batch.Config{Dir: t.TempDir(), Concurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go batches.Run(ctx)
+	api := newTestAPI(t, WithBackend(fake), WithBatches(batches))
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{name: "Valid batch", body: `{"id":"a","prompt":"one"}` + "\n" + `{"id":"b","prompt":"two","model":"fast"}`, expectedStatus: http.StatusAccepted},
+		{name: "Empty batch", body: "", expectedStatus: http.StatusBadRequest},
+		{name: "Invalid line", body: `{"prompt":"one"}` + "\nnot json", expectedStatus: http.StatusBadRequest},
+		{name: "Model not allowed", body: `{"prompt":"one","model":"gpt-4"}`, expectedStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := serve(api, "POST", "/batch", tt.body)
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v: %s", status, tt.expectedStatus, rr.Body)
+			}
+		})
+	}
+
+	rr := serve(api, "POST", "/batch", `{"id":"a","prompt":"one"}`+"\n"+`{"id":"b","prompt":"two","model":"fast"}`)
+	var job batch.Job
+	if err := json.Unmarshal(rr.Body.Bytes(), &job); err != nil {
+		t.Fatal(err)
+	}
+	if loc := rr.Header().Get("Location"); loc != "/batch/"+job.ID {
+		t.Errorf("unexpected Location %q", loc)
+	}
+
+	for range 200 {
+		rr = serve(api, "GET", "/batch/"+job.ID, "")
+		if err := json.Unmarshal(rr.Body.Bytes(), &job); err != nil {
+			t.Fatal(err)
+		}
+		if job.Status == batch.StatusCompleted {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if job.Status != batch.StatusCompleted || job.Succeeded != 2 {
+		t.Fatalf("job did not complete: %+v", job)
+	}
+
+	rr = serve(api, "GET", "/batch/"+job.ID+"/results", "")
+	if ct := rr.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("unexpected Content-Type %q", ct)
+	}
+	models := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(rr.Body.String()), "\n") {
+		var res batch.Result
+		if err := json.Unmarshal([]byte(line), &res); err != nil {
+			t.Fatal(err)
+		}
+		models[res.ID] = res.Model
+	}
+	if models["a"] != "llama3.2" || models["b"] != "llama3.2:1b" {
+		t.Errorf("items were not routed to resolved models: %v", models)
+	}
+
+	if rr = serve(api, "GET", "/batch/missing", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for missing job, got %d", rr.Code)
+	}
+}
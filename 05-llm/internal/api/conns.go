@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"sync"
+)
+
+// Connections tracks the WebSocket connections of the API. They are hijacked
+// from the HTTP server, so http.Server.Shutdown neither closes nor waits
+// for them; Shutdown does that instead.
+type Connections struct {
+	mu      sync.Mutex
+	closing bool
+	conns   map[*wsConn]struct{}
+	wg      sync.WaitGroup
+}
+
+func NewConnections() *Connections {
+	return &Connections{conns: make(map[*wsConn]struct{})}
+}
+
+// Closing reports whether Shutdown was called, after which new connections
+// are refused.
+func (cs *Connections) Closing() bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.closing
+}
+
+// add registers the connection unless the shutdown has already started.
+func (cs *Connections) add(c *wsConn) bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if cs.closing {
+		return false
+	}
+	cs.conns[c] = struct{}{}
+	cs.wg.Add(1)
+	return true
+}
+
+func (cs *Connections) remove(c *wsConn) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if _, ok := cs.conns[c]; ok {
+		delete(cs.conns, c)
+		cs.wg.Done()
+	}
+}
+
+// Shutdown closes the idle connections and lets the others finish the reply
+// being generated before closing them. Once ctx is done the remaining
+// connections are closed with their generations cancelled.
+func (cs *Connections) Shutdown(ctx context.Context) error {
+	cs.mu.Lock()
+	cs.closing = true
+	conns := make([]*wsConn, 0, len(cs.conns))
+	for c := range cs.conns {
+		conns = append(conns, c)
+	}
+	cs.mu.Unlock()
+
+	for _, c := range conns {
+		c.drain()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		cs.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	cs.mu.Lock()
+	for c := range cs.conns {
+		c.kill()
+	}
+	cs.mu.Unlock()
+	return ctx.Err()
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/errs"
+)
+
+// RequestTimeoutHeader sets the deadline of the request as a duration ("90s")
+// or a number of seconds. It is capped by the server maximum.
+const RequestTimeoutHeader = "X-Request-Timeout"
+
+// withDeadline bounds the request context by the timeout requested by the
+// client or the server default. Cancelling the context aborts upstream calls.
+func (api *API) withDeadline(r *http.Request) (*http.Request, context.CancelFunc, error) {
+	timeout := api.timeout
+	if v := r.Header.Get(RequestTimeoutHeader); v != "" {
+		d, err := parseTimeout(v)
+		if err != nil {
+			return r, nil, errs.NewErrBadRequest(fmt.Sprintf("invalid %s: %v", RequestTimeoutHeader, err))
+		}
+		timeout = d
+	}
+	if api.maxTimeout > 0 && (timeout <= 0 || timeout > api.maxTimeout) {
+		timeout = api.maxTimeout
+	}
+	if timeout <= 0 {
+		return r, func() {}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	return r.WithContext(ctx), cancel, nil
+}
+
+func parseTimeout(v string) (time.Duration, error) {
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		seconds, convErr := strconv.ParseFloat(v, 64)
+		if convErr != nil {
+			return 0, err
+		}
+		d = time.Duration(seconds * float64(time.Second))
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("timeout must be positive")
+	}
+	return d, nil
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+)
+
+func TestRequestDeadline(t *testing.T) {
+	tests := []struct {
+		name           string
+		timeout        string
+		queueTimeout   string
+		clientCancel   bool
+		expectedStatus int
+		cancelled      int
+	}{
+		{name: "Within deadline", timeout: "5s", expectedStatus: http.StatusOK},
+		{name: "Client deadline", timeout: "0.05", expectedStatus: http.StatusGatewayTimeout, cancelled: 1},
+		{name: "Client cancellation", clientCancel: true, expectedStatus: StatusClientClosedRequest, cancelled: 1},
+		{name: "Capped by server maximum", timeout: "1h", expectedStatus: http.StatusOK},
+		{name: "Server default", expectedStatus: http.StatusOK},
+		{name: "Invalid header", timeout: "soon", expectedStatus: http.StatusBadRequest},
+		{name: "Queue timeout", queueTimeout: "500ms", expectedStatus: http.StatusOK},
+		{name: "Invalid queue timeout", queueTimeout: "soon", expectedStatus: http.StatusBadRequest},
+		{name: "Negative queue timeout", queueTimeout: "-1s", expectedStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := backend.NewFake()
+			fake.SetDelay(100 * time.Millisecond)
+			api := newTestAPI(t, WithBackend(fake), WithRequestTimeout(time.Second, 2*time.Second))
+
+			req := httptest.NewRequest("GET", "/?q=hello", nil)
+			if tt.timeout != "" {
+				req.Header.Set(RequestTimeoutHeader, tt.timeout)
+			}
+			if tt.queueTimeout != "" {
+				req.Header.Set(QueueTimeoutHeader, tt.queueTimeout)
+			}
+			if tt.clientCancel {
+				ctx, cancel := context.WithCancel(req.Context())
+				time.AfterFunc(50*time.Millisecond, cancel)
+				req = req.WithContext(ctx)
+			}
+			rr := httptest.NewRecorder()
+			api.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v: %s", status, tt.expectedStatus, rr.Body)
+			}
+			if got := fake.Cancelled(); got != tt.cancelled {
+				t.Errorf("backend saw %d cancellations, want %d", got, tt.cancelled)
+			}
+		})
+	}
+
+	fake := backend.NewFake()
+	fake.SetDelay(time.Second)
+	api := newTestAPI(t, WithBackend(fake), WithRequestTimeout(0, 50*time.Millisecond))
+	req := httptest.NewRequest("GET", "/?q=hello", nil)
+	req.Header.Set(RequestTimeoutHeader, "1h")
+	rr := httptest.NewRecorder()
+	api.ServeHTTP(rr, req)
+	if rr.Code != http.StatusGatewayTimeout || fake.Cancelled() != 1 {
+		t.Errorf("server maximum should bound client deadline, got %d with %d cancellations", rr.Code, fake.Cancelled())
+	}
+}
+
+func TestClientDisconnect(t *testing.T) {
+	tests := []struct {
+		name   string
+		url    string
+		stream bool
+	}{
+		{name: "Non-streaming", url: "/?q=one+two+three"},
+		{name: "Streaming", url: "/?q=one+two+three&stream=1", stream: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := backend.NewFake()
+			fake.SetDelay(200 * time.Millisecond)
+			srv := httptest.NewServer(newTestAPI(t, WithBackend(fake)))
+			defer srv.Close()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+tt.url, nil)
+			if !tt.stream {
+				time.AfterFunc(50*time.Millisecond, cancel)
+			}
+
+			resp, err := srv.Client().Do(req)
+			if err == nil {
+				// Read the first streamed word and hang up.
+				bufio.NewReader(resp.Body).ReadString(' ')
+				cancel()
+				resp.Body.Close()
+			}
+
+			deadline := time.Now().Add(2 * time.Second)
+			for fake.Cancelled() == 0 {
+				if time.Now().After(deadline) {
+					t.Fatal("backend did not see the cancellation")
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			cancel()
+		})
+	}
+}
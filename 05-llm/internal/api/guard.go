@@ -0,0 +1,62 @@
+package api
+
+import (
+	"bufio"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/guard"
+)
+
+// GuardrailsHeader lists guardrail rules which fired for the request as stage:filter:rule.
+const GuardrailsHeader = "X-Guardrails"
+
+// guardWriter adds the guardrails header before the response is written.
+// Rules fired after the headers are sent, e.g. while streaming, are only logged.
+type guardWriter struct {
+	http.ResponseWriter
+	report      *guard.Report
+	wroteHeader bool
+}
+
+func (w *guardWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if findings := w.report.Findings(); len(findings) > 0 {
+			w.Header().Set(GuardrailsHeader, joinFindings(findings))
+		}
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *guardWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Hijack lets the connection be taken over, e.g. for WebSocket upgrades.
+func (w *guardWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+func (w *guardWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func logFindings(r *http.Request, report *guard.Report) {
+	if findings := report.Findings(); len(findings) > 0 {
+		log.Printf("Guardrails fired for %s %s: %s", r.Method, r.URL.Path, joinFindings(findings))
+	}
+}
+
+func joinFindings(findings []guard.Finding) string {
+	s := make([]string, len(findings))
+	for i, f := range findings {
+		s[i] = f.String()
+	}
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/guard"
+)
+
+func TestGuardrails(t *testing.T) {
+	g, err := guard.New(guard.Config{
+		Input: guard.StageConfig{BlockKeywords: []string{"password"}, Redact: []string{guard.PIIEmail}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := newTestAPI(t, WithBackend(guard.NewBackend(backend.NewFake(), g)), WithGuardrails())
+
+	tests := []struct {
+		name           string
+		prompt         string
+		expectedStatus int
+		expectedBody   string
+		expectedHeader string
+	}{
+		{name: "Clean prompt", prompt: "hello", expectedStatus: http.StatusOK, expectedBody: "echo: hello"},
+		{name: "Redacted prompt", prompt: "mail ann@example.com", expectedStatus: http.StatusOK, expectedBody: "echo: mail [REDACTED EMAIL]", expectedHeader: "input:pii:email"},
+		{name: "Blocked prompt", prompt: "my password", expectedStatus: http.StatusUnprocessableEntity, expectedHeader: "input:blocklist:password"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/?q="+url.QueryEscape(tt.prompt), nil)
+			rr := httptest.NewRecorder()
+			api.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v: %s", status, tt.expectedStatus, rr.Body)
+			}
+			if tt.expectedBody != "" && rr.Body.String() != tt.expectedBody {
+				t.Errorf("handler returned unexpected body: got %q want %q", rr.Body.String(), tt.expectedBody)
+			}
+			if got := rr.Header().Get(GuardrailsHeader); got != tt.expectedHeader {
+				t.Errorf("handler returned unexpected %s header: got %q want %q", GuardrailsHeader, got, tt.expectedHeader)
+			}
+		})
+	}
+
+	req := httptest.NewRequest("GET", "/?q=password", nil)
+	rr := httptest.NewRecorder()
+	api.ServeHTTP(rr, req)
+
+	var resp struct {
+		Details guard.Finding `json:"details"`
+	}
+	if err = json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Details.Rule != "password" || resp.Details.Stage != guard.StageInput {
+		t.Errorf("expected the fired rule in error details, got %s", rr.Body)
+	}
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+)
+
+// readyTimeout bounds the backend check of the readiness probe, which
+// Kubernetes gives one second by default.
+const readyTimeout = 800 * time.Millisecond
+
+type readinessResponse struct {
+	Ready bool   `json:"ready"`
+	Model string `json:"model"`
+	Error string `json:"error,omitempty"`
+}
+
+// registerProbes registers the Kubernetes probes. They are served before
+// authentication and request deadlines.
+func (api *API) registerProbes() {
+	api.probes.HandleFunc("GET /healthz", api.healthzHandler)
+	api.probes.HandleFunc("GET /readyz", api.readyzHandler)
+}
+
+// healthzHandler reports that the process is alive.
+func (api *API) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
+
+// readyzHandler reports whether the backend is reachable and serves the
+// default model.
+func (api *API) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	resp := readinessResponse{Model: api.router.Default()}
+	available, err := api.backend.ListModels(ctx)
+	if err == nil && !slices.ContainsFunc(available, func(m backend.Model) bool { return sameModel(m.Name, resp.Model) }) {
+		err = fmt.Errorf("model %s is not available", resp.Model)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		resp.Error = err.Error()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	} else {
+		resp.Ready = true
+	}
+	api.WriteJSON(w, r, resp)
+}
+
+// sameModel treats "llama3.2" and "llama3.2:latest" as the same model.
+func sameModel(a, b string) bool {
+	return strings.TrimSuffix(a, ":latest") == strings.TrimSuffix(b, ":latest")
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/auth"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/ollamatest"
+)
+
+func TestProbes(t *testing.T) {
+	srv := ollamatest.NewServer("llama3.2:latest", "nomic-embed-text")
+	defer srv.Close()
+	manager, err := auth.NewManager(auth.Config{Clients: []auth.ClientConfig{{Name: "team-a", KeyHash: auth.HashKey("secret")}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		backend        backend.Backend
+		path           string
+		fail           bool
+		expectedStatus int
+		expectedError  string
+	}{
+		{name: "Alive", backend: backend.NewFake(), path: "/healthz", expectedStatus: http.StatusOK},
+		{name: "Alive while backend fails", backend: newOllamaBackend(t, srv), path: "/healthz", fail: true, expectedStatus: http.StatusOK},
+		{name: "Ready", backend: newOllamaBackend(t, srv), path: "/readyz", expectedStatus: http.StatusOK},
+		{name: "Backend unreachable", backend: newOllamaBackend(t, srv), path: "/readyz", fail: true, expectedStatus: http.StatusServiceUnavailable, expectedError: "out of memory"},
+		{name: "Model missing", backend: backend.NewFake("qwen"), path: "/readyz", expectedStatus: http.StatusServiceUnavailable, expectedError: "model llama3.2 is not available"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv.Reset()
+			if tt.fail {
+				srv.FailNext(1, http.StatusInternalServerError, "out of memory")
+			}
+			// The probes need no API key.
+			api := newTestAPI(t, WithAuth(manager), WithBackend(tt.backend))
+			rr := httptest.NewRecorder()
+			api.ServeHTTP(rr, httptest.NewRequest("GET", tt.path, nil))
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, tt.expectedStatus, rr.Body)
+			}
+			if tt.path != "/readyz" {
+				return
+			}
+			var resp readinessResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Ready != (tt.expectedStatus == http.StatusOK) || resp.Model != "llama3.2" {
+				t.Errorf("unexpected readiness %+v", resp)
+			}
+			if !strings.Contains(resp.Error, tt.expectedError) || (resp.Error == "") != (tt.expectedError == "") {
+				t.Errorf("unexpected error %q, want it to contain %q", resp.Error, tt.expectedError)
+			}
+		})
+	}
+}
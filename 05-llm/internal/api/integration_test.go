@@ -0,0 +1,379 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/models"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/ollamatest"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/session"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/tools"
+)
+
+// The tests in this file run the API against the real Ollama backend
+// talking HTTP to a fake Ollama server.
+
+func newOllamaBackend(t *testing.T, srv *ollamatest.Server) *backend.Ollama {
+	t.Helper()
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return backend.NewOllama(u, srv.Client())
+}
+
+func serve(api http.Handler, method, url, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	api.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestOllamaPrompt(t *testing.T) {
+	srv := ollamatest.NewServer()
+	defer srv.Close()
+	srv.SetReply("hi", ollamatest.Reply{Content: "Hello there!"})
+	api := newTestAPI(t, WithBackend(newOllamaBackend(t, srv)))
+
+	tests := []struct {
+		name           string
+		url            string
+		expectedStatus int
+		expectedBody   string
+		expectedPath   string
+		expectedStream bool
+	}{
+		{name: "Echo", url: "/?q=hello", expectedStatus: http.StatusOK, expectedBody: "echo: hello", expectedPath: "/api/generate"},
+		{name: "Scripted reply", url: "/?q=hi", expectedStatus: http.StatusOK, expectedBody: "Hello there!", expectedPath: "/api/generate"},
+		{
+			name:           "Streaming",
+			url:            "/?q=one+two+three&stream=1",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "echo: one two three",
+			expectedPath:   "/api/chat",
+			expectedStream: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv.Reset()
+			srv.SetReply("hi", ollamatest.Reply{Content: "Hello there!"})
+
+			rr := serve(api, "GET", tt.url, "")
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v: %s", status, tt.expectedStatus, rr.Body)
+			}
+			if body := rr.Body.String(); body != tt.expectedBody {
+				t.Errorf("handler returned unexpected body: got %q want %q", body, tt.expectedBody)
+			}
+
+			requests := srv.Requests()
+			if len(requests) != 1 {
+				t.Fatalf("llm server got %d requests, want 1", len(requests))
+			}
+			got := requests[0]
+			if got.Path != tt.expectedPath || got.Stream != tt.expectedStream || got.Model != "llama3.2" {
+				t.Errorf("unexpected llm request %s stream=%v model=%s", got.Path, got.Stream, got.Model)
+			}
+		})
+	}
+}
+
+func TestOllamaErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		setup          func(*ollamatest.Server)
+		url            string
+		expectedStatus int
+	}{
+		{
+			name:           "Server error",
+			setup:          func(s *ollamatest.Server) { s.FailNext(1, http.StatusInternalServerError, "out of memory") },
+			url:            "/?q=hello",
+			expectedStatus: http.StatusBadGateway,
+		},
+		{
+			name: "Scripted error",
+			setup: func(s *ollamatest.Server) {
+				s.Script(ollamatest.Reply{Status: http.StatusNotFound, Error: "model not found"})
+			},
+			url:            "/?q=hello",
+			expectedStatus: http.StatusBadGateway,
+		},
+		{
+			name:           "Streaming error before output",
+			setup:          func(s *ollamatest.Server) { s.FailNext(1, http.StatusServiceUnavailable, "busy") },
+			url:            "/?q=hello&stream=1",
+			expectedStatus: http.StatusBadGateway,
+		},
+		{
+			name:           "Deadline",
+			setup:          func(s *ollamatest.Server) { s.SetLatency(time.Second) },
+			url:            "/?q=hello",
+			expectedStatus: http.StatusGatewayTimeout,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := ollamatest.NewServer()
+			defer srv.Close()
+			tt.setup(srv)
+			api := newTestAPI(t, WithBackend(newOllamaBackend(t, srv)), WithRequestTimeout(50*time.Millisecond, time.Second))
+
+			rr := serve(api, "GET", tt.url, "")
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v: %s", status, tt.expectedStatus, rr.Body)
+			}
+		})
+	}
+}
+
+func TestOllamaDeadlineCancelsUpstream(t *testing.T) {
+	srv := ollamatest.NewServer()
+	defer srv.Close()
+	srv.SetLatency(time.Second)
+	api := newTestAPI(t, WithBackend(newOllamaBackend(t, srv)), WithRequestTimeout(50*time.Millisecond, time.Second))
+
+	start := time.Now()
+	rr := serve(api, "GET", "/?q=hello", "")
+	if rr.Code != http.StatusGatewayTimeout {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusGatewayTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("request took %v, deadline was not applied", elapsed)
+	}
+
+	// The server notices the disconnect asynchronously.
+	for range 50 {
+		if srv.Cancelled() == 1 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("llm server saw %d cancellations, want 1", srv.Cancelled())
+}
+
+func TestOllamaPoolFailover(t *testing.T) {
+	first := ollamatest.NewServer()
+	defer first.Close()
+	second := ollamatest.NewServer()
+	defer second.Close()
+	first.FailNext(1, http.StatusInternalServerError, "crashed")
+
+	pool := backend.NewPool(
+		backend.Upstream{Name: "first", Backend: newOllamaBackend(t, first)},
+		backend.Upstream{Name: "second", Backend: newOllamaBackend(t, second)},
+	)
+	api := newTestAPI(t, WithBackend(pool))
+
+	rr := serve(api, "GET", "/?q=hello", "")
+	if rr.Code != http.StatusOK || rr.Body.String() != "echo: hello" {
+		t.Fatalf("handler returned %d: %s", rr.Code, rr.Body)
+	}
+	if len(first.Requests()) != 1 || len(second.Requests()) != 1 {
+		t.Errorf("expected one request per upstream, got %d and %d", len(first.Requests()), len(second.Requests()))
+	}
+}
+
+func TestOllamaSession(t *testing.T) {
+	srv := ollamatest.NewServer()
+	defer srv.Close()
+	api := newTestAPI(t, WithBackend(newOllamaBackend(t, srv)))
+
+	rr := serve(api, "POST", "/sessions", `{"system":"Be brief."}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create returned %d: %s", rr.Code, rr.Body)
+	}
+	var sess struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &sess); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, content := range []string{"first", "second"} {
+		rr = serve(api, "POST", "/sessions/"+sess.ID+"/messages", `{"content":"`+content+`"}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("message returned %d: %s", rr.Code, rr.Body)
+		}
+	}
+
+	requests := srv.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("llm server got %d requests, want 2", len(requests))
+	}
+	expected := []ollamatest.Message{
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: "first"},
+		{Role: "assistant", Content: "echo: first"},
+		{Role: "user", Content: "second"},
+	}
+	if got := requests[1].Messages; !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected history sent to llm server: %+v", got)
+	}
+}
+
+func TestOllamaContextWindow(t *testing.T) {
+	srv := ollamatest.NewServer()
+	defer srv.Close()
+	srv.SetContextLength("llama3.2", 80)
+	llm := newOllamaBackend(t, srv)
+	api := newTestAPI(t, WithBackend(llm), WithSessions(session.NewStore(0), session.Window{
+		ContextSize: models.NewContextSizes(nil, llm.ContextSize).Get,
+		Strategy:    session.TruncateMiddle,
+	}))
+
+	rr := serve(api, "POST", "/sessions", `{"system":"Be brief."}`)
+	var sess struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &sess); err != nil {
+		t.Fatal(err)
+	}
+
+	long := strings.Repeat("x", 60)
+	var fits []*session.Fit
+	for _, content := range []string{"first " + long, "second " + long} {
+		rr = serve(api, "POST", "/sessions/"+sess.ID+"/messages", `{"content":"`+content+`"}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("message returned %d: %s", rr.Code, rr.Body)
+		}
+		var resp sessionMessageResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		fits = append(fits, resp.Context)
+	}
+
+	// The context of 80 tokens leaves 60 for the history.
+	expected := &session.Fit{Strategy: session.TruncateMiddle, Budget: 60, Tokens: 49, Dropped: 1}
+	if fits[0] != nil || !reflect.DeepEqual(fits[1], expected) {
+		t.Errorf("unexpected fits %+v, %+v", fits[0], fits[1])
+	}
+
+	rr = serve(api, "GET", "/prompt?q="+strings.Repeat("x", 300), "")
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "exceeds the model context") {
+		t.Errorf("expected a too long prompt to be rejected, got %d: %s", rr.Code, rr.Body)
+	}
+
+	var shows int
+	var last ollamatest.Request
+	for _, req := range srv.Requests() {
+		switch req.Path {
+		case "/api/show":
+			shows++
+		case "/api/chat":
+			last = req
+		}
+	}
+	if shows != 1 {
+		t.Errorf("expected the context size to be looked up once, got %d", shows)
+	}
+	if len(last.Messages) != 3 || !strings.HasPrefix(last.Messages[1].Content, "first") || !strings.HasPrefix(last.Messages[2].Content, "second") {
+		t.Errorf("unexpected history sent to llm server: %+v", last.Messages)
+	}
+}
+
+func TestOllamaStructured(t *testing.T) {
+	srv := ollamatest.NewServer()
+	defer srv.Close()
+	srv.Script(
+		ollamatest.Reply{Content: `{"name":"Ann"}`},
+		ollamatest.Reply{Content: `{"name":"Ann","age":30}`},
+	)
+	api := newTestAPI(t, WithBackend(newOllamaBackend(t, srv)))
+
+	schema := `{"type":"object","properties":{"age":{"type":"integer"}},"required":["age"]}`
+	rr := serve(api, "POST", "/structured", `{"prompt":"Ann, 30","schema":`+schema+`}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned %d: %s", rr.Code, rr.Body)
+	}
+
+	var resp struct {
+		Data     json.RawMessage `json:"data"`
+		Attempts int             `json:"attempts"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Attempts != 2 || string(resp.Data) != `{"name":"Ann","age":30}` {
+		t.Errorf("unexpected response %s", rr.Body)
+	}
+
+	for _, req := range srv.Requests() {
+		if string(req.Format) != schema {
+			t.Errorf("schema was not sent as format, got %s", req.Format)
+		}
+	}
+}
+
+func TestOllamaTools(t *testing.T) {
+	srv := ollamatest.NewServer()
+	defer srv.Close()
+	srv.Script(
+		ollamatest.Reply{ToolCalls: []ollamatest.ToolCall{ollamatest.NewToolCall("current_time", map[string]string{})}},
+		ollamatest.Reply{Content: "It is noon."},
+	)
+
+	registry := tools.NewRegistry()
+	now := func() time.Time { return time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC) }
+	if err := registry.Register(tools.CurrentTime(now)); err != nil {
+		t.Fatal(err)
+	}
+	b := newOllamaBackend(t, srv)
+	api := newTestAPI(t, WithBackend(b), WithTools(tools.NewRunner(b, registry, 3)))
+
+	rr := serve(api, "POST", "/tools/chat", `{"prompt":"What time is it?"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned %d: %s", rr.Code, rr.Body)
+	}
+	if !strings.Contains(rr.Body.String(), "It is noon.") {
+		t.Errorf("unexpected response %s", rr.Body)
+	}
+
+	requests := srv.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("llm server got %d requests, want 2", len(requests))
+	}
+	if !strings.Contains(string(requests[0].Tools), `"current_time"`) {
+		t.Errorf("tool definitions were not sent: %s", requests[0].Tools)
+	}
+	messages := requests[1].Messages
+	last := messages[len(messages)-1]
+	if last.Role != "tool" || last.Content != "2025-03-01T12:00:00Z" {
+		t.Errorf("tool result was not sent back, got %+v", last)
+	}
+}
+
+func TestOllamaEmbed(t *testing.T) {
+	srv := ollamatest.NewServer()
+	defer srv.Close()
+	api := newTestAPI(t, WithBackend(newOllamaBackend(t, srv)), WithEmbedModel("nomic-embed-text"))
+
+	rr := serve(api, "POST", "/embed", `{"model":"nomic-embed-text","input":["first text","second text"]}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned %d: %s", rr.Code, rr.Body)
+	}
+
+	var resp struct {
+		Embeddings [][]float64 `json:"embeddings"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]float64{ollamatest.Embedding("first text"), ollamatest.Embedding("second text")}
+	if !reflect.DeepEqual(resp.Embeddings, expected) {
+		t.Errorf("unexpected embeddings")
+	}
+	if n := len(srv.Requests()); n != 2 {
+		t.Errorf("llm server got %d requests, want 2", n)
+	}
+}
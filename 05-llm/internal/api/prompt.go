@@ -0,0 +1,206 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/cache"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/errs"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/models"
+)
+
+const (
+	// CacheHeader reports whether the response was served from cache:
+	// HIT, MISS or BYPASS when the request was not cacheable.
+	CacheHeader = "X-Cache"
+
+	cacheKind = "prompt"
+)
+
+func (api *API) promptHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Received request: %s %s", r.Method, r.URL.Path)
+
+	query := r.URL.Query()
+	prompt := query.Get("q")
+
+	if prompt == "" {
+		http.Error(w, "Prompt is empty", http.StatusBadRequest)
+		return
+	}
+
+	options, err := parseOptions(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	model, err := api.resolveModel(r, prompt)
+	if errors.Is(err, models.ErrNotAllowed) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("Error resolving model: %v", err)
+		return
+	}
+	w.Header().Set(ModelHeader, model)
+
+	req := backend.GenerateRequest{
+		Model:   model,
+		Prompt:  prompt,
+		Options: options,
+	}
+	err = api.window.Check(r.Context(), model, backend.ChatFromGenerate(req).Messages)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var cacheKey string
+	if api.cache != nil {
+		if !cacheable(query, options) {
+			w.Header().Set(CacheHeader, "BYPASS")
+		} else {
+			cacheKey = cache.Key(cacheKind, backend.ChatFromGenerate(req))
+			if resp, ok := api.cache.Get(cacheKey); ok {
+				w.Header().Set(CacheHeader, "HIT")
+				fmt.Fprint(w, resp.Content)
+				return
+			}
+			w.Header().Set(CacheHeader, "MISS")
+		}
+	}
+
+	var resp backend.Response
+	if stream, _ := strconv.ParseBool(query.Get("stream")); stream {
+		resp, err = api.streamPrompt(w, r, req)
+		if err != nil {
+			return
+		}
+	} else {
+		resp, err = api.backend.Generate(r.Context(), req)
+		if err != nil {
+			api.writePromptError(w, r, err)
+			return
+		}
+
+		fmt.Fprint(w, resp.Content)
+	}
+
+	if cacheKey != "" {
+		api.cache.Set(cacheKey, resp)
+	}
+}
+
+func (api *API) resolveModel(r *http.Request, prompt string) (string, error) {
+	requested := r.URL.Query().Get("model")
+	if requested == "" {
+		requested = r.Header.Get(ModelHeader)
+	}
+
+	return api.router.Resolve(requested, prompt, r.Header)
+}
+
+func (api *API) streamPrompt(w http.ResponseWriter, r *http.Request, req backend.GenerateRequest) (backend.Response, error) {
+	rc := http.NewResponseController(w)
+	started := false
+
+	resp, err := api.backend.Stream(r.Context(), backend.ChatFromGenerate(req), func(chunk backend.Chunk) error {
+		if !started {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Header().Set("X-Content-Type-Options", "nosniff")
+			started = true
+		}
+		_, err := fmt.Fprint(w, chunk.Content)
+		if err != nil {
+			return err
+		}
+		return rc.Flush()
+	})
+	if err != nil {
+		if !started {
+			api.writePromptError(w, r, err)
+		} else {
+			// The status is sent already, only a broken connection tells
+			// the client that the response is incomplete.
+			log.Printf("Error streaming llm response: %v", err)
+			panic(http.ErrAbortHandler)
+		}
+	}
+
+	return resp, err
+}
+
+func (api *API) writePromptError(w http.ResponseWriter, r *http.Request, err error) {
+	switch e := backendError(err).(type) {
+	case errs.ErrUnprocessable:
+		api.WriteError(w, r, e)
+	case errs.ErrUnavailable:
+		log.Printf("Error requesting llm server: %v", err)
+		setRetryAfter(w, e.RetryAfter())
+		http.Error(w, e.Error(), http.StatusServiceUnavailable)
+	case errs.ErrGatewayTimeout:
+		log.Printf("Error requesting llm server: %v", err)
+		http.Error(w, e.Error(), http.StatusGatewayTimeout)
+	case errs.ErrClientClosed:
+		log.Printf("Request cancelled by the client: %v", err)
+		http.Error(w, e.Error(), StatusClientClosedRequest)
+	default:
+		log.Printf("Error requesting llm server: %v", err)
+		http.Error(w, "Error requesting llm server", http.StatusBadGateway)
+	}
+}
+
+// cacheable reports whether a response may be reused. Sampling with a
+// non-zero (or backend default) temperature is random, so such requests
+// are only cached when the client explicitly opts in with cache=1.
+func cacheable(query url.Values, options backend.Options) bool {
+	if v := query.Get("cache"); v != "" {
+		optIn, _ := strconv.ParseBool(v)
+		return optIn
+	}
+	return options.Temperature != nil && *options.Temperature == 0
+}
+
+func parseOptions(query url.Values) (backend.Options, error) {
+	var options backend.Options
+
+	floats := map[string]**float64{
+		"temperature": &options.Temperature,
+		"top_p":       &options.TopP,
+	}
+	for name, dst := range floats {
+		if v := query.Get(name); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil || f < 0 {
+				return options, fmt.Errorf("parameter '%s' must be a non-negative number", name)
+			}
+			*dst = &f
+		}
+	}
+
+	ints := map[string]**int{
+		"top_k":       &options.TopK,
+		"seed":        &options.Seed,
+		"num_predict": &options.NumPredict,
+	}
+	for name, dst := range ints {
+		if v := query.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return options, fmt.Errorf("parameter '%s' must be an integer", name)
+			}
+			*dst = &n
+		}
+	}
+
+	options.Stop = query["stop"]
+
+	return options, nil
+}
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/errs"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/models"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/rag"
+)
+
+const defaultRAGResults = 4
+
+// stringList accepts either a single JSON string or an array of strings.
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(b []byte) error {
+	var s string
+	if json.Unmarshal(b, &s) == nil {
+		*l = []string{s}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]string)(l))
+}
+
+type embedRequest struct {
+	Model string     `json:"model"`
+	Input stringList `json:"input"`
+}
+
+type embedResponse struct {
+	Model      string      `json:"model"`
+	Embeddings [][]float64 `json:"embeddings"`
+}
+
+type documentRequest struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Source string `json:"source"`
+	Text   string `json:"text"`
+}
+
+type documentResponse struct {
+	rag.Document
+	Chunks int `json:"chunks"`
+}
+
+type ragRequest struct {
+	Question string `json:"question"`
+	K        int    `json:"k"`
+	Model    string `json:"model"`
+}
+
+type citation struct {
+	N int `json:"n"`
+	rag.Result
+}
+
+type ragResponse struct {
+	Answer    string        `json:"answer"`
+	Model     string        `json:"model"`
+	Citations []citation    `json:"citations"`
+	Usage     backend.Usage `json:"usage"`
+}
+
+func (api *API) embedHandler(w http.ResponseWriter, r *http.Request) {
+	var body embedRequest
+	err := decodeJSON(w, r, &body)
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	if len(body.Input) == 0 {
+		api.WriteError(w, r, errs.NewErrBadRequest("input is empty"))
+		return
+	}
+	// The configured embedding model needs no allowlist entry, other models
+	// are checked like the chat ones.
+	model := api.embedModel
+	if body.Model != "" && body.Model != api.embedModel {
+		model, err = api.router.Resolve(body.Model, "", r.Header)
+		if errors.Is(err, models.ErrNotAllowed) {
+			err = errs.NewErrBadRequest(err.Error())
+		}
+		if err != nil {
+			api.WriteError(w, r, err)
+			return
+		}
+	}
+
+	resp, err := api.backend.Embed(r.Context(), backend.EmbedRequest{Model: model, Input: body.Input})
+	if err != nil {
+		api.WriteError(w, r, backendError(err))
+		return
+	}
+
+	api.WriteJSON(w, r, embedResponse{Model: model, Embeddings: resp.Embeddings})
+}
+
+func (api *API) createDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	var body documentRequest
+	err := decodeJSON(w, r, &body)
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	if body.Text == "" {
+		api.WriteError(w, r, errs.NewErrBadRequest("document text is empty"))
+		return
+	}
+
+	// Failures of the index are not the backend's and are reported as they are.
+	doc, err := api.retriever.Ingest(r.Context(), rag.Document{ID: body.ID, Title: body.Title, Source: body.Source}, body.Text)
+	switch {
+	case errors.Is(err, rag.ErrEmptyDocument):
+		err = errs.NewErrBadRequest(err.Error())
+	case errors.Is(err, rag.ErrEmbedding):
+		err = backendError(err)
+	}
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+
+	chunks := len(doc.Chunks)
+	doc.Chunks = nil
+	w.WriteHeader(http.StatusCreated)
+	api.WriteJSON(w, r, documentResponse{Document: doc, Chunks: chunks})
+}
+
+func (api *API) listDocumentsHandler(w http.ResponseWriter, r *http.Request) {
+	api.WriteJSON(w, r, api.retriever.Index().Documents())
+}
+
+func (api *API) deleteDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	err := api.retriever.Index().Delete(r.PathValue("id"))
+	if errors.Is(err, rag.ErrNotFound) {
+		err = errs.NewErrNotFound(err.Error())
+	}
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (api *API) ragHandler(w http.ResponseWriter, r *http.Request) {
+	var body ragRequest
+	err := decodeJSON(w, r, &body)
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	if body.Question == "" {
+		api.WriteError(w, r, errs.NewErrBadRequest("question is empty"))
+		return
+	}
+	if body.K <= 0 {
+		body.K = defaultRAGResults
+	}
+
+	if body.Model == "" {
+		body.Model = r.Header.Get(ModelHeader)
+	}
+	model, err := api.router.Resolve(body.Model, body.Question, r.Header)
+	if errors.Is(err, models.ErrNotAllowed) {
+		err = errs.NewErrBadRequest(err.Error())
+	}
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+
+	results, err := api.retriever.Retrieve(r.Context(), body.Question, body.K)
+	if err != nil {
+		api.WriteError(w, r, backendError(err))
+		return
+	}
+
+	resp, err := api.backend.Chat(r.Context(), backend.ChatRequest{
+		Model:    model,
+		Messages: rag.Messages(body.Question, results),
+	})
+	if err != nil {
+		api.WriteError(w, r, backendError(err))
+		return
+	}
+
+	citations := make([]citation, len(results))
+	for i, res := range results {
+		citations[i] = citation{N: i + 1, Result: res}
+	}
+
+	w.Header().Set(ModelHeader, model)
+	api.WriteJSON(w, r, ragResponse{
+		Answer:    resp.Content,
+		Model:     model,
+		Citations: citations,
+		Usage:     resp.Usage,
+	})
+}
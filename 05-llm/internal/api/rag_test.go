@@ -0,0 +1,150 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/ollamatest"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/rag"
+)
+
+// chatRecorder keeps the chat requests it receives.
+type chatRecorder struct {
+	*backend.Fake
+	requests []backend.ChatRequest
+}
+
+func (b *chatRecorder) Chat(ctx context.Context, req backend.ChatRequest) (backend.Response, error) {
+	b.requests = append(b.requests, req)
+	return b.Fake.Chat(ctx, req)
+}
+
+func TestRAG(t *testing.T) {
+	index, err := rag.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	retriever := rag.NewRetriever(index, backend.NewFake(), "fake-embed", rag.Chunker{Size: 50})
+	recorder := &chatRecorder{Fake: backend.NewFake()}
+	api := newTestAPI(t, WithBackend(recorder), WithEmbedModel("fake-embed"), WithRetriever(retriever))
+
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		api.ServeHTTP(rr, httptest.NewRequest(method, url, strings.NewReader(body)))
+		return rr
+	}
+
+	if rr := do("POST", "/embed", `{"input":"hello"}`); rr.Code != http.StatusOK {
+		t.Fatalf("embed returned %d: %s", rr.Code, rr.Body)
+	}
+
+	for _, doc := range []string{
+		`{"id":"paris","title":"Paris","text":"Paris is the capital of France."}`,
+		`{"id":"rome","title":"Rome","text":"Rome is the capital of Italy."}`,
+	} {
+		if rr := do("POST", "/documents", doc); rr.Code != http.StatusCreated {
+			t.Fatalf("create document returned %d: %s", rr.Code, rr.Body)
+		}
+	}
+
+	rr := do("POST", "/rag", `{"question":"What is the capital of France?","k":1}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("rag returned %d: %s", rr.Code, rr.Body)
+	}
+
+	var resp ragResponse
+	if err = json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Citations) != 1 || resp.Citations[0].DocumentID != "paris" || resp.Citations[0].N != 1 {
+		t.Errorf("unexpected citations %+v", resp.Citations)
+	}
+	if resp.Answer != "echo: What is the capital of France?" {
+		t.Errorf("unexpected answer %q", resp.Answer)
+	}
+	// Only the question is client content for the input guardrails.
+	messages := recorder.requests[0].Messages
+	if len(messages) != 3 || !messages[1].Internal || !strings.Contains(messages[1].Content, "[1] Paris") || messages[2].Internal {
+		t.Errorf("expected the retrieved passage in an internal message, got %+v", messages)
+	}
+}
+
+func TestEmbedModel(t *testing.T) {
+	api := newTestAPI(t, WithEmbedModel("fake-embed"))
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedModel  string
+	}{
+		{name: "Default", body: `{"input":"hello"}`, expectedStatus: http.StatusOK, expectedModel: "fake-embed"},
+		{name: "Configured model", body: `{"model":"fake-embed","input":"hello"}`, expectedStatus: http.StatusOK, expectedModel: "fake-embed"},
+		{name: "Alias", body: `{"model":"fast","input":"hello"}`, expectedStatus: http.StatusOK, expectedModel: "llama3.2:1b"},
+		{name: "Not allowed", body: `{"model":"gpt-4","input":"hello"}`, expectedStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			api.ServeHTTP(rr, httptest.NewRequest("POST", "/embed", strings.NewReader(tt.body)))
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, tt.expectedStatus, rr.Body)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			var resp embedResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Model != tt.expectedModel {
+				t.Errorf("expected model %q, got %q", tt.expectedModel, resp.Model)
+			}
+		})
+	}
+}
+
+func TestCreateDocumentErrors(t *testing.T) {
+	srv := ollamatest.NewServer()
+	defer srv.Close()
+
+	tests := []struct {
+		name           string
+		indexPath      string
+		text           string
+		fail           bool
+		expectedStatus int
+	}{
+		{name: "Blank text", text: "   ", expectedStatus: http.StatusBadRequest},
+		{name: "Embedding failure", text: "Paris is the capital of France.", fail: true, expectedStatus: http.StatusBadGateway},
+		{name: "Index failure", indexPath: filepath.Join(t.TempDir(), "missing", "index.json"), text: "Paris is the capital of France.", expectedStatus: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index, err := rag.Open(tt.indexPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			llm := newOllamaBackend(t, srv)
+			api := newTestAPI(t, WithBackend(llm), WithRetriever(rag.NewRetriever(index, llm, "nomic-embed-text", rag.Chunker{Size: 50})))
+			srv.Reset()
+			if tt.fail {
+				srv.FailNext(1, http.StatusInternalServerError, "out of memory")
+			}
+
+			body, _ := json.Marshal(documentRequest{Text: tt.text})
+			rr := httptest.NewRecorder()
+			api.ServeHTTP(rr, httptest.NewRequest("POST", "/documents", strings.NewReader(string(body))))
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v: %s", rr.Code, tt.expectedStatus, rr.Body)
+			}
+		})
+	}
+}
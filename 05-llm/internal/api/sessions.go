@@ -0,0 +1,127 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/errs"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/models"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/session"
+)
+
+type createSessionRequest struct {
+	Model  string `json:"model"`
+	System string `json:"system"`
+}
+
+type sessionMessageRequest struct {
+	Content string `json:"content"`
+}
+
+type sessionMessageResponse struct {
+	Message backend.Message `json:"message"`
+	Model   string          `json:"model"`
+	Usage   backend.Usage   `json:"usage"`
+	// Context is set when the history was fitted into the model context.
+	Context *session.Fit `json:"context,omitempty"`
+}
+
+func (api *API) createSessionHandler(w http.ResponseWriter, r *http.Request) {
+	var body createSessionRequest
+	err := decodeJSON(w, r, &body)
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+
+	if body.Model == "" {
+		body.Model = r.Header.Get(ModelHeader)
+	}
+	model, err := api.router.Resolve(body.Model, "", r.Header)
+	if errors.Is(err, models.ErrNotAllowed) {
+		api.WriteError(w, r, errs.NewErrBadRequest(err.Error()))
+		return
+	}
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+
+	sess := api.sessions.Create(model, body.System)
+
+	w.Header().Set(ModelHeader, model)
+	w.WriteHeader(http.StatusCreated)
+	api.WriteJSON(w, r, sess)
+}
+
+func (api *API) getSessionHandler(w http.ResponseWriter, r *http.Request) {
+	sess, err := api.sessions.Get(r.PathValue("id"))
+	if err != nil {
+		api.WriteError(w, r, sessionError(err))
+		return
+	}
+
+	api.WriteJSON(w, r, sess)
+}
+
+func (api *API) deleteSessionHandler(w http.ResponseWriter, r *http.Request) {
+	err := api.sessions.Delete(r.PathValue("id"))
+	if err != nil {
+		api.WriteError(w, r, sessionError(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (api *API) sessionMessageHandler(w http.ResponseWriter, r *http.Request) {
+	var body sessionMessageRequest
+	err := decodeJSON(w, r, &body)
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	if body.Content == "" {
+		api.WriteError(w, r, errs.NewErrBadRequest("message content is empty"))
+		return
+	}
+
+	var result sessionMessageResponse
+	err = api.sessions.Update(r.PathValue("id"), func(sess *session.Session) error {
+		sess.Messages = append(sess.Messages, backend.Message{Role: "user", Content: body.Content})
+
+		messages, fit, err := api.window.Messages(r.Context(), sess)
+		if err == nil {
+			var resp backend.Response
+			resp, err = api.backend.Chat(r.Context(), backend.ChatRequest{Model: sess.Model, Messages: messages})
+			result = sessionMessageResponse{
+				Message: backend.Message{Role: "assistant", Content: resp.Content},
+				Model:   sess.Model,
+				Usage:   resp.Usage,
+				Context: fit,
+			}
+		}
+		if err != nil {
+			sess.Messages = sess.Messages[:len(sess.Messages)-1]
+			return backendError(err)
+		}
+
+		sess.Messages = append(sess.Messages, result.Message)
+		return nil
+	})
+	if err != nil {
+		api.WriteError(w, r, sessionError(err))
+		return
+	}
+
+	w.Header().Set(ModelHeader, result.Model)
+	api.WriteJSON(w, r, result)
+}
+
+func sessionError(err error) error {
+	if errors.Is(err, session.ErrNotFound) {
+		return errs.NewErrNotFound(err.Error())
+	}
+	return err
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/session"
+)
+
+func TestSessionLifecycle(t *testing.T) {
+	api := newTestAPI(t)
+
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		api.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do("POST", "/sessions", `{"model":"fast","system":"Be brief."}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create returned %d: %s", rr.Code, rr.Body)
+	}
+	var sess session.Session
+	if err := json.Unmarshal(rr.Body.Bytes(), &sess); err != nil {
+		t.Fatal(err)
+	}
+	if sess.Model != "llama3.2:1b" {
+		t.Errorf("unexpected session model %q", sess.Model)
+	}
+
+	for _, content := range []string{"first", "second"} {
+		rr = do("POST", "/sessions/"+sess.ID+"/messages", `{"content":"`+content+`"}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("message returned %d: %s", rr.Code, rr.Body)
+		}
+	}
+
+	rr = do("GET", "/sessions/"+sess.ID, "")
+	if err := json.Unmarshal(rr.Body.Bytes(), &sess); err != nil {
+		t.Fatal(err)
+	}
+	if len(sess.Messages) != 4 {
+		t.Fatalf("expected 4 messages in history, got %+v", sess.Messages)
+	}
+	if sess.Messages[3].Content != "echo: second" {
+		t.Errorf("unexpected last message %+v", sess.Messages[3])
+	}
+
+	if rr = do("DELETE", "/sessions/"+sess.ID, ""); rr.Code != http.StatusNoContent {
+		t.Errorf("delete returned %d", rr.Code)
+	}
+	if rr = do("GET", "/sessions/"+sess.ID, ""); rr.Code != http.StatusNotFound {
+		t.Errorf("get after delete returned %d", rr.Code)
+	}
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/errs"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/models"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/structured"
+)
+
+const defaultStructuredRetries = 2
+
+type structuredRequest struct {
+	Prompt     string          `json:"prompt"`
+	System     string          `json:"system"`
+	Model      string          `json:"model"`
+	Schema     json.RawMessage `json:"schema"`
+	MaxRetries *int            `json:"maxRetries"`
+	Options    backend.Options `json:"options"`
+}
+
+type structuredResponse struct {
+	Data     json.RawMessage `json:"data"`
+	Model    string          `json:"model"`
+	Attempts int             `json:"attempts"`
+	Usage    backend.Usage   `json:"usage"`
+}
+
+func (api *API) structuredHandler(w http.ResponseWriter, r *http.Request) {
+	var body structuredRequest
+	err := decodeJSON(w, r, &body)
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	if body.Prompt == "" {
+		api.WriteError(w, r, errs.NewErrBadRequest("prompt is empty"))
+		return
+	}
+	if len(body.Schema) == 0 {
+		api.WriteError(w, r, errs.NewErrBadRequest("schema is empty"))
+		return
+	}
+
+	schema, err := structured.Compile(body.Schema)
+	if err != nil {
+		api.WriteError(w, r, errs.NewErrBadRequest(err.Error()))
+		return
+	}
+
+	if body.Model == "" {
+		body.Model = r.Header.Get(ModelHeader)
+	}
+	model, err := api.router.Resolve(body.Model, body.Prompt, r.Header)
+	if errors.Is(err, models.ErrNotAllowed) {
+		err = errs.NewErrBadRequest(err.Error())
+	}
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+
+	retries := -1
+	if body.MaxRetries != nil {
+		retries = *body.MaxRetries
+	}
+
+	req := backend.ChatFromGenerate(backend.GenerateRequest{
+		Model:   model,
+		System:  body.System,
+		Prompt:  body.Prompt,
+		Options: body.Options,
+	})
+	err = api.window.Check(r.Context(), model, req.Messages)
+	if err != nil {
+		api.WriteError(w, r, errs.NewErrBadRequest(err.Error()))
+		return
+	}
+	result, err := api.structured.Generate(r.Context(), req, schema, retries)
+	var invalid *structured.ValidationError
+	if errors.As(err, &invalid) {
+		api.WriteError(w, r, errs.NewErrUnprocessable(invalid.Error(), invalid))
+		return
+	}
+	if err != nil {
+		api.WriteError(w, r, backendError(err))
+		return
+	}
+
+	w.Header().Set(ModelHeader, model)
+	api.WriteJSON(w, r, structuredResponse{
+		Data:     result.Data,
+		Model:    model,
+		Attempts: result.Attempts,
+		Usage:    result.Usage,
+	})
+}
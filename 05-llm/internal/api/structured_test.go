@@ -0,0 +1,59 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+)
+
+func TestStructuredHandler(t *testing.T) {
+	fake := backend.NewFake()
+	fake.SetReply("Ann, 30", `{"name":"Ann","age":30}`)
+	api := newTestAPI(t, WithBackend(fake), WithStructuredRetries(1))
+
+	schema := `{"type":"object","properties":{"age":{"type":"integer"}},"required":["age"]}`
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{name: "Valid output", body: `{"prompt":"Ann, 30","schema":` + schema + `}`, expectedStatus: http.StatusOK},
+		{name: "Invalid output", body: `{"prompt":"Bob","schema":` + schema + `}`, expectedStatus: http.StatusUnprocessableEntity},
+		{name: "Missing schema", body: `{"prompt":"Ann, 30"}`, expectedStatus: http.StatusBadRequest},
+		{name: "Invalid schema", body: `{"prompt":"Ann, 30","schema":{"type":5}}`, expectedStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/structured", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			api.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v: %s", status, tt.expectedStatus, rr.Body)
+			}
+		})
+	}
+
+	req := httptest.NewRequest("POST", "/structured", strings.NewReader(`{"prompt":"Bob","schema":`+schema+`}`))
+	rr := httptest.NewRecorder()
+	api.ServeHTTP(rr, req)
+
+	var resp struct {
+		Details struct {
+			Attempts   int `json:"attempts"`
+			Violations []struct {
+				Path string `json:"path"`
+			} `json:"violations"`
+		} `json:"details"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Details.Attempts != 2 || len(resp.Details.Violations) == 0 {
+		t.Errorf("expected validation details after 2 attempts, got %s", rr.Body)
+	}
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/errs"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/models"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/templates"
+)
+
+type templateResponse struct {
+	templates.Info
+	Template string `json:"template"`
+}
+
+type putTemplateRequest struct {
+	Template string `json:"template"`
+}
+
+type rollbackRequest struct {
+	Version int `json:"version"`
+}
+
+type runTemplateRequest struct {
+	Variables map[string]any  `json:"variables"`
+	Version   int             `json:"version"`
+	Model     string          `json:"model"`
+	Options   backend.Options `json:"options"`
+}
+
+type runTemplateResponse struct {
+	Response string        `json:"response"`
+	Model    string        `json:"model"`
+	Template string        `json:"template"`
+	Version  int           `json:"version"`
+	Usage    backend.Usage `json:"usage"`
+}
+
+// templateError converts template library errors into API errors. Failures
+// of the templates directory are server errors.
+func templateError(err error) error {
+	switch {
+	case errors.Is(err, templates.ErrNotFound):
+		return errs.NewErrNotFound(err.Error())
+	case errors.Is(err, templates.ErrInvalid), errors.Is(err, templates.ErrRender):
+		return errs.NewErrBadRequest(err.Error())
+	}
+	return err
+}
+
+func (api *API) listTemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	api.WriteJSON(w, r, api.templates.List())
+}
+
+func (api *API) getTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	info, err := api.templates.Get(name)
+	if err != nil {
+		api.WriteError(w, r, templateError(err))
+		return
+	}
+	text, err := api.templates.Text(name, 0)
+	if err != nil {
+		api.WriteError(w, r, templateError(err))
+		return
+	}
+
+	api.WriteJSON(w, r, templateResponse{Info: info, Template: text})
+}
+
+func (api *API) putTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	var body putTemplateRequest
+	err := decodeJSON(w, r, &body)
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	if body.Template == "" {
+		api.WriteError(w, r, errs.NewErrBadRequest("template is empty"))
+		return
+	}
+
+	info, err := api.templates.Add(r.PathValue("name"), body.Template)
+	if err != nil {
+		api.WriteError(w, r, templateError(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	api.WriteJSON(w, r, templateResponse{Info: info, Template: body.Template})
+}
+
+func (api *API) rollbackTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	var body rollbackRequest
+	err := decodeJSON(w, r, &body)
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+
+	info, err := api.templates.Rollback(r.PathValue("name"), body.Version)
+	if err != nil {
+		api.WriteError(w, r, templateError(err))
+		return
+	}
+
+	api.WriteJSON(w, r, info)
+}
+
+func (api *API) runTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	var body runTemplateRequest
+	err := decodeJSON(w, r, &body)
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+
+	prompt, err := api.templates.Render(r.PathValue("name"), body.Version, body.Variables)
+	if err != nil {
+		api.WriteError(w, r, templateError(err))
+		return
+	}
+	if prompt.User == "" {
+		api.WriteError(w, r, errs.NewErrBadRequest("rendered prompt is empty"))
+		return
+	}
+
+	if body.Model == "" {
+		body.Model = r.Header.Get(ModelHeader)
+	}
+	model, err := api.router.Resolve(body.Model, prompt.User, r.Header)
+	if errors.Is(err, models.ErrNotAllowed) {
+		err = errs.NewErrBadRequest(err.Error())
+	}
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+
+	req := backend.GenerateRequest{
+		Model:   model,
+		System:  prompt.System,
+		Prompt:  prompt.User,
+		Options: body.Options,
+	}
+	err = api.window.Check(r.Context(), model, backend.ChatFromGenerate(req).Messages)
+	if err != nil {
+		api.WriteError(w, r, errs.NewErrBadRequest(err.Error()))
+		return
+	}
+
+	resp, err := api.backend.Generate(r.Context(), req)
+	if err != nil {
+		api.WriteError(w, r, backendError(err))
+		return
+	}
+
+	w.Header().Set(ModelHeader, model)
+	api.WriteJSON(w, r, runTemplateResponse{
+		Response: resp.Content,
+		Model:    model,
+		Template: prompt.Name,
+		Version:  prompt.Version,
+		Usage:    resp.Usage,
+	})
+}
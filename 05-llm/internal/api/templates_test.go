@@ -0,0 +1,120 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/templates"
+)
+
+func TestTemplates(t *testing.T) {
+	lib, err := templates.Load(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := newTestAPI(t, WithTemplates(lib))
+
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		api.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for _, text := range []string{"Hello, {{.name}}!", "Hi, {{.name}}!"} {
+		body, _ := json.Marshal(putTemplateRequest{Template: text})
+		if rr := do("PUT", "/templates/greet", string(body)); rr.Code != http.StatusCreated {
+			t.Fatalf("put returned %d: %s", rr.Code, rr.Body)
+		}
+	}
+
+	tests := []struct {
+		name             string
+		url              string
+		body             string
+		expectedStatus   int
+		expectedResponse string
+	}{
+		{name: "Active version", url: "/templates/greet/run", body: `{"variables":{"name":"Ann"}}`, expectedStatus: http.StatusOK, expectedResponse: "echo: Hi, Ann!"},
+		{name: "Old version", url: "/templates/greet/run", body: `{"variables":{"name":"Ann"},"version":1}`, expectedStatus: http.StatusOK, expectedResponse: "echo: Hello, Ann!"},
+		{name: "Missing variable", url: "/templates/greet/run", body: `{"variables":{}}`, expectedStatus: http.StatusBadRequest},
+		{name: "Unknown template", url: "/templates/other/run", body: `{}`, expectedStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := do("POST", tt.url, tt.body)
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v: %s", status, tt.expectedStatus, rr.Body)
+			}
+
+			var resp runTemplateResponse
+			json.Unmarshal(rr.Body.Bytes(), &resp)
+			if resp.Response != tt.expectedResponse {
+				t.Errorf("handler returned unexpected response: got %q want %q", resp.Response, tt.expectedResponse)
+			}
+		})
+	}
+
+	if rr := do("POST", "/templates/greet/rollback", ""); rr.Code != http.StatusOK {
+		t.Fatalf("rollback returned %d: %s", rr.Code, rr.Body)
+	}
+	var tmpl templateResponse
+	json.Unmarshal(do("GET", "/templates/greet", "").Body.Bytes(), &tmpl)
+	if tmpl.Active != 1 || tmpl.Template != "Hello, {{.name}}!" {
+		t.Errorf("expected version 1 to be active after rollback, got %+v", tmpl)
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	dir := t.TempDir()
+	lib, err := templates.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := newTestAPI(t, WithTemplates(lib))
+
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		body           string
+		setup          func(t *testing.T)
+		expectedStatus int
+	}{
+		{name: "Unparsable template", method: "PUT", url: "/templates/greet", body: `{"template":"{{.name"}`, expectedStatus: http.StatusBadRequest},
+		{name: "Invalid name", method: "PUT", url: "/templates/gr%20eet", body: `{"template":"Hi"}`, expectedStatus: http.StatusBadRequest},
+		{name: "Created", method: "PUT", url: "/templates/greet", body: `{"template":"Hi"}`, expectedStatus: http.StatusCreated},
+		{name: "No previous version", method: "POST", url: "/templates/greet/rollback", expectedStatus: http.StatusBadRequest},
+		{
+			name:   "Directory failure",
+			method: "PUT",
+			url:    "/templates/other",
+			body:   `{"template":"Hi"}`,
+			setup: func(t *testing.T) {
+				if err := os.RemoveAll(dir); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(dir, nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.setup != nil {
+				tt.setup(t)
+			}
+			rr := httptest.NewRecorder()
+			api.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body)))
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v: %s", rr.Code, tt.expectedStatus, rr.Body)
+			}
+		})
+	}
+}
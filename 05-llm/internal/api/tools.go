@@ -0,0 +1,100 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/errs"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/models"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/tools"
+)
+
+type toolChatRequest struct {
+	Prompt  string          `json:"prompt"`
+	System  string          `json:"system"`
+	Model   string          `json:"model"`
+	Tools   []string        `json:"tools"`
+	Options backend.Options `json:"options"`
+}
+
+type toolChatResponse struct {
+	Message    backend.Message `json:"message"`
+	Model      string          `json:"model"`
+	ToolCalls  []tools.Call    `json:"toolCalls"`
+	Iterations int             `json:"iterations"`
+	Usage      backend.Usage   `json:"usage"`
+}
+
+func (api *API) listToolsHandler(w http.ResponseWriter, r *http.Request) {
+	defs, err := api.tools.Registry().Definitions()
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+
+	api.WriteJSON(w, r, defs)
+}
+
+func (api *API) toolChatHandler(w http.ResponseWriter, r *http.Request) {
+	var body toolChatRequest
+	err := decodeJSON(w, r, &body)
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	if body.Prompt == "" {
+		api.WriteError(w, r, errs.NewErrBadRequest("prompt is empty"))
+		return
+	}
+
+	if body.Model == "" {
+		body.Model = r.Header.Get(ModelHeader)
+	}
+	model, err := api.router.Resolve(body.Model, body.Prompt, r.Header)
+	if errors.Is(err, models.ErrNotAllowed) {
+		err = errs.NewErrBadRequest(err.Error())
+	}
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+
+	req := backend.ChatFromGenerate(backend.GenerateRequest{
+		Model:   model,
+		System:  body.System,
+		Prompt:  body.Prompt,
+		Options: body.Options,
+	})
+	err = api.window.Check(r.Context(), model, req.Messages)
+	if err != nil {
+		api.WriteError(w, r, errs.NewErrBadRequest(err.Error()))
+		return
+	}
+	result, err := api.tools.Run(r.Context(), req, body.Tools...)
+	switch {
+	case errors.Is(err, tools.ErrUnknownTool):
+		api.WriteError(w, r, errs.NewErrBadRequest(err.Error()))
+		return
+	case errors.Is(err, tools.ErrMaxIterations):
+		api.WriteError(w, r, errs.NewErrUnprocessable(err.Error(), result.Calls))
+		return
+	case err != nil:
+		api.WriteError(w, r, backendError(err))
+		return
+	}
+
+	calls := result.Calls
+	if calls == nil {
+		calls = []tools.Call{}
+	}
+
+	w.Header().Set(ModelHeader, model)
+	api.WriteJSON(w, r, toolChatResponse{
+		Message:    result.Message,
+		Model:      model,
+		ToolCalls:  calls,
+		Iterations: result.Iterations,
+		Usage:      result.Usage,
+	})
+}
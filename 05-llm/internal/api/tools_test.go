@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/tools"
+)
+
+// toolCaller asks for the current time once and then answers with the tool result.
+type toolCaller struct {
+	*backend.Fake
+}
+
+func (b toolCaller) Chat(ctx context.Context, req backend.ChatRequest) (backend.Response, error) {
+	last := req.Messages[len(req.Messages)-1]
+	if last.Role == "tool" {
+		return backend.Response{Model: req.Model, Content: "It is " + last.Content}, nil
+	}
+	return backend.Response{Model: req.Model, ToolCalls: []backend.ToolCall{{
+		Function: backend.ToolCallFunction{Name: "current_time", Arguments: json.RawMessage(`{}`)},
+	}}}, nil
+}
+
+func TestToolChatHandler(t *testing.T) {
+	registry := tools.NewRegistry()
+	now := func() time.Time { return time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC) }
+	if err := registry.Register(tools.CurrentTime(now)); err != nil {
+		t.Fatal(err)
+	}
+	b := toolCaller{Fake: backend.NewFake()}
+	api := newTestAPI(t, WithBackend(b), WithTools(tools.NewRunner(b, registry, 3)))
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedAnswer string
+	}{
+		{name: "Tool call", body: `{"prompt":"What time is it?"}`, expectedStatus: http.StatusOK, expectedAnswer: "It is 2025-03-01T12:00:00Z"},
+		{name: "Unknown tool", body: `{"prompt":"What time is it?","tools":["shell"]}`, expectedStatus: http.StatusBadRequest},
+		{name: "Empty prompt", body: `{}`, expectedStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/tools/chat", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			api.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v: %s", status, tt.expectedStatus, rr.Body)
+			}
+			if tt.expectedAnswer == "" {
+				return
+			}
+
+			var resp toolChatResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Message.Content != tt.expectedAnswer || len(resp.ToolCalls) != 1 || resp.Iterations != 2 {
+				t.Errorf("unexpected response %s", rr.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"slices"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/errs"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/models"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/vision"
+)
+
+const (
+	maxImages = 4
+	// multipartMemory is the part of the form kept in memory, the rest
+	// goes to temporary files.
+	multipartMemory = 8 << 20
+)
+
+type imageInfo struct {
+	Width   int  `json:"width"`
+	Height  int  `json:"height"`
+	Resized bool `json:"resized"`
+}
+
+type visionResponse struct {
+	Response string        `json:"response"`
+	Model    string        `json:"model"`
+	Images   []imageInfo   `json:"images"`
+	Usage    backend.Usage `json:"usage"`
+}
+
+// visionHandler answers a prompt about uploaded images. The multipart form
+// has the prompt, optional system and model fields, generation options as
+// in the prompt endpoint and up to maxImages files in image fields.
+func (api *API) visionHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImages*api.vision.Limit()+maxBodySize)
+	err := r.ParseMultipartForm(multipartMemory)
+	if err != nil {
+		api.WriteError(w, r, formError(err))
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	prompt := r.FormValue("prompt")
+	if prompt == "" {
+		api.WriteError(w, r, errs.NewErrBadRequest("prompt is empty"))
+		return
+	}
+	files := r.MultipartForm.File["image"]
+	if len(files) == 0 {
+		api.WriteError(w, r, errs.NewErrBadRequest("image is missing"))
+		return
+	}
+	if len(files) > maxImages {
+		api.WriteError(w, r, errs.NewErrBadRequest(fmt.Sprintf("at most %d images are allowed", maxImages)))
+		return
+	}
+
+	options, err := parseOptions(r.Form)
+	if err != nil {
+		api.WriteError(w, r, errs.NewErrBadRequest(err.Error()))
+		return
+	}
+
+	requested := r.FormValue("model")
+	if requested == "" {
+		requested = r.Header.Get(ModelHeader)
+	}
+	model, err := api.router.ResolveVision(requested, prompt, r.Header)
+	if errors.Is(err, models.ErrNotAllowed) || errors.Is(err, models.ErrNoVision) {
+		err = errs.NewErrBadRequest(err.Error())
+	}
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+
+	var images []string
+	var infos []imageInfo
+	for _, fh := range files {
+		img, err := api.readImage(fh)
+		if err != nil {
+			api.WriteError(w, r, err)
+			return
+		}
+		images = append(images, img.Base64())
+		infos = append(infos, imageInfo{Width: img.Width, Height: img.Height, Resized: img.Resized})
+	}
+
+	resp, err := api.backend.Generate(r.Context(), backend.GenerateRequest{
+		Model:   model,
+		System:  r.FormValue("system"),
+		Prompt:  prompt,
+		Images:  images,
+		Options: options,
+	})
+	if err != nil {
+		api.WriteError(w, r, backendError(err))
+		return
+	}
+
+	w.Header().Set(ModelHeader, model)
+	api.WriteJSON(w, r, visionResponse{
+		Response: resp.Content,
+		Model:    model,
+		Images:   infos,
+		Usage:    resp.Usage,
+	})
+}
+
+// readImage checks the declared type and the size of the uploaded file
+// before vision.Config.Prepare validates its content.
+func (api *API) readImage(fh *multipart.FileHeader) (vision.Image, error) {
+	declared, _, _ := mime.ParseMediaType(fh.Header.Get("Content-Type"))
+	if declared != "" && declared != "application/octet-stream" && !slices.Contains(vision.Types, declared) {
+		return vision.Image{}, errs.NewErrUnsupportedMediaType(fmt.Sprintf("%s: unsupported image type %s", fh.Filename, declared))
+	}
+	if fh.Size > api.vision.Limit() {
+		return vision.Image{}, errs.NewErrTooLarge(fmt.Sprintf("%s: image is larger than %d bytes", fh.Filename, api.vision.Limit()))
+	}
+
+	f, err := fh.Open()
+	if err != nil {
+		return vision.Image{}, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return vision.Image{}, err
+	}
+
+	img, err := api.vision.Prepare(data)
+	switch {
+	case errors.Is(err, vision.ErrTooLarge):
+		return vision.Image{}, errs.NewErrTooLarge(fh.Filename + ": " + err.Error())
+	case errors.Is(err, vision.ErrUnsupported):
+		return vision.Image{}, errs.NewErrUnsupportedMediaType(fh.Filename + ": " + err.Error())
+	case errors.Is(err, vision.ErrInvalid):
+		return vision.Image{}, errs.NewErrBadRequest(fh.Filename + ": " + err.Error())
+	}
+	return img, err
+}
+
+func formError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return errs.NewErrTooLarge(fmt.Sprintf("request body is larger than %d bytes", tooLarge.Limit))
+	}
+	if errors.Is(err, http.ErrNotMultipart) {
+		return errs.NewErrUnsupportedMediaType("expected a multipart/form-data request")
+	}
+	return errs.NewErrBadRequest(fmt.Sprintf("invalid form: %v", err))
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/models"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/ollamatest"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/vision"
+)
+
+type upload struct {
+	contentType string
+	data        []byte
+}
+
+func pngImage(t *testing.T, w, h int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func newVisionRequest(t *testing.T, fields map[string]string, uploads ...upload) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for k, v := range fields {
+		if err := mw.WriteField(k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, u := range uploads {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", `form-data; name="image"; filename="image"`)
+		header.Set("Content-Type", u.contentType)
+		part, err := mw.CreatePart(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(u.data)
+	}
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/vision", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func newVisionAPI(t *testing.T, srv *ollamatest.Server) *API {
+	t.Helper()
+
+	router, err := models.NewRouter(models.Config{
+		Default: "llama3.2",
+		Allowed: []string{"llama3.2", "llava"},
+		Vision:  []string{"llava"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newTestAPI(t,
+		WithRouter(router),
+		WithBackend(newOllamaBackend(t, srv)),
+		WithVision(vision.Config{MaxBytes: 4 << 10, MaxDimension: 64}),
+	)
+}
+
+func TestVisionHandler(t *testing.T) {
+	srv := ollamatest.NewServer("llama3.2", "llava")
+	defer srv.Close()
+	api := newVisionAPI(t, srv)
+
+	small := pngImage(t, 32, 16)
+	large := pngImage(t, 128, 32)
+	tests := []struct {
+		name           string
+		fields         map[string]string
+		uploads        []upload
+		expectedStatus int
+		expectedImages []imageInfo
+	}{
+		{
+			name:           "Image passed as is",
+			fields:         map[string]string{"prompt": "what is it?", "temperature": "0.2"},
+			uploads:        []upload{{"image/png", small}},
+			expectedStatus: http.StatusOK,
+			expectedImages: []imageInfo{{Width: 32, Height: 16}},
+		},
+		{
+			name:           "Image downscaled",
+			fields:         map[string]string{"prompt": "compare", "model": "llava", "system": "Be brief."},
+			uploads:        []upload{{"application/octet-stream", large}, {"image/png", small}},
+			expectedStatus: http.StatusOK,
+			expectedImages: []imageInfo{{Width: 64, Height: 16, Resized: true}, {Width: 32, Height: 16}},
+		},
+		{
+			name:           "Empty prompt",
+			uploads:        []upload{{"image/png", small}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Missing image",
+			fields:         map[string]string{"prompt": "what is it?"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Text model",
+			fields:         map[string]string{"prompt": "what is it?", "model": "llama3.2"},
+			uploads:        []upload{{"image/png", small}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Declared type not supported",
+			fields:         map[string]string{"prompt": "what is it?"},
+			uploads:        []upload{{"image/tiff", small}},
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:           "Content not an image",
+			fields:         map[string]string{"prompt": "what is it?"},
+			uploads:        []upload{{"image/png", []byte("<html>hello</html>")}},
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:           "Image too large",
+			fields:         map[string]string{"prompt": "what is it?"},
+			uploads:        []upload{{"image/png", make([]byte, 5<<10)}},
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:           "Too many images",
+			fields:         map[string]string{"prompt": "what is it?"},
+			uploads:        []upload{{"image/png", small}, {"image/png", small}, {"image/png", small}, {"image/png", small}, {"image/png", small}},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv.Reset()
+			rr := httptest.NewRecorder()
+			api.ServeHTTP(rr, newVisionRequest(t, tt.fields, tt.uploads...))
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if rr.Code != http.StatusOK {
+				if len(srv.Requests()) != 0 {
+					t.Error("a rejected request reached the llm server")
+				}
+				return
+			}
+
+			var resp visionResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Model != "llava" || resp.Response != "echo: "+tt.fields["prompt"] {
+				t.Errorf("unexpected response %+v", resp)
+			}
+			if len(resp.Images) != len(tt.expectedImages) {
+				t.Fatalf("expected %d images, got %+v", len(tt.expectedImages), resp.Images)
+			}
+			for i, info := range resp.Images {
+				if info != tt.expectedImages[i] {
+					t.Errorf("image %d: got %+v, want %+v", i, info, tt.expectedImages[i])
+				}
+			}
+
+			got := srv.Requests()[0]
+			if got.Model != "llava" || got.System != tt.fields["system"] || len(got.Images) != len(tt.uploads) {
+				t.Fatalf("unexpected llm request %+v", got)
+			}
+			data, err := base64.StdEncoding.DecodeString(got.Images[len(got.Images)-1])
+			if err != nil || !bytes.Equal(data, small) {
+				t.Errorf("the last image was not forwarded unchanged: %v", err)
+			}
+		})
+	}
+}
+
+func TestVisionHandlerNotMultipart(t *testing.T) {
+	srv := ollamatest.NewServer()
+	defer srv.Close()
+
+	rr := serve(newVisionAPI(t, srv), http.MethodPost, "/vision", `{"prompt":"hi"}`)
+	if rr.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected status %d, got %d", http.StatusUnsupportedMediaType, rr.Code)
+	}
+}
@@ -0,0 +1,313 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/errs"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/models"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/session"
+)
+
+const (
+	// Message types sent by the client.
+	wsTypeMessage = "message"
+	wsTypeStop    = "stop"
+	// Message types sent by the server.
+	wsTypeToken = "token"
+	wsTypeDone  = "done"
+	wsTypeError = "error"
+
+	wsWriteWait      = 10 * time.Second
+	wsPongWait       = 60 * time.Second
+	wsPingInterval   = wsPongWait * 9 / 10
+	wsMaxMessageSize = 64 << 10
+)
+
+var errStopped = errors.New("generation stopped by the client")
+
+const shutdownMessage = "server is shutting down"
+
+// wsMessage is a frame of the /ws protocol. The client sends "message" with
+// the content of a user message and "stop" to abort the generation. The
+// server streams "token" frames followed by "done" with the whole reply,
+// or "error" if the message failed.
+type wsMessage struct {
+	Type    string         `json:"type"`
+	Content string         `json:"content,omitempty"`
+	Model   string         `json:"model,omitempty"`
+	Usage   *backend.Usage `json:"usage,omitempty"`
+	// Stopped is set on "done" when the client stopped the generation.
+	Stopped bool `json:"stopped,omitempty"`
+	// Context is set on "done" when the history was fitted into the model context.
+	Context *session.Fit `json:"context,omitempty"`
+	Error   string       `json:"error,omitempty"`
+	Code    int          `json:"code,omitempty"`
+}
+
+var upgrader = websocket.Upgrader{}
+
+// wsHandler serves an interactive chat over WebSocket. The conversation
+// history lives as long as the connection. The model and the system prompt
+// are chosen with the model and system query parameters.
+func (api *API) wsHandler(w http.ResponseWriter, r *http.Request) {
+	model, err := api.resolveModel(r, "")
+	if errors.Is(err, models.ErrNotAllowed) {
+		api.WriteError(w, r, errs.NewErrBadRequest(err.Error()))
+		return
+	}
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+
+	if api.conns.Closing() {
+		api.WriteError(w, r, errs.NewErrUnavailable(shutdownMessage, time.Second))
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already replied with an error.
+		log.Printf("Error upgrading to WebSocket: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	// The connection outlives the request deadline, every generation gets its own.
+	ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+	defer cancel()
+
+	c := &wsConn{
+		api:    api,
+		conn:   conn,
+		sess:   session.Session{Model: model, System: r.URL.Query().Get("system")},
+		cancel: cancel,
+	}
+	if !api.conns.add(c) {
+		c.close()
+		return
+	}
+	defer api.conns.remove(c)
+
+	c.run(ctx)
+	cancel()
+	c.wg.Wait()
+}
+
+type wsConn struct {
+	api  *API
+	conn *websocket.Conn
+	// sess is only accessed by the generation in progress.
+	sess session.Session
+	// cancel aborts the generation in progress and the pings.
+	cancel context.CancelFunc
+
+	writeMu sync.Mutex
+
+	mu sync.Mutex
+	// stop cancels the generation in progress; it is nil when the connection is idle.
+	stop context.CancelCauseFunc
+	// draining is set on shutdown; the connection is closed once it is idle.
+	draining bool
+	wg       sync.WaitGroup
+}
+
+// run reads client frames until the connection is closed.
+func (c *wsConn) run(ctx context.Context) {
+	c.conn.SetReadLimit(wsMaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.ping(ctx)
+	}()
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("Error reading WebSocket message: %v", err)
+			}
+			return
+		}
+
+		var msg wsMessage
+		err = json.Unmarshal(data, &msg)
+		if err != nil {
+			c.writeError(errs.NewErrBadRequest("invalid message: " + err.Error()))
+			continue
+		}
+
+		switch msg.Type {
+		case wsTypeMessage:
+			c.start(ctx, msg.Content)
+		case wsTypeStop:
+			c.mu.Lock()
+			if c.stop != nil {
+				c.stop(errStopped)
+			}
+			c.mu.Unlock()
+		default:
+			c.writeError(errs.NewErrBadRequest("unknown message type " + msg.Type))
+		}
+	}
+}
+
+// ping keeps the connection alive; the client's pongs extend the read deadline.
+func (c *wsConn) ping(ctx context.Context) {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+			if err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// start generates the reply to the user message in the background so that
+// the client can stop it. Only one generation runs at a time.
+func (c *wsConn) start(ctx context.Context, content string) {
+	if content == "" {
+		c.writeError(errs.NewErrBadRequest("message content is empty"))
+		return
+	}
+
+	c.mu.Lock()
+	if c.draining {
+		c.mu.Unlock()
+		c.writeError(errs.NewErrUnavailable(shutdownMessage, time.Second))
+		return
+	}
+	if c.stop != nil {
+		c.mu.Unlock()
+		c.writeError(errs.NewErrBadRequest("a reply is already being generated"))
+		return
+	}
+	ctx, stop := context.WithCancelCause(ctx)
+	c.stop = stop
+	c.mu.Unlock()
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+
+		genCtx, cancel := ctx, context.CancelFunc(func() {})
+		if c.api.timeout > 0 {
+			genCtx, cancel = context.WithTimeout(ctx, c.api.timeout)
+		}
+		reply, err := c.generate(genCtx, content)
+		cancel()
+
+		// The connection is idle again before the client learns the outcome.
+		c.mu.Lock()
+		c.stop = nil
+		draining := c.draining
+		c.mu.Unlock()
+		stop(nil)
+
+		if err != nil {
+			c.writeError(err)
+		} else {
+			c.write(reply)
+		}
+		if draining {
+			c.close()
+		}
+	}()
+}
+
+// drain closes the connection as soon as the reply being generated, if any,
+// is sent.
+func (c *wsConn) drain() {
+	c.mu.Lock()
+	c.draining = true
+	idle := c.stop == nil
+	c.mu.Unlock()
+
+	if idle {
+		c.close()
+	}
+}
+
+// close tells the client that the server is going away and closes the
+// connection, which ends the read loop.
+func (c *wsConn) close() {
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, shutdownMessage)
+	c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(wsWriteWait))
+	c.conn.Close()
+}
+
+// kill cancels the generation in progress and closes the connection.
+func (c *wsConn) kill() {
+	c.cancel()
+	c.close()
+}
+
+func (c *wsConn) generate(ctx context.Context, content string) (wsMessage, error) {
+	c.sess.Messages = append(c.sess.Messages, backend.Message{Role: "user", Content: content})
+
+	var reply strings.Builder
+	var resp backend.Response
+	messages, fit, err := c.api.window.Messages(ctx, &c.sess)
+	if err == nil {
+		resp, err = c.api.backend.Stream(ctx, backend.ChatRequest{Model: c.sess.Model, Messages: messages}, func(chunk backend.Chunk) error {
+			reply.WriteString(chunk.Content)
+			return c.write(wsMessage{Type: wsTypeToken, Content: chunk.Content})
+		})
+	}
+
+	stopped := errors.Is(context.Cause(ctx), errStopped)
+	if err != nil && !stopped {
+		c.sess.Messages = c.sess.Messages[:len(c.sess.Messages)-1]
+		return wsMessage{}, backendError(err)
+	}
+
+	// A stopped reply is kept in the history as far as it got.
+	c.sess.Messages = append(c.sess.Messages, backend.Message{Role: "assistant", Content: reply.String()})
+	return wsMessage{
+		Type:    wsTypeDone,
+		Content: reply.String(),
+		Model:   c.sess.Model,
+		Usage:   &resp.Usage,
+		Stopped: stopped,
+		Context: fit,
+	}, nil
+}
+
+func (c *wsConn) write(msg wsMessage) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return c.conn.WriteJSON(msg)
+}
+
+func (c *wsConn) writeError(err error) {
+	msg := wsMessage{Type: wsTypeError, Error: err.Error(), Code: statusCode(err)}
+	log.Printf("Error handling WebSocket message: %v", err)
+	if msg.Code == http.StatusInternalServerError {
+		msg.Error = "Internal Server Error"
+	}
+	c.write(msg)
+}
@@ -0,0 +1,233 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/ollamatest"
+)
+
+func dialWS(t *testing.T, api http.Handler, query string) *websocket.Conn {
+	t.Helper()
+
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+// readReply reads frames until "done" or "error" and returns the streamed tokens and the final frame.
+func readReply(t *testing.T, conn *websocket.Conn) ([]string, wsMessage) {
+	t.Helper()
+
+	var tokens []string
+	for {
+		var msg wsMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Type != wsTypeToken {
+			return tokens, msg
+		}
+		tokens = append(tokens, msg.Content)
+	}
+}
+
+func TestWebSocketChat(t *testing.T) {
+	srv := ollamatest.NewServer()
+	defer srv.Close()
+	conn := dialWS(t, newTestAPI(t, WithBackend(newOllamaBackend(t, srv))), "?model=fast&system=Be+brief.")
+
+	tests := []struct {
+		name            string
+		frame           string
+		fail            bool
+		expectedType    string
+		expectedContent string
+		expectedCode    int
+	}{
+		{name: "First message", frame: `{"type":"message","content":"one two"}`, expectedType: wsTypeDone, expectedContent: "echo: one two"},
+		{name: "Backend failure", frame: `{"type":"message","content":"three"}`, fail: true, expectedType: wsTypeError, expectedCode: http.StatusBadGateway},
+		{name: "Follow-up", frame: `{"type":"message","content":"four"}`, expectedType: wsTypeDone, expectedContent: "echo: four"},
+		{name: "Empty message", frame: `{"type":"message"}`, expectedType: wsTypeError, expectedCode: http.StatusBadRequest},
+		{name: "Unknown type", frame: `{"type":"edit"}`, expectedType: wsTypeError, expectedCode: http.StatusBadRequest},
+		{name: "Invalid JSON", frame: `{`, expectedType: wsTypeError, expectedCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.fail {
+				srv.FailNext(1, http.StatusInternalServerError, "out of memory")
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(tt.frame)); err != nil {
+				t.Fatal(err)
+			}
+
+			tokens, msg := readReply(t, conn)
+			if msg.Type != tt.expectedType || msg.Content != tt.expectedContent || msg.Code != tt.expectedCode {
+				t.Fatalf("unexpected reply %+v", msg)
+			}
+			if msg.Type == wsTypeDone && strings.Join(tokens, "") != msg.Content {
+				t.Errorf("tokens %q do not add up to %q", tokens, msg.Content)
+			}
+		})
+	}
+
+	// The failed message is not part of the history.
+	requests := srv.Requests()
+	expected := []ollamatest.Message{
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: "one two"},
+		{Role: "assistant", Content: "echo: one two"},
+		{Role: "user", Content: "four"},
+	}
+	if got := requests[len(requests)-1]; got.Model != "llama3.2:1b" || !reflect.DeepEqual(got.Messages, expected) {
+		t.Errorf("unexpected llm request %s %+v", got.Model, got.Messages)
+	}
+}
+
+func TestWebSocketStop(t *testing.T) {
+	fake := backend.NewFake()
+	fake.SetDelay(50 * time.Millisecond)
+	conn := dialWS(t, newTestAPI(t, WithBackend(fake)), "")
+
+	err := conn.WriteJSON(wsMessage{Type: wsTypeMessage, Content: "one two three four five six"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var first wsMessage
+	if err := conn.ReadJSON(&first); err != nil || first.Type != wsTypeToken {
+		t.Fatalf("expected a token, got %+v: %v", first, err)
+	}
+
+	// A second message is rejected while the reply is generated.
+	if err := conn.WriteJSON(wsMessage{Type: wsTypeMessage, Content: "again"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.WriteJSON(wsMessage{Type: wsTypeStop}); err != nil {
+		t.Fatal(err)
+	}
+
+	rejected := false
+	var done wsMessage
+	for done.Type != wsTypeDone {
+		var msg wsMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+		switch msg.Type {
+		case wsTypeError:
+			rejected = msg.Code == http.StatusBadRequest
+		case wsTypeDone:
+			done = msg
+		}
+	}
+	if !rejected {
+		t.Error("concurrent message was not rejected")
+	}
+	if !done.Stopped || !strings.HasPrefix(done.Content, first.Content) || done.Content == "echo: one two three four five six" {
+		t.Errorf("expected a partial stopped reply, got %+v", done)
+	}
+	if fake.Cancelled() != 1 {
+		t.Errorf("backend saw %d cancellations, want 1", fake.Cancelled())
+	}
+
+	// The connection accepts new messages after the stop.
+	fake.SetDelay(0)
+	if err := conn.WriteJSON(wsMessage{Type: wsTypeMessage, Content: "next"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, msg := readReply(t, conn); msg.Type != wsTypeDone || msg.Content != "echo: next" {
+		t.Errorf("unexpected reply %+v", msg)
+	}
+}
+
+func TestWebSocketModelNotAllowed(t *testing.T) {
+	srv := httptest.NewServer(newTestAPI(t))
+	defer srv.Close()
+
+	_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws?model=gpt-4", nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected the handshake to fail with 400, got %v", err)
+	}
+}
+
+func TestWebSocketShutdown(t *testing.T) {
+	fake := backend.NewFake()
+	fake.SetDelay(20 * time.Millisecond)
+	conns := NewConnections()
+	api := newTestAPI(t, WithBackend(fake), WithConnections(conns))
+	idle := dialWS(t, api, "")
+	busy := dialWS(t, api, "")
+
+	if err := busy.WriteJSON(wsMessage{Type: wsTypeMessage, Content: "one two three"}); err != nil {
+		t.Fatal(err)
+	}
+	var first wsMessage
+	if err := busy.ReadJSON(&first); err != nil || first.Type != wsTypeToken {
+		t.Fatalf("expected a token, got %+v: %v", first, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- conns.Shutdown(ctx) }()
+
+	if _, _, err := idle.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("idle connection was not closed as going away: %v", err)
+	}
+	// The reply in progress is finished before the connection is closed.
+	if _, msg := readReply(t, busy); msg.Type != wsTypeDone || msg.Content != "echo: one two three" {
+		t.Errorf("unexpected reply %+v", msg)
+	}
+	if _, _, err := busy.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("busy connection was not closed as going away: %v", err)
+	}
+	if err := <-shutdown; err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+	_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected the handshake to fail with 503, got %v", err)
+	}
+}
+
+func TestWebSocketShutdownDeadline(t *testing.T) {
+	fake := backend.NewFake()
+	fake.SetDelay(time.Second)
+	conns := NewConnections()
+	conn := dialWS(t, newTestAPI(t, WithBackend(fake), WithConnections(conns)), "")
+
+	if err := conn.WriteJSON(wsMessage{Type: wsTypeMessage, Content: "one two three"}); err != nil {
+		t.Fatal(err)
+	}
+	// The generation has started once the handler waits for the backend.
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := conns.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to be exceeded, got %v", err)
+	}
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("connection was not closed as going away: %v", err)
+	}
+}
@@ -0,0 +1,250 @@
+// Package audit records every exchange with the llm server to rotated
+// JSONL files and reads the records back for replay.
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+)
+
+const (
+	filePrefix  = "audit"
+	fileExt     = ".jsonl"
+	currentFile = filePrefix + fileExt
+	timeLayout  = "20060102T150405.000000000"
+)
+
+var ErrNotFound = errors.New("audit record not found")
+
+// Record is a single generation made by the backend.
+type Record struct {
+	ID        string    `json:"id"`
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"requestId,omitempty"`
+	Client    string    `json:"client,omitempty"`
+	// Operation is generate, chat or stream.
+	Operation string            `json:"operation"`
+	Model     string            `json:"model"`
+	Messages  []backend.Message `json:"messages"`
+	Options   backend.Options   `json:"options"`
+	Format    json.RawMessage   `json:"format,omitempty"`
+	// Redacted is set when personal data was removed from the messages.
+	Redacted  bool          `json:"redacted,omitempty"`
+	Response  string        `json:"response"`
+	Usage     backend.Usage `json:"usage"`
+	LatencyMS int64         `json:"latencyMs"`
+	Error     string        `json:"error,omitempty"`
+}
+
+type Config struct {
+	Dir string
+	// MaxBytes is the size after which the current file is rotated; 0 disables rotation.
+	MaxBytes int64
+	// MaxFiles is the number of rotated files kept; 0 keeps all of them.
+	MaxFiles int
+	// Redact, if set, is applied to the message contents before they are written.
+	Redact func(string) string
+}
+
+// Logger appends records to <dir>/audit.jsonl. When the file grows over
+// MaxBytes it is renamed to audit-<time>.jsonl and a new file is started.
+type Logger struct {
+	mu   sync.Mutex
+	cfg  Config
+	file *os.File
+	size int64
+	now  func() time.Time
+}
+
+func Open(cfg Config) (*Logger, error) {
+	err := os.MkdirAll(cfg.Dir, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create audit directory: %w", err)
+	}
+
+	l := &Logger{cfg: cfg, now: time.Now}
+	err = l.open()
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Logger) open() error {
+	f, err := os.OpenFile(filepath.Join(l.cfg.Dir, currentFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	l.file = f
+	l.size = info.Size()
+	return nil
+}
+
+// Write appends the record, rotating the file if needed.
+func (l *Logger) Write(rec Record) error {
+	if l.cfg.Redact != nil {
+		messages := make([]backend.Message, len(rec.Messages))
+		for i, m := range rec.Messages {
+			m.Content = l.cfg.Redact(m.Content)
+			rec.Redacted = rec.Redacted || m.Content != rec.Messages[i].Content
+			messages[i] = m
+		}
+		rec.Messages = messages
+	}
+
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit record: %w", err)
+	}
+	b = append(b, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.cfg.MaxBytes > 0 && l.size > 0 && l.size+int64(len(b)) > l.cfg.MaxBytes {
+		err = l.rotate()
+		if err != nil {
+			return err
+		}
+	}
+
+	n, err := l.file.Write(b)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	return nil
+}
+
+// rotate must be called with l.mu held.
+func (l *Logger) rotate() error {
+	err := l.file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close audit log: %w", err)
+	}
+
+	rotated := fmt.Sprintf("%s-%s%s", filePrefix, l.now().UTC().Format(timeLayout), fileExt)
+	err = os.Rename(filepath.Join(l.cfg.Dir, currentFile), filepath.Join(l.cfg.Dir, rotated))
+	if err != nil {
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+
+	if l.cfg.MaxFiles > 0 {
+		// The current file is renamed, so only rotated files are listed, oldest first.
+		files, err := Files(l.cfg.Dir)
+		if err != nil {
+			return err
+		}
+		for len(files) > l.cfg.MaxFiles {
+			os.Remove(files[0])
+			files = files[1:]
+		}
+	}
+
+	return l.open()
+}
+
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.file.Close()
+}
+
+// Files returns audit log files in dir in chronological order.
+func Files(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit directory: %w", err)
+	}
+
+	var rotated []string
+	current := ""
+	for _, e := range entries {
+		name := e.Name()
+		switch {
+		case name == currentFile:
+			current = filepath.Join(dir, name)
+		case strings.HasPrefix(name, filePrefix+"-") && strings.HasSuffix(name, fileExt):
+			rotated = append(rotated, filepath.Join(dir, name))
+		}
+	}
+	// The timestamp layout sorts lexicographically.
+	slices.Sort(rotated)
+	if current != "" {
+		rotated = append(rotated, current)
+	}
+	return rotated, nil
+}
+
+// Find returns records in dir whose ID or request ID equals id.
+func Find(dir, id string) ([]Record, error) {
+	files, err := Files(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []Record
+	for _, path := range files {
+		err = scan(path, func(rec Record) {
+			if rec.ID == id || rec.RequestID == id {
+				found = append(found, rec)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(found) == 0 {
+		return nil, ErrNotFound
+	}
+	return found, nil
+}
+
+func scan(path string, fn func(Record)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+	for scanner.Scan() {
+		var rec Record
+		if json.Unmarshal(scanner.Bytes(), &rec) != nil {
+			continue
+		}
+		fn(rec)
+	}
+	return scanner.Err()
+}
+
+type requestIDKey struct{}
+
+// WithRequestID stores the ID of the API request the generations are made for.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,122 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/auth"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+)
+
+func TestBackendRecords(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(Config{
+		Dir:    dir,
+		Redact: func(s string) string { return strings.ReplaceAll(s, "ann@example.com", "[EMAIL]") },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewBackend(backend.NewFake(), l)
+
+	ctx := WithRequestID(auth.WithClient(context.Background(), "team-a"), "req-1")
+	_, err = b.Generate(ctx, backend.GenerateRequest{Model: "llama3.2", Prompt: "write to ann@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = b.Chat(cancelled, backend.ChatRequest{Model: "llama3.2", Messages: []backend.Message{{Role: "user", Content: "hi"}}})
+	if err == nil {
+		t.Fatal("expected error for cancelled context")
+	}
+	l.Close()
+
+	records, err := Find(dir, "req-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records for the request, got %d", len(records))
+	}
+
+	rec := records[0]
+	if rec.Client != "team-a" || rec.Operation != "generate" || rec.Model != "llama3.2" {
+		t.Errorf("unexpected record %+v", rec)
+	}
+	if !rec.Redacted || rec.Messages[0].Content != "write to [EMAIL]" {
+		t.Errorf("prompt should be redacted, got %+v", rec.Messages)
+	}
+	if rec.Response != "echo: write to ann@example.com" || rec.Usage.CompletionTokens == 0 {
+		t.Errorf("response should be recorded, got %q %+v", rec.Response, rec.Usage)
+	}
+	if records[1].Error == "" {
+		t.Errorf("error should be recorded, got %+v", records[1])
+	}
+
+	if _, err = Find(dir, records[1].ID); err != nil {
+		t.Errorf("expected to find record by its ID: %v", err)
+	}
+	if _, err = Find(dir, "unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(Config{Dir: dir, MaxBytes: 300, MaxFiles: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	l.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+
+	for i := range 10 {
+		err = l.Write(Record{ID: fmt.Sprint(i), Response: strings.Repeat("x", 100)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Close()
+
+	files, err := Files(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 || !strings.HasSuffix(files[2], currentFile) {
+		t.Fatalf("expected 2 rotated files and the current one, got %v", files)
+	}
+	if _, err = Find(dir, "9"); err != nil {
+		t.Errorf("latest record should be kept: %v", err)
+	}
+	if _, err = Find(dir, "0"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("oldest record should be removed with old files, got %v", err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{name: "Equal", a: "a\nb", b: "a\nb", want: ""},
+		{name: "Changed line", a: "a\nb\nc", b: "a\nx\nc", want: "  a\n- b\n+ x\n  c\n"},
+		{name: "Added line", a: "a", b: "a\nb", want: "  a\n+ b\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff(tt.a, tt.b); got != tt.want {
+				t.Errorf("Diff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
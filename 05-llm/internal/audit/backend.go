@@ -0,0 +1,66 @@
+package audit
+
+import (
+	"context"
+	"crypto/rand"
+	"log"
+	"time"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/auth"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+)
+
+// Backend writes every generation made by the wrapped backend to the log.
+type Backend struct {
+	backend.Backend
+	log *Logger
+}
+
+func NewBackend(b backend.Backend, l *Logger) *Backend {
+	return &Backend{Backend: b, log: l}
+}
+
+func (b *Backend) Generate(ctx context.Context, req backend.GenerateRequest) (backend.Response, error) {
+	start := time.Now()
+	resp, err := b.Backend.Generate(ctx, req)
+	b.record(ctx, "generate", backend.ChatFromGenerate(req), resp, err, start)
+	return resp, err
+}
+
+func (b *Backend) Chat(ctx context.Context, req backend.ChatRequest) (backend.Response, error) {
+	start := time.Now()
+	resp, err := b.Backend.Chat(ctx, req)
+	b.record(ctx, "chat", req, resp, err, start)
+	return resp, err
+}
+
+func (b *Backend) Stream(ctx context.Context, req backend.ChatRequest, fn func(backend.Chunk) error) (backend.Response, error) {
+	start := time.Now()
+	resp, err := b.Backend.Stream(ctx, req, fn)
+	b.record(ctx, "stream", req, resp, err, start)
+	return resp, err
+}
+
+func (b *Backend) record(ctx context.Context, op string, req backend.ChatRequest, resp backend.Response, err error, start time.Time) {
+	rec := Record{
+		ID:        rand.Text(),
+		Time:      start.UTC(),
+		RequestID: RequestIDFromContext(ctx),
+		Client:    auth.ClientFromContext(ctx),
+		Operation: op,
+		Model:     req.Model,
+		Messages:  req.Messages,
+		Options:   req.Options,
+		Format:    req.Format,
+		Response:  resp.Content,
+		Usage:     resp.Usage,
+		LatencyMS: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+
+	if err := b.log.Write(rec); err != nil {
+		log.Printf("Error writing audit log: %v", err)
+	}
+}
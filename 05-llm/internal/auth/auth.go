@@ -0,0 +1,280 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+)
+
+const hashPrefix = "sha256:"
+
+var ErrUnauthorized = errors.New("invalid or missing API key")
+
+// RateLimitError is returned when the client sends requests faster than allowed.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e RateLimitError) Error() string {
+	return "rate limit exceeded"
+}
+
+// QuotaError is returned when the client has used up its token quota.
+type QuotaError struct {
+	Period     string
+	RetryAfter time.Duration
+}
+
+func (e QuotaError) Error() string {
+	return fmt.Sprintf("%s token quota exceeded", e.Period)
+}
+
+type ClientConfig struct {
+	Name string `json:"name" yaml:"name"`
+	// KeyHash is the output of HashKey for the client's API key.
+	// Plain keys are never stored.
+	KeyHash           string  `json:"keyHash" yaml:"keyHash"`
+	RequestsPerSecond float64 `json:"requestsPerSecond" yaml:"requestsPerSecond"`
+	Burst             int     `json:"burst" yaml:"burst"`
+	DailyTokens       int64   `json:"dailyTokens" yaml:"dailyTokens"`
+	MonthlyTokens     int64   `json:"monthlyTokens" yaml:"monthlyTokens"`
+}
+
+type Config struct {
+	Clients []ClientConfig `json:"clients" yaml:"clients"`
+}
+
+// LoadConfig reads API clients from a JSON file.
+func LoadConfig(path string) (Config, error) {
+	var cfg Config
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read API keys config: %w", err)
+	}
+
+	err = json.Unmarshal(b, &cfg)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to parse API keys config %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// HashKey returns the representation of an API key stored in the config file.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hashPrefix + hex.EncodeToString(sum[:])
+}
+
+// Remaining is the number of tokens the client may still use.
+// Negative values mean the quota is unlimited.
+type Remaining struct {
+	Daily   int64
+	Monthly int64
+}
+
+type client struct {
+	cfg    ClientConfig
+	tokens float64
+	filled time.Time
+
+	day       string
+	dayUsed   int64
+	month     string
+	monthUsed int64
+}
+
+// Manager authenticates clients and enforces their rate limits and quotas.
+type Manager struct {
+	mu      sync.Mutex
+	keys    map[string]string
+	clients map[string]*client
+	now     func() time.Time
+}
+
+func NewManager(cfg Config) (*Manager, error) {
+	m := &Manager{now: time.Now}
+	err := m.Update(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Update replaces the clients with the ones in cfg. Clients keeping their
+// name keep the tokens used in the current periods and their rate limit
+// bucket. On error the manager is left unchanged.
+func (m *Manager) Update(cfg Config) error {
+	keys := make(map[string]string)
+	clients := make(map[string]*client)
+	for i, c := range cfg.Clients {
+		switch {
+		case c.Name == "":
+			return fmt.Errorf("client %d: name is not set", i)
+		case clients[c.Name] != nil:
+			return fmt.Errorf("client %d: duplicate name %q", i, c.Name)
+		case len(c.KeyHash) != len(hashPrefix)+sha256.Size*2 || c.KeyHash[:len(hashPrefix)] != hashPrefix:
+			return fmt.Errorf("client %q: keyHash must be %s followed by hex-encoded SHA-256 of the key", c.Name, hashPrefix)
+		case keys[c.KeyHash] != "":
+			return fmt.Errorf("client %q: key is already used by client %q", c.Name, keys[c.KeyHash])
+		case c.RequestsPerSecond < 0 || c.Burst < 0 || c.DailyTokens < 0 || c.MonthlyTokens < 0:
+			return fmt.Errorf("client %q: limits must not be negative", c.Name)
+		}
+		if c.RequestsPerSecond > 0 && c.Burst == 0 {
+			c.Burst = max(1, int(c.RequestsPerSecond))
+		}
+
+		keys[c.KeyHash] = c.Name
+		clients[c.Name] = &client{cfg: c, tokens: float64(c.Burst), filled: m.now()}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for name, c := range clients {
+		if old := m.clients[name]; old != nil {
+			c.tokens = min(old.tokens, float64(c.cfg.Burst))
+			c.filled = old.filled
+			c.day, c.dayUsed = old.day, old.dayUsed
+			c.month, c.monthUsed = old.month, old.monthUsed
+		}
+	}
+	m.keys = keys
+	m.clients = clients
+	return nil
+}
+
+// Authenticate returns the name of the client owning key.
+func (m *Manager) Authenticate(key string) (string, error) {
+	if key == "" {
+		return "", ErrUnauthorized
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name, ok := m.keys[HashKey(key)]
+	if !ok {
+		return "", ErrUnauthorized
+	}
+	return name, nil
+}
+
+// Allow takes a token from the client's rate limit bucket and checks
+// that its quotas are not exhausted.
+func (m *Manager) Allow(name string) (Remaining, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c := m.clients[name]
+	if c == nil {
+		return Remaining{}, ErrUnauthorized
+	}
+
+	now := m.now()
+	m.resetPeriods(c, now)
+	remaining := c.remaining()
+
+	if remaining.Daily == 0 {
+		return remaining, QuotaError{Period: "daily", RetryAfter: startOfNextDay(now).Sub(now)}
+	}
+	if remaining.Monthly == 0 {
+		return remaining, QuotaError{Period: "monthly", RetryAfter: startOfNextMonth(now).Sub(now)}
+	}
+
+	if c.cfg.RequestsPerSecond > 0 {
+		elapsed := now.Sub(c.filled).Seconds()
+		c.tokens = min(float64(c.cfg.Burst), c.tokens+elapsed*c.cfg.RequestsPerSecond)
+		c.filled = now
+		if c.tokens < 1 {
+			wait := (1 - c.tokens) / c.cfg.RequestsPerSecond
+			return remaining, RateLimitError{RetryAfter: time.Duration(wait * float64(time.Second))}
+		}
+		c.tokens--
+	}
+
+	return remaining, nil
+}
+
+// Record charges the client for the tokens used by a generation.
+func (m *Manager) Record(name string, usage backend.Usage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c := m.clients[name]
+	if c == nil {
+		return
+	}
+
+	m.resetPeriods(c, m.now())
+	tokens := int64(usage.PromptTokens + usage.CompletionTokens)
+	c.dayUsed += tokens
+	c.monthUsed += tokens
+}
+
+func (m *Manager) Remaining(name string) Remaining {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c := m.clients[name]
+	if c == nil {
+		return Remaining{}
+	}
+	m.resetPeriods(c, m.now())
+	return c.remaining()
+}
+
+func (m *Manager) resetPeriods(c *client, now time.Time) {
+	now = now.UTC()
+	if day := now.Format(time.DateOnly); c.day != day {
+		c.day = day
+		c.dayUsed = 0
+	}
+	if month := now.Format("2006-01"); c.month != month {
+		c.month = month
+		c.monthUsed = 0
+	}
+}
+
+func (c *client) remaining() Remaining {
+	r := Remaining{Daily: -1, Monthly: -1}
+	if c.cfg.DailyTokens > 0 {
+		r.Daily = max(0, c.cfg.DailyTokens-c.dayUsed)
+	}
+	if c.cfg.MonthlyTokens > 0 {
+		r.Monthly = max(0, c.cfg.MonthlyTokens-c.monthUsed)
+	}
+	return r
+}
+
+func startOfNextDay(now time.Time) time.Time {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+}
+
+func startOfNextMonth(now time.Time) time.Time {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+type contextKey struct{}
+
+// WithClient stores the authenticated client name in ctx.
+func WithClient(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, contextKey{}, name)
+}
+
+// ClientFromContext returns the authenticated client name or an empty string.
+func ClientFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(contextKey{}).(string)
+	return name
+}
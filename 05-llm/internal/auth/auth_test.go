@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+)
+
+func newTestManager(t *testing.T, now *time.Time, clients ...ClientConfig) *Manager {
+	t.Helper()
+
+	m, err := NewManager(Config{Clients: clients})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.now = func() time.Time { return *now }
+	for _, c := range m.clients {
+		c.filled = *now
+	}
+	return m
+}
+
+func TestAuthenticate(t *testing.T) {
+	now := time.Now()
+	m := newTestManager(t, &now, ClientConfig{Name: "team-a", KeyHash: HashKey("secret")})
+
+	if name, err := m.Authenticate("secret"); err != nil || name != "team-a" {
+		t.Errorf("Authenticate() = %q, %v; want team-a", name, err)
+	}
+	for _, key := range []string{"", "wrong"} {
+		if _, err := m.Authenticate(key); !errors.Is(err, ErrUnauthorized) {
+			t.Errorf("Authenticate(%q) error = %v, want ErrUnauthorized", key, err)
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	now := time.Now()
+	m := newTestManager(t, &now, ClientConfig{
+		Name:              "team-a",
+		KeyHash:           HashKey("secret"),
+		RequestsPerSecond: 2,
+		Burst:             2,
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := m.Allow("team-a"); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+
+	var rateErr RateLimitError
+	if _, err := m.Allow("team-a"); !errors.As(err, &rateErr) {
+		t.Fatalf("expected RateLimitError, got %v", err)
+	}
+	if rateErr.RetryAfter != 500*time.Millisecond {
+		t.Errorf("unexpected retry after %v", rateErr.RetryAfter)
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if _, err := m.Allow("team-a"); err != nil {
+		t.Errorf("expected bucket to refill, got %v", err)
+	}
+}
+
+func TestQuota(t *testing.T) {
+	now := time.Date(2025, 1, 31, 23, 0, 0, 0, time.UTC)
+	m := newTestManager(t, &now, ClientConfig{
+		Name:          "team-a",
+		KeyHash:       HashKey("secret"),
+		DailyTokens:   100,
+		MonthlyTokens: 150,
+	})
+
+	remaining, err := m.Allow("team-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remaining != (Remaining{Daily: 100, Monthly: 150}) {
+		t.Errorf("unexpected remaining %+v", remaining)
+	}
+
+	m.Record("team-a", backend.Usage{PromptTokens: 60, CompletionTokens: 40})
+
+	var quotaErr QuotaError
+	if _, err = m.Allow("team-a"); !errors.As(err, &quotaErr) || quotaErr.Period != "daily" {
+		t.Fatalf("expected daily QuotaError, got %v", err)
+	}
+	if quotaErr.RetryAfter != time.Hour {
+		t.Errorf("unexpected retry after %v", quotaErr.RetryAfter)
+	}
+
+	now = now.Add(2 * time.Hour)
+	remaining, err = m.Allow("team-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remaining != (Remaining{Daily: 100, Monthly: 150}) {
+		t.Errorf("expected quotas to reset in a new month, got %+v", remaining)
+	}
+
+	for _, tokens := range []int{50, 60, 40} {
+		m.Record("team-a", backend.Usage{CompletionTokens: tokens})
+		now = now.Add(24 * time.Hour)
+	}
+	if _, err = m.Allow("team-a"); !errors.As(err, &quotaErr) || quotaErr.Period != "monthly" {
+		t.Errorf("expected monthly QuotaError, got %v", err)
+	}
+}
+
+func TestNewManagerValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		clients []ClientConfig
+	}{
+		{name: "Missing name", clients: []ClientConfig{{KeyHash: HashKey("a")}}},
+		{name: "Plain key", clients: []ClientConfig{{Name: "a", KeyHash: "secret"}}},
+		{name: "Duplicate key", clients: []ClientConfig{{Name: "a", KeyHash: HashKey("a")}, {Name: "b", KeyHash: HashKey("a")}}},
+		{name: "Negative limit", clients: []ClientConfig{{Name: "a", KeyHash: HashKey("a"), DailyTokens: -1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewManager(Config{Clients: tt.clients}); err == nil {
+				t.Error("expected validation error")
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	m := newTestManager(t, &now,
+		ClientConfig{Name: "team-a", KeyHash: HashKey("secret-a"), DailyTokens: 100},
+		ClientConfig{Name: "team-b", KeyHash: HashKey("secret-b")},
+	)
+	m.Record("team-a", backend.Usage{PromptTokens: 30, CompletionTokens: 10})
+
+	err := m.Update(Config{Clients: []ClientConfig{
+		{Name: "team-a", KeyHash: HashKey("rotated"), DailyTokens: 50},
+		{Name: "team-c", KeyHash: HashKey("secret-c")},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Authenticate("secret-a"); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("old key of team-a still works: %v", err)
+	}
+	if name, err := m.Authenticate("rotated"); err != nil || name != "team-a" {
+		t.Errorf("Authenticate(rotated) = %q, %v; want team-a", name, err)
+	}
+	if _, err := m.Authenticate("secret-b"); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("removed client team-b still authenticates: %v", err)
+	}
+	if got := m.Remaining("team-a").Daily; got != 10 {
+		t.Errorf("team-a daily remaining = %d, want usage kept under the new quota (10)", got)
+	}
+
+	// An invalid update leaves the manager unchanged.
+	err = m.Update(Config{Clients: []ClientConfig{{Name: "team-a"}}})
+	if err == nil {
+		t.Fatal("expected an error for a client without a key")
+	}
+	if name, err := m.Authenticate("rotated"); err != nil || name != "team-a" {
+		t.Errorf("failed update changed the clients: %q, %v", name, err)
+	}
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+)
+
+// Backend charges the client stored in the request context
+// for tokens used by the wrapped backend.
+type Backend struct {
+	backend.Backend
+	manager *Manager
+}
+
+func NewBackend(b backend.Backend, manager *Manager) *Backend {
+	return &Backend{Backend: b, manager: manager}
+}
+
+func (b *Backend) Generate(ctx context.Context, req backend.GenerateRequest) (backend.Response, error) {
+	resp, err := b.Backend.Generate(ctx, req)
+	b.record(ctx, resp)
+	return resp, err
+}
+
+func (b *Backend) Chat(ctx context.Context, req backend.ChatRequest) (backend.Response, error) {
+	resp, err := b.Backend.Chat(ctx, req)
+	b.record(ctx, resp)
+	return resp, err
+}
+
+func (b *Backend) Stream(ctx context.Context, req backend.ChatRequest, fn func(backend.Chunk) error) (backend.Response, error) {
+	resp, err := b.Backend.Stream(ctx, req, fn)
+	b.record(ctx, resp)
+	return resp, err
+}
+
+func (b *Backend) record(ctx context.Context, resp backend.Response) {
+	if name := ClientFromContext(ctx); name != "" {
+		b.manager.Record(name, resp.Usage)
+	}
+}
@@ -0,0 +1,211 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	KindOllama = "ollama"
+	KindOpenAI = "openai"
+	KindFake   = "fake"
+)
+
+// Backend is an LLM provider the proxy forwards generations to.
+type Backend interface {
+	Generate(ctx context.Context, req GenerateRequest) (Response, error)
+	Chat(ctx context.Context, req ChatRequest) (Response, error)
+	// Stream runs a chat generation and calls fn for every received chunk.
+	// The returned Response holds the whole generated content and usage.
+	Stream(ctx context.Context, req ChatRequest, fn func(Chunk) error) (Response, error)
+	Embed(ctx context.Context, req EmbedRequest) (EmbedResponse, error)
+	ListModels(ctx context.Context) ([]Model, error)
+}
+
+// ErrContextUnknown is returned by a ContextSizer which cannot tell the
+// context length of a model. It does not mean the backend is unhealthy.
+var ErrContextUnknown = errors.New("context length is unknown")
+
+// ContextSizer is implemented by backends which can tell the context
+// length of a model in tokens.
+type ContextSizer interface {
+	ContextSize(ctx context.Context, model string) (int, error)
+}
+
+type Options struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	TopK        *int     `json:"top_k,omitempty"`
+	Seed        *int     `json:"seed,omitempty"`
+	NumPredict  *int     `json:"num_predict,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+type Message struct {
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// ToolName and ToolCallID identify the call answered by a "tool" message.
+	ToolName   string `json:"tool_name,omitempty"`
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	// Images are base64 encoded images for vision models.
+	Images []string `json:"images,omitempty"`
+	// Internal marks a message written by the proxy rather than the client,
+	// e.g. a summarization transcript. Input guardrails skip it.
+	Internal bool `json:"-"`
+}
+
+// Tool describes a function the model may call.
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+type ToolFunction struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Parameters is a JSON schema of the arguments object.
+	Parameters json.RawMessage `json:"parameters"`
+}
+
+type ToolCall struct {
+	ID       string           `json:"id,omitempty"`
+	Function ToolCallFunction `json:"function"`
+}
+
+type ToolCallFunction struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type GenerateRequest struct {
+	Model   string
+	System  string
+	Prompt  string
+	Options Options
+	// Images are base64 encoded images attached to the prompt.
+	Images []string
+	// Format constrains the output: "json" or a JSON schema.
+	Format json.RawMessage
+}
+
+type ChatRequest struct {
+	Model    string
+	Messages []Message
+	Options  Options
+	Format   json.RawMessage
+	Tools    []Tool
+}
+
+type Usage struct {
+	PromptTokens     int `json:"promptTokens"`
+	CompletionTokens int `json:"completionTokens"`
+}
+
+// Timings break down the generation time. Only Ollama reports them,
+// other backends leave them zero.
+type Timings struct {
+	Total      time.Duration
+	Load       time.Duration
+	PromptEval time.Duration
+	Eval       time.Duration
+}
+
+type Response struct {
+	Model     string
+	Content   string
+	ToolCalls []ToolCall
+	Usage     Usage
+	Timings   Timings
+}
+
+type Chunk struct {
+	Content string
+}
+
+type EmbedRequest struct {
+	Model string
+	Input []string
+}
+
+type EmbedResponse struct {
+	Model      string
+	Embeddings [][]float64
+}
+
+type Model struct {
+	Name string `json:"name"`
+}
+
+// StatusError is returned when the upstream server responds with a non-2xx status.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e StatusError) Error() string {
+	return fmt.Sprintf("llm server responded with status %d: %s", e.StatusCode, e.Body)
+}
+
+type Config struct {
+	Kind string
+	// URLs of upstream servers. Several URLs make a load balanced Pool.
+	URLs   []string
+	APIKey string
+	Models []string
+	// ContextLength is requested from Ollama with every generation, see
+	// Ollama.SetContextLength.
+	ContextLength int
+}
+
+// New builds the backend selected by cfg.Kind.
+func New(cfg Config, client *http.Client) (Backend, error) {
+	if cfg.Kind == KindFake {
+		return NewFake(cfg.Models...), nil
+	}
+	if len(cfg.URLs) == 0 {
+		return nil, fmt.Errorf("backend URL is not set")
+	}
+
+	var upstreams []Upstream
+	for _, rawURL := range cfg.URLs {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse backend URL: %w", err)
+		}
+
+		var b Backend
+		switch cfg.Kind {
+		case KindOllama, "":
+			ollama := NewOllama(u, client)
+			ollama.SetContextLength(cfg.ContextLength)
+			b = ollama
+		case KindOpenAI:
+			b = NewOpenAI(u, cfg.APIKey, client)
+		default:
+			return nil, fmt.Errorf("unknown backend kind %q", cfg.Kind)
+		}
+		upstreams = append(upstreams, Upstream{Name: rawURL, Backend: b})
+	}
+
+	if len(upstreams) == 1 {
+		return upstreams[0].Backend, nil
+	}
+	return NewPool(upstreams...), nil
+}
+
+// ChatFromGenerate converts a generate request into an equivalent chat request.
+func ChatFromGenerate(req GenerateRequest) ChatRequest {
+	var messages []Message
+	if req.System != "" {
+		messages = append(messages, Message{Role: "system", Content: req.System})
+	}
+	messages = append(messages, Message{Role: "user", Content: req.Prompt, Images: req.Images})
+
+	return ChatRequest{Model: req.Model, Messages: messages, Options: req.Options, Format: req.Format}
+}
@@ -0,0 +1,334 @@
+package backend
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOllamaStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		fmt.Fprintln(w, `{"model":"llama3.2","message":{"role":"assistant","content":"Hello"},"done":false}`)
+		fmt.Fprintln(w, `{"model":"llama3.2","message":{"role":"assistant","content":", world"},"done":false}`)
+		fmt.Fprintln(w, `{"model":"llama3.2","message":{"role":"assistant","content":""},"done":true,"prompt_eval_count":3,"eval_count":2}`)
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	o := NewOllama(u, srv.Client())
+
+	var chunks []string
+	resp, err := o.Stream(context.Background(), ChatRequest{Model: "llama3.2"}, func(c Chunk) error {
+		chunks = append(chunks, c.Content)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(chunks, "|"); got != "Hello|, world" {
+		t.Errorf("unexpected chunks: got %q", got)
+	}
+	if resp.Content != "Hello, world" {
+		t.Errorf("unexpected content: got %q", resp.Content)
+	}
+	if resp.Usage != (Usage{PromptTokens: 3, CompletionTokens: 2}) {
+		t.Errorf("unexpected usage: got %+v", resp.Usage)
+	}
+}
+
+func TestOpenAIStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("unexpected authorization header %q", r.Header.Get("Authorization"))
+		}
+		fmt.Fprint(w, "data: {\"model\":\"qwen\",\"choices\":[{\"delta\":{\"content\":\"Hi\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"model\":\"qwen\",\"choices\":[{\"delta\":{\"content\":\" there\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"model\":\"qwen\",\"choices\":[],\"usage\":{\"prompt_tokens\":4,\"completion_tokens\":2}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	o := NewOpenAI(u, "secret", srv.Client())
+
+	resp, err := o.Stream(context.Background(), ChatRequest{Model: "qwen"}, func(Chunk) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Content != "Hi there" {
+		t.Errorf("unexpected content: got %q", resp.Content)
+	}
+	if resp.Usage != (Usage{PromptTokens: 4, CompletionTokens: 2}) {
+		t.Errorf("unexpected usage: got %+v", resp.Usage)
+	}
+}
+
+func TestOpenAIImages(t *testing.T) {
+	png := base64.StdEncoding.EncodeToString([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"))
+	tests := []struct {
+		name     string
+		message  Message
+		expected string
+	}{
+		{
+			name:     "Text only",
+			message:  Message{Role: "user", Content: "hi"},
+			expected: `{"role":"user","content":"hi"}`,
+		},
+		{
+			name:    "With image",
+			message: Message{Role: "user", Content: "what is it?", Images: []string{png}},
+			expected: `{"role":"user","content":[{"type":"text","text":"what is it?"},` +
+				`{"type":"image_url","image_url":{"url":"data:image/png;base64,` + png + `"}}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := newOpenAIChatRequest(ChatRequest{Messages: []Message{tt.message}})
+			b, err := json.Marshal(in.Messages[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.expected {
+				t.Errorf("got %s, want %s", b, tt.expected)
+			}
+		})
+	}
+}
+
+func TestOllamaContextSize(t *testing.T) {
+	tests := []struct {
+		name         string
+		numCtx       int
+		body         string
+		expectedSize int
+		expectedErr  bool
+	}{
+		{
+			name:         "Pinned",
+			numCtx:       4096,
+			body:         `{"parameters":"num_ctx 8192","model_info":{"general.architecture":"llama","llama.context_length":131072}}`,
+			expectedSize: 4096,
+		},
+		{
+			name:         "Pinned above trained context length",
+			numCtx:       4096,
+			body:         `{"model_info":{"general.architecture":"bert","bert.context_length":512}}`,
+			expectedSize: 512,
+		},
+		{
+			name:         "num_ctx from Modelfile",
+			body:         `{"parameters":"num_ctx                        8192\nstop \"<|eot_id|>\"","model_info":{"llama.context_length":131072}}`,
+			expectedSize: 8192,
+		},
+		{
+			// Ollama runs with its own default, not the trained length.
+			name:        "Trained context length only",
+			body:        `{"parameters":"stop \"<|eot_id|>\"","model_info":{"general.architecture":"llama","llama.context_length":131072}}`,
+			expectedErr: true,
+		},
+		{
+			name:        "Unknown",
+			body:        `{"model_info":{"general.architecture":"bert"}}`,
+			expectedErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if r.URL.Path != "/api/show" || string(body) != `{"model":"llama3.2"}` {
+					t.Errorf("unexpected request %s %s", r.URL.Path, body)
+				}
+				fmt.Fprint(w, tt.body)
+			}))
+			defer srv.Close()
+
+			u, _ := url.Parse(srv.URL)
+			o := NewOllama(u, srv.Client())
+			o.SetContextLength(tt.numCtx)
+			size, err := o.ContextSize(context.Background(), "llama3.2")
+			if (err != nil) != tt.expectedErr || (err != nil && !errors.Is(err, ErrContextUnknown)) {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if size != tt.expectedSize {
+				t.Errorf("expected size %d, got %d", tt.expectedSize, size)
+			}
+		})
+	}
+}
+
+func TestOllamaNumCtx(t *testing.T) {
+	tests := []struct {
+		name     string
+		numCtx   int
+		expected string
+	}{
+		{name: "Pinned", numCtx: 4096, expected: `{"temperature":0.2,"num_ctx":4096}`},
+		{name: "Modelfile default", expected: `{"temperature":0.2}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var options []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var in struct {
+					Options json.RawMessage `json:"options"`
+				}
+				if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+					t.Error(err)
+				}
+				options = append(options, string(in.Options))
+				if r.URL.Path == "/api/generate" {
+					fmt.Fprint(w, `{"model":"llama3.2","response":"hi","done":true}`)
+					return
+				}
+				fmt.Fprint(w, `{"model":"llama3.2","message":{"role":"assistant","content":"hi"},"done":true}`)
+			}))
+			defer srv.Close()
+
+			u, _ := url.Parse(srv.URL)
+			o := NewOllama(u, srv.Client())
+			o.SetContextLength(tt.numCtx)
+			temperature := 0.2
+			opts := Options{Temperature: &temperature}
+			ctx := context.Background()
+			if _, err := o.Generate(ctx, GenerateRequest{Model: "llama3.2", Options: opts}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := o.Chat(ctx, ChatRequest{Model: "llama3.2", Options: opts}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := o.Stream(ctx, ChatRequest{Model: "llama3.2", Options: opts}, func(Chunk) error { return nil }); err != nil {
+				t.Fatal(err)
+			}
+
+			for i, got := range options {
+				if got != tt.expected {
+					t.Errorf("request %d sent options %s, want %s", i, got, tt.expected)
+				}
+			}
+			if len(options) != 3 {
+				t.Errorf("expected 3 requests, got %d", len(options))
+			}
+		})
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"model not found"}`, http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	_, err := NewOllama(u, srv.Client()).Generate(context.Background(), GenerateRequest{Model: "nope"})
+
+	statusErr, ok := err.(StatusError)
+	if !ok {
+		t.Fatalf("expected StatusError, got %v", err)
+	}
+	if statusErr.StatusCode != http.StatusNotFound {
+		t.Errorf("unexpected status code: got %d", statusErr.StatusCode)
+	}
+}
+
+func TestFake(t *testing.T) {
+	f := NewFake()
+	f.SetReply("ping", "pong")
+
+	tests := []struct {
+		name   string
+		prompt string
+		want   string
+	}{
+		{name: "Echo", prompt: "hello there", want: "echo: hello there"},
+		{name: "Scripted reply", prompt: "ping", want: "pong"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := f.Generate(context.Background(), GenerateRequest{Prompt: tt.prompt})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Content != tt.want {
+				t.Errorf("Generate() = %q, want %q", resp.Content, tt.want)
+			}
+
+			var streamed strings.Builder
+			_, err = f.Stream(context.Background(), ChatFromGenerate(GenerateRequest{Prompt: tt.prompt}), func(c Chunk) error {
+				streamed.WriteString(c.Content)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if streamed.String() != tt.want {
+				t.Errorf("Stream() = %q, want %q", streamed.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestOllamaCancellation(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream bool
+	}{
+		{name: "Non-streaming", stream: false},
+		{name: "Streaming", stream: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aborted := make(chan struct{})
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// The server notices a closed connection only after the body is read.
+				io.Copy(io.Discard, r.Body)
+				if tt.stream {
+					fmt.Fprintln(w, `{"model":"llama3.2","message":{"role":"assistant","content":"Hello"},"done":false}`)
+					w.(http.Flusher).Flush()
+				}
+				<-r.Context().Done()
+				close(aborted)
+			}))
+			defer srv.Close()
+
+			u, _ := url.Parse(srv.URL)
+			o := NewOllama(u, srv.Client())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			var err error
+			if tt.stream {
+				_, err = o.Stream(ctx, ChatRequest{Model: "llama3.2"}, func(c Chunk) error {
+					cancel()
+					return nil
+				})
+			} else {
+				time.AfterFunc(50*time.Millisecond, cancel)
+				_, err = o.Chat(ctx, ChatRequest{Model: "llama3.2"})
+			}
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("expected context.Canceled, got %v", err)
+			}
+
+			select {
+			case <-aborted:
+			case <-time.After(2 * time.Second):
+				t.Fatal("upstream request was not aborted")
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+const fakeEmbeddingSize = 64
+
+// Fake is a deterministic in-process backend for tests and local development.
+// It echoes the last user message unless a scripted reply is registered for it.
+type Fake struct {
+	mu        sync.Mutex
+	models    []string
+	replies   map[string]string
+	delay     time.Duration
+	cancelled int
+}
+
+func NewFake(models ...string) *Fake {
+	if len(models) == 0 {
+		models = []string{"fake"}
+	}
+	return &Fake{models: models, replies: make(map[string]string)}
+}
+
+// SetReply makes the backend answer prompt with reply.
+func (f *Fake) SetReply(prompt, reply string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.replies[prompt] = reply
+}
+
+// SetDelay makes Chat and Generate take d to answer and Stream wait d before every chunk.
+func (f *Fake) SetDelay(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.delay = d
+}
+
+// Cancelled returns the number of generations aborted by context cancellation.
+func (f *Fake) Cancelled() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.cancelled
+}
+
+// wait simulates generation time and watches for cancellation.
+func (f *Fake) wait(ctx context.Context) error {
+	f.mu.Lock()
+	delay := f.delay
+	f.mu.Unlock()
+
+	err := ctx.Err()
+	if err == nil && delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+
+	if err != nil {
+		f.mu.Lock()
+		f.cancelled++
+		f.mu.Unlock()
+	}
+	return err
+}
+
+func (f *Fake) reply(req ChatRequest) Response {
+	var prompt string
+	promptTokens := 0
+	for _, m := range req.Messages {
+		promptTokens += countWords(m.Content)
+		if m.Role == "user" {
+			prompt = m.Content
+		}
+	}
+
+	f.mu.Lock()
+	content, ok := f.replies[prompt]
+	f.mu.Unlock()
+	if !ok {
+		content = fmt.Sprintf("echo: %s", prompt)
+	}
+
+	return Response{
+		Model:   req.Model,
+		Content: content,
+		Usage:   Usage{PromptTokens: promptTokens, CompletionTokens: countWords(content)},
+	}
+}
+
+func (f *Fake) Generate(ctx context.Context, req GenerateRequest) (Response, error) {
+	return f.Chat(ctx, ChatFromGenerate(req))
+}
+
+func (f *Fake) Chat(ctx context.Context, req ChatRequest) (Response, error) {
+	if err := f.wait(ctx); err != nil {
+		return Response{}, err
+	}
+	return f.reply(req), nil
+}
+
+func (f *Fake) Stream(ctx context.Context, req ChatRequest, fn func(Chunk) error) (Response, error) {
+	resp := f.reply(req)
+
+	for i, word := range strings.SplitAfter(resp.Content, " ") {
+		if err := f.wait(ctx); err != nil {
+			return Response{}, err
+		}
+		if i == 0 && word == "" {
+			continue
+		}
+		err := fn(Chunk{Content: word})
+		if err != nil {
+			return Response{}, err
+		}
+	}
+
+	return resp, nil
+}
+
+// Embed returns a normalized bag-of-words vector, so texts sharing words
+// end up close to each other by cosine similarity.
+func (f *Fake) Embed(ctx context.Context, req EmbedRequest) (EmbedResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return EmbedResponse{}, err
+	}
+
+	result := EmbedResponse{Model: req.Model, Embeddings: make([][]float64, 0, len(req.Input))}
+	for _, input := range req.Input {
+		vector := make([]float64, fakeEmbeddingSize)
+		for _, word := range strings.Fields(strings.ToLower(input)) {
+			h := fnv.New32a()
+			h.Write([]byte(strings.Trim(word, ".,!?;:\"'()")))
+			vector[h.Sum32()%fakeEmbeddingSize]++
+		}
+
+		var norm float64
+		for _, v := range vector {
+			norm += v * v
+		}
+		if norm > 0 {
+			norm = math.Sqrt(norm)
+			for i := range vector {
+				vector[i] /= norm
+			}
+		}
+		result.Embeddings = append(result.Embeddings, vector)
+	}
+
+	return result, nil
+}
+
+func (f *Fake) ListModels(ctx context.Context) ([]Model, error) {
+	models := make([]Model, 0, len(f.models))
+	for _, name := range f.models {
+		models = append(models, Model{Name: name})
+	}
+	return models, nil
+}
+
+func countWords(s string) int {
+	return len(strings.Fields(s))
+}
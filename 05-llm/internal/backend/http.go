@@ -0,0 +1,77 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+const maxErrorBody = 4 << 10
+
+type httpClient struct {
+	baseURL *url.URL
+	client  *http.Client
+	headers map[string]string
+}
+
+func (c *httpClient) endpoint(path string) string {
+	u := *c.baseURL
+	u.Path = path
+	return u.String()
+}
+
+// do sends the request and returns the response if its status is 2xx.
+// The caller is responsible for closing the response body.
+func (c *httpClient) do(ctx context.Context, method, path string, in any) (*http.Response, error) {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint(path), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range c.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request llm server: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return nil, StatusError{StatusCode: resp.StatusCode, Body: string(bytes.TrimSpace(b))}
+	}
+
+	return resp, nil
+}
+
+func (c *httpClient) doJSON(ctx context.Context, method, path string, in, out any) error {
+	resp, err := c.do(ctx, method, path, in)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	err = json.NewDecoder(resp.Body).Decode(out)
+	if err != nil {
+		return fmt.Errorf("failed to parse llm response: %w", err)
+	}
+
+	return nil
+}
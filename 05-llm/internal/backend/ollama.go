@@ -0,0 +1,277 @@
+package backend
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ollama talks to an Ollama server through its native /api endpoints.
+type Ollama struct {
+	http httpClient
+	// numCtx is the context length requested with every generation, zero
+	// leaves it to the Modelfile or the server default.
+	numCtx int
+}
+
+// ollamaOptions adds the options the proxy sets itself to those of the client.
+type ollamaOptions struct {
+	Options
+	NumCtx int `json:"num_ctx,omitempty"`
+}
+
+type ollamaGenerateRequest struct {
+	Model   string          `json:"model"`
+	Prompt  string          `json:"prompt"`
+	System  string          `json:"system,omitempty"`
+	Images  []string        `json:"images,omitempty"`
+	Stream  bool            `json:"stream"`
+	Format  json.RawMessage `json:"format,omitempty"`
+	Options ollamaOptions   `json:"options"`
+}
+
+type ollamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []Message       `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Format   json.RawMessage `json:"format,omitempty"`
+	Tools    []Tool          `json:"tools,omitempty"`
+	Options  ollamaOptions   `json:"options"`
+}
+
+type ollamaResponse struct {
+	Model           string  `json:"model"`
+	Response        string  `json:"response"`
+	Message         Message `json:"message"`
+	Done            bool    `json:"done"`
+	Error           string  `json:"error"`
+	PromptEvalCount int     `json:"prompt_eval_count"`
+	EvalCount       int     `json:"eval_count"`
+	// Durations are in nanoseconds.
+	TotalDuration      int64 `json:"total_duration"`
+	LoadDuration       int64 `json:"load_duration"`
+	PromptEvalDuration int64 `json:"prompt_eval_duration"`
+	EvalDuration       int64 `json:"eval_duration"`
+}
+
+func (r ollamaResponse) usage() Usage {
+	return Usage{PromptTokens: r.PromptEvalCount, CompletionTokens: r.EvalCount}
+}
+
+func (r ollamaResponse) timings() Timings {
+	return Timings{
+		Total:      time.Duration(r.TotalDuration),
+		Load:       time.Duration(r.LoadDuration),
+		PromptEval: time.Duration(r.PromptEvalDuration),
+		Eval:       time.Duration(r.EvalDuration),
+	}
+}
+
+type ollamaEmbeddingsRequest struct {
+	Model  string `json:"model"`
+	Prompt string `json:"prompt"`
+}
+
+type ollamaEmbeddingsResponse struct {
+	Embedding []float64 `json:"embedding"`
+}
+
+type ollamaTagsResponse struct {
+	Models []Model `json:"models"`
+}
+
+type ollamaShowRequest struct {
+	Model string `json:"model"`
+}
+
+type ollamaShowResponse struct {
+	// Parameters are the Modelfile parameters, one "name value" per line.
+	Parameters string         `json:"parameters"`
+	ModelInfo  map[string]any `json:"model_info"`
+}
+
+func NewOllama(baseURL *url.URL, client *http.Client) *Ollama {
+	return &Ollama{http: httpClient{baseURL: baseURL, client: client}}
+}
+
+// SetContextLength makes every generation run with a context of n tokens
+// instead of the num_ctx of the Modelfile or the server default, which the
+// proxy cannot know. Zero restores the default.
+func (o *Ollama) SetContextLength(n int) {
+	o.numCtx = n
+}
+
+func (o *Ollama) options(opts Options) ollamaOptions {
+	return ollamaOptions{Options: opts, NumCtx: o.numCtx}
+}
+
+func (o *Ollama) Generate(ctx context.Context, req GenerateRequest) (Response, error) {
+	in := ollamaGenerateRequest{
+		Model:   req.Model,
+		Prompt:  req.Prompt,
+		System:  req.System,
+		Images:  req.Images,
+		Format:  req.Format,
+		Options: o.options(req.Options),
+	}
+
+	var out ollamaResponse
+	err := o.http.doJSON(ctx, http.MethodPost, "/api/generate", in, &out)
+	if err != nil {
+		return Response{}, err
+	}
+
+	return Response{Model: out.Model, Content: out.Response, Usage: out.usage(), Timings: out.timings()}, nil
+}
+
+func (o *Ollama) Chat(ctx context.Context, req ChatRequest) (Response, error) {
+	in := ollamaChatRequest{
+		Model:    req.Model,
+		Messages: req.Messages,
+		Format:   req.Format,
+		Tools:    req.Tools,
+		Options:  o.options(req.Options),
+	}
+
+	var out ollamaResponse
+	err := o.http.doJSON(ctx, http.MethodPost, "/api/chat", in, &out)
+	if err != nil {
+		return Response{}, err
+	}
+
+	return Response{
+		Model:     out.Model,
+		Content:   out.Message.Content,
+		ToolCalls: out.Message.ToolCalls,
+		Usage:     out.usage(),
+		Timings:   out.timings(),
+	}, nil
+}
+
+func (o *Ollama) Stream(ctx context.Context, req ChatRequest, fn func(Chunk) error) (Response, error) {
+	in := ollamaChatRequest{
+		Model:    req.Model,
+		Messages: req.Messages,
+		Stream:   true,
+		Format:   req.Format,
+		Tools:    req.Tools,
+		Options:  o.options(req.Options),
+	}
+
+	resp, err := o.http.do(ctx, http.MethodPost, "/api/chat", in)
+	if err != nil {
+		return Response{}, err
+	}
+	defer resp.Body.Close()
+
+	result := Response{Model: req.Model}
+	var content strings.Builder
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var chunk ollamaResponse
+		err = json.Unmarshal(line, &chunk)
+		if err != nil {
+			return result, fmt.Errorf("failed to parse llm response: %w", err)
+		}
+		if chunk.Error != "" {
+			return result, fmt.Errorf("llm server error: %s", chunk.Error)
+		}
+
+		result.ToolCalls = append(result.ToolCalls, chunk.Message.ToolCalls...)
+		if chunk.Message.Content != "" {
+			content.WriteString(chunk.Message.Content)
+			err = fn(Chunk{Content: chunk.Message.Content})
+			if err != nil {
+				return result, err
+			}
+		}
+
+		if chunk.Done {
+			result.Model = chunk.Model
+			result.Content = content.String()
+			result.Usage = chunk.usage()
+			result.Timings = chunk.timings()
+			return result, nil
+		}
+	}
+
+	err = scanner.Err()
+	if err == nil {
+		err = fmt.Errorf("llm stream ended unexpectedly: %w", io.ErrUnexpectedEOF)
+	}
+	result.Content = content.String()
+	return result, err
+}
+
+func (o *Ollama) Embed(ctx context.Context, req EmbedRequest) (EmbedResponse, error) {
+	result := EmbedResponse{Model: req.Model, Embeddings: make([][]float64, 0, len(req.Input))}
+
+	for _, input := range req.Input {
+		in := ollamaEmbeddingsRequest{Model: req.Model, Prompt: input}
+
+		var out ollamaEmbeddingsResponse
+		err := o.http.doJSON(ctx, http.MethodPost, "/api/embeddings", in, &out)
+		if err != nil {
+			return EmbedResponse{}, err
+		}
+		result.Embeddings = append(result.Embeddings, out.Embedding)
+	}
+
+	return result, nil
+}
+
+func (o *Ollama) ListModels(ctx context.Context) ([]Model, error) {
+	var out ollamaTagsResponse
+	err := o.http.doJSON(ctx, http.MethodGet, "/api/tags", nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Models, nil
+}
+
+// ContextSize returns the context length the model runs with: the one set
+// with SetContextLength, capped by the length the model was trained with, or
+// else num_ctx of its Modelfile. The trained length alone says nothing about
+// the window Ollama allocates, so without either the size is unknown.
+func (o *Ollama) ContextSize(ctx context.Context, model string) (int, error) {
+	var out ollamaShowResponse
+	err := o.http.doJSON(ctx, http.MethodPost, "/api/show", ollamaShowRequest{Model: model}, &out)
+	if err != nil {
+		return 0, err
+	}
+
+	if o.numCtx > 0 {
+		// The key is prefixed with the architecture, e.g. "llama.context_length".
+		for key, value := range out.ModelInfo {
+			if n, ok := value.(float64); ok && strings.HasSuffix(key, ".context_length") && n > 0 {
+				return min(o.numCtx, int(n)), nil
+			}
+		}
+		return o.numCtx, nil
+	}
+
+	for _, line := range strings.Split(out.Parameters, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "num_ctx" {
+			if n, err := strconv.Atoi(fields[1]); err == nil && n > 0 {
+				return n, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("%w for %s", ErrContextUnknown, model)
+}
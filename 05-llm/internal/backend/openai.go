@@ -0,0 +1,296 @@
+package backend
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// OpenAI talks to any server exposing the OpenAI-compatible /v1 API,
+// e.g. llama.cpp server or vLLM.
+type OpenAI struct {
+	http httpClient
+}
+
+type openAIChatRequest struct {
+	Model          string               `json:"model"`
+	Messages       []openAIMessage      `json:"messages"`
+	Stream         bool                 `json:"stream"`
+	StreamOptions  *openAIStreamOptions `json:"stream_options,omitempty"`
+	Temperature    *float64             `json:"temperature,omitempty"`
+	TopP           *float64             `json:"top_p,omitempty"`
+	Seed           *int                 `json:"seed,omitempty"`
+	MaxTokens      *int                 `json:"max_tokens,omitempty"`
+	Stop           []string             `json:"stop,omitempty"`
+	ResponseFormat map[string]any       `json:"response_format,omitempty"`
+	Tools          []Tool               `json:"tools,omitempty"`
+}
+
+// openAIMessage differs from Message in tool calls: arguments are
+// a JSON encoded string and results refer to the call by ID.
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+	// Images are sent as content parts with data URLs.
+	Images []string `json:"-"`
+}
+
+type openAIContentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageURL *struct {
+		URL string `json:"url"`
+	} `json:"image_url,omitempty"`
+}
+
+func (m openAIMessage) MarshalJSON() ([]byte, error) {
+	type message openAIMessage
+	if len(m.Images) == 0 {
+		return json.Marshal(message(m))
+	}
+
+	parts := []openAIContentPart{{Type: "text", Text: m.Content}}
+	for _, img := range m.Images {
+		part := openAIContentPart{Type: "image_url"}
+		part.ImageURL = &struct {
+			URL string `json:"url"`
+		}{URL: "data:" + imageType(img) + ";base64," + img}
+		parts = append(parts, part)
+	}
+	return json.Marshal(struct {
+		message
+		Content []openAIContentPart `json:"content"`
+	}{message(m), parts})
+}
+
+// imageType sniffs the MIME type of a base64 encoded image.
+func imageType(b64 string) string {
+	// 512 bytes are enough for sniffing, which is 684 base64 characters.
+	head, _ := base64.StdEncoding.DecodeString(b64[:min(len(b64), 684)])
+	return http.DetectContentType(head)
+}
+
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+type openAIChatResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+		Delta   openAIMessage `json:"delta"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+}
+
+type openAIEmbeddingsRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type openAIEmbeddingsResponse struct {
+	Model string `json:"model"`
+	Data  []struct {
+		Index     int       `json:"index"`
+		Embedding []float64 `json:"embedding"`
+	} `json:"data"`
+}
+
+type openAIModelsResponse struct {
+	Data []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+func NewOpenAI(baseURL *url.URL, apiKey string, client *http.Client) *OpenAI {
+	o := &OpenAI{http: httpClient{baseURL: baseURL, client: client}}
+	if apiKey != "" {
+		o.http.headers = map[string]string{"Authorization": "Bearer " + apiKey}
+	}
+	return o
+}
+
+func newOpenAIChatRequest(req ChatRequest) openAIChatRequest {
+	in := openAIChatRequest{
+		Model:       req.Model,
+		Messages:    make([]openAIMessage, len(req.Messages)),
+		Tools:       req.Tools,
+		Temperature: req.Options.Temperature,
+		TopP:        req.Options.TopP,
+		Seed:        req.Options.Seed,
+		MaxTokens:   req.Options.NumPredict,
+		Stop:        req.Options.Stop,
+	}
+
+	for i, m := range req.Messages {
+		in.Messages[i] = openAIMessage{Role: m.Role, Content: m.Content, ToolCallID: m.ToolCallID, Images: m.Images}
+		for _, call := range m.ToolCalls {
+			var c openAIToolCall
+			c.ID = call.ID
+			c.Type = "function"
+			c.Function.Name = call.Function.Name
+			c.Function.Arguments = string(call.Function.Arguments)
+			in.Messages[i].ToolCalls = append(in.Messages[i].ToolCalls, c)
+		}
+	}
+
+	switch {
+	case len(req.Format) == 0:
+	case string(req.Format) == `"json"`:
+		in.ResponseFormat = map[string]any{"type": "json_object"}
+	default:
+		in.ResponseFormat = map[string]any{
+			"type":        "json_schema",
+			"json_schema": map[string]any{"name": "response", "schema": req.Format},
+		}
+	}
+
+	return in
+}
+
+func (o *OpenAI) Generate(ctx context.Context, req GenerateRequest) (Response, error) {
+	return o.Chat(ctx, ChatFromGenerate(req))
+}
+
+func (o *OpenAI) Chat(ctx context.Context, req ChatRequest) (Response, error) {
+	var out openAIChatResponse
+	err := o.http.doJSON(ctx, http.MethodPost, "/v1/chat/completions", newOpenAIChatRequest(req), &out)
+	if err != nil {
+		return Response{}, err
+	}
+	if len(out.Choices) == 0 {
+		return Response{}, fmt.Errorf("llm server returned no choices")
+	}
+
+	message := out.Choices[0].Message
+	result := Response{Model: out.Model, Content: message.Content}
+	for _, c := range message.ToolCalls {
+		if c.Function.Arguments == "" {
+			c.Function.Arguments = "{}"
+		}
+		result.ToolCalls = append(result.ToolCalls, ToolCall{
+			ID:       c.ID,
+			Function: ToolCallFunction{Name: c.Function.Name, Arguments: json.RawMessage(c.Function.Arguments)},
+		})
+	}
+	if out.Usage != nil {
+		result.Usage = Usage{PromptTokens: out.Usage.PromptTokens, CompletionTokens: out.Usage.CompletionTokens}
+	}
+
+	return result, nil
+}
+
+func (o *OpenAI) Stream(ctx context.Context, req ChatRequest, fn func(Chunk) error) (Response, error) {
+	in := newOpenAIChatRequest(req)
+	in.Stream = true
+	in.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+
+	resp, err := o.http.do(ctx, http.MethodPost, "/v1/chat/completions", in)
+	if err != nil {
+		return Response{}, err
+	}
+	defer resp.Body.Close()
+
+	result := Response{Model: req.Model}
+	var content strings.Builder
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		data, ok := bytes.CutPrefix(scanner.Bytes(), []byte("data:"))
+		if !ok {
+			continue
+		}
+		data = bytes.TrimSpace(data)
+		if string(data) == "[DONE]" {
+			result.Content = content.String()
+			return result, nil
+		}
+
+		var chunk openAIChatResponse
+		err = json.Unmarshal(data, &chunk)
+		if err != nil {
+			return result, fmt.Errorf("failed to parse llm response: %w", err)
+		}
+		if chunk.Model != "" {
+			result.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			result.Usage = Usage{PromptTokens: chunk.Usage.PromptTokens, CompletionTokens: chunk.Usage.CompletionTokens}
+		}
+
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content == "" {
+				continue
+			}
+			content.WriteString(choice.Delta.Content)
+			err = fn(Chunk{Content: choice.Delta.Content})
+			if err != nil {
+				return result, err
+			}
+		}
+	}
+
+	err = scanner.Err()
+	if err == nil {
+		err = fmt.Errorf("llm stream ended unexpectedly: %w", io.ErrUnexpectedEOF)
+	}
+	result.Content = content.String()
+	return result, err
+}
+
+func (o *OpenAI) Embed(ctx context.Context, req EmbedRequest) (EmbedResponse, error) {
+	var out openAIEmbeddingsResponse
+	err := o.http.doJSON(ctx, http.MethodPost, "/v1/embeddings", openAIEmbeddingsRequest{Model: req.Model, Input: req.Input}, &out)
+	if err != nil {
+		return EmbedResponse{}, err
+	}
+
+	result := EmbedResponse{Model: req.Model, Embeddings: make([][]float64, len(req.Input))}
+	for _, d := range out.Data {
+		if d.Index < 0 || d.Index >= len(result.Embeddings) {
+			return EmbedResponse{}, fmt.Errorf("llm server returned embedding with unexpected index %d", d.Index)
+		}
+		result.Embeddings[d.Index] = d.Embedding
+	}
+
+	return result, nil
+}
+
+func (o *OpenAI) ListModels(ctx context.Context) ([]Model, error) {
+	var out openAIModelsResponse
+	err := o.http.doJSON(ctx, http.MethodGet, "/v1/models", nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	models := make([]Model, 0, len(out.Data))
+	for _, m := range out.Data {
+		models = append(models, Model{Name: m.ID})
+	}
+
+	return models, nil
+}
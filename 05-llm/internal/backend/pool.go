@@ -0,0 +1,358 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrNoUpstream       = errors.New("no healthy llm upstream")
+	ErrModelUnavailable = errors.New("model is not available on any healthy llm upstream")
+)
+
+type Upstream struct {
+	Name    string
+	Backend Backend
+}
+
+type UpstreamStatus struct {
+	Name        string    `json:"name"`
+	Healthy     bool      `json:"healthy"`
+	Outstanding int       `json:"outstanding"`
+	Models      []string  `json:"models"`
+	Error       string    `json:"error,omitempty"`
+	CheckedAt   time.Time `json:"checkedAt"`
+}
+
+type member struct {
+	Upstream
+
+	outstanding int
+	healthy     bool
+	// models is nil until the first successful health check,
+	// which means that any model is accepted.
+	models    map[string]bool
+	lastErr   error
+	checkedAt time.Time
+}
+
+// Pool balances requests between several upstream backends. It sends every
+// request to the healthy upstream with the fewest outstanding requests among
+// those serving the model and retries on another upstream when one fails
+// before producing any output.
+type Pool struct {
+	mu      sync.Mutex
+	members []*member
+	next    int
+}
+
+func NewPool(upstreams ...Upstream) *Pool {
+	p := &Pool{}
+	for _, u := range upstreams {
+		p.members = append(p.members, &member{Upstream: u, healthy: true})
+	}
+	return p
+}
+
+// Run probes upstreams every interval until ctx is done.
+func (p *Pool) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		p.CheckHealth(ctx, interval)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckHealth lists models of every upstream concurrently and updates their state.
+func (p *Pool) CheckHealth(ctx context.Context, timeout time.Duration) {
+	var wg sync.WaitGroup
+	for _, m := range p.members {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			models, err := m.Backend.ListModels(ctx)
+
+			p.mu.Lock()
+			defer p.mu.Unlock()
+
+			m.checkedAt = time.Now()
+			if err != nil {
+				if m.healthy {
+					log.Printf("Upstream %s is unhealthy: %v", m.Name, err)
+				}
+				m.healthy = false
+				m.lastErr = err
+				return
+			}
+			if !m.healthy {
+				log.Printf("Upstream %s is healthy again", m.Name)
+			}
+			m.healthy = true
+			m.lastErr = nil
+			m.models = make(map[string]bool, len(models))
+			for _, model := range models {
+				m.models[normalizeModel(model.Name)] = true
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (p *Pool) Status() []UpstreamStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	statuses := make([]UpstreamStatus, 0, len(p.members))
+	for _, m := range p.members {
+		s := UpstreamStatus{
+			Name:        m.Name,
+			Healthy:     m.healthy,
+			Outstanding: m.outstanding,
+			Models:      []string{},
+			CheckedAt:   m.checkedAt,
+		}
+		for model := range m.models {
+			s.Models = append(s.Models, model)
+		}
+		slices.Sort(s.Models)
+		if m.lastErr != nil {
+			s.Error = m.lastErr.Error()
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses
+}
+
+// pick reserves the least loaded healthy upstream serving model, skipping tried ones.
+func (p *Pool) pick(model string, tried map[*member]bool) (*member, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	model = normalizeModel(model)
+	var best *member
+	healthy := false
+	for i := range p.members {
+		// Start from a rotating offset so that ties are spread evenly.
+		m := p.members[(p.next+i)%len(p.members)]
+		if !m.healthy || tried[m] {
+			continue
+		}
+		healthy = true
+		if model != "" && m.models != nil && !m.models[model] {
+			continue
+		}
+		if best == nil || m.outstanding < best.outstanding {
+			best = m
+		}
+	}
+	p.next++
+
+	if best == nil {
+		if healthy {
+			return nil, fmt.Errorf("%w: %s", ErrModelUnavailable, model)
+		}
+		return nil, ErrNoUpstream
+	}
+
+	best.outstanding++
+	return best, nil
+}
+
+func (p *Pool) done(m *member, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	m.outstanding--
+	if err != nil && retryable(err) {
+		log.Printf("Upstream %s failed, marking unhealthy until next check: %v", m.Name, err)
+		m.healthy = false
+		m.lastErr = err
+	}
+}
+
+// do runs fn on upstreams until it succeeds, fails with a non-retryable
+// error or all suitable upstreams are tried.
+func (p *Pool) do(ctx context.Context, model string, fn func(Backend) error) error {
+	tried := make(map[*member]bool)
+	var lastErr error
+	for {
+		m, err := p.pick(model, tried)
+		if err != nil {
+			if lastErr != nil {
+				return lastErr
+			}
+			return err
+		}
+		tried[m] = true
+
+		err = fn(m.Backend)
+		p.done(m, err)
+		if err == nil || !retryable(err) || ctx.Err() != nil {
+			return err
+		}
+		lastErr = fmt.Errorf("upstream %s: %w", m.Name, err)
+	}
+}
+
+func (p *Pool) Generate(ctx context.Context, req GenerateRequest) (Response, error) {
+	var resp Response
+	err := p.do(ctx, req.Model, func(b Backend) error {
+		var err error
+		resp, err = b.Generate(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (p *Pool) Chat(ctx context.Context, req ChatRequest) (Response, error) {
+	var resp Response
+	err := p.do(ctx, req.Model, func(b Backend) error {
+		var err error
+		resp, err = b.Chat(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (p *Pool) Stream(ctx context.Context, req ChatRequest, fn func(Chunk) error) (Response, error) {
+	var resp Response
+	started := false
+	err := p.do(ctx, req.Model, func(b Backend) error {
+		var err error
+		resp, err = b.Stream(ctx, req, func(c Chunk) error {
+			started = true
+			return fn(c)
+		})
+		if err != nil && started {
+			// Part of the answer is already sent to the client, it can't be retried.
+			return streamStartedError{err}
+		}
+		return err
+	})
+
+	var startedErr streamStartedError
+	if errors.As(err, &startedErr) {
+		err = startedErr.err
+	}
+	return resp, err
+}
+
+func (p *Pool) Embed(ctx context.Context, req EmbedRequest) (EmbedResponse, error) {
+	var resp EmbedResponse
+	err := p.do(ctx, req.Model, func(b Backend) error {
+		var err error
+		resp, err = b.Embed(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+// ContextSize asks an upstream serving the model for its context length.
+func (p *Pool) ContextSize(ctx context.Context, model string) (int, error) {
+	var size int
+	err := p.do(ctx, model, func(b Backend) error {
+		sizer, ok := b.(ContextSizer)
+		if !ok {
+			return fmt.Errorf("%w for %s", ErrContextUnknown, model)
+		}
+		var err error
+		size, err = sizer.ContextSize(ctx, model)
+		return err
+	})
+	return size, err
+}
+
+// ListModels returns models available on healthy upstreams.
+func (p *Pool) ListModels(ctx context.Context) ([]Model, error) {
+	var models []Model
+	seen := make(map[string]bool)
+	for _, s := range p.Status() {
+		if !s.Healthy {
+			continue
+		}
+		for _, name := range s.Models {
+			if !seen[name] {
+				seen[name] = true
+				models = append(models, Model{Name: name})
+			}
+		}
+	}
+	if len(seen) == 0 && !p.healthy() {
+		return nil, ErrNoUpstream
+	}
+	return models, nil
+}
+
+func (p *Pool) healthy() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, m := range p.members {
+		if m.healthy {
+			return true
+		}
+	}
+	return false
+}
+
+type streamStartedError struct {
+	err error
+}
+
+func (e streamStartedError) Error() string {
+	return e.err.Error()
+}
+
+func (e streamStartedError) Unwrap() error {
+	return e.err
+}
+
+// retryable reports whether the request may succeed on another upstream:
+// the upstream is unreachable, dropped the connection before answering or
+// failed with a server error. Anything else, e.g. a response which cannot be
+// parsed, would most likely fail the same way on every upstream.
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var started streamStartedError
+	if errors.As(err, &started) {
+		return false
+	}
+	var statusErr StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError
+	}
+	// *url.Error returned by http.Client implements net.Error.
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// normalizeModel makes "llama3.2" and "llama3.2:latest" the same model.
+func normalizeModel(name string) string {
+	return strings.TrimSuffix(name, ":latest")
+}
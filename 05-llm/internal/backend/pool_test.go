@@ -0,0 +1,248 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// stubBackend answers with its name or fails with err.
+type stubBackend struct {
+	Fake
+	name   string
+	models []string
+	err    error
+	// chunks are streamed before failing with err.
+	chunks int
+	calls  int
+	mu     sync.Mutex
+}
+
+func (s *stubBackend) Generate(ctx context.Context, req GenerateRequest) (Response, error) {
+	s.mu.Lock()
+	s.calls++
+	s.mu.Unlock()
+	if s.err != nil {
+		return Response{}, s.err
+	}
+	return Response{Content: s.name}, nil
+}
+
+func (s *stubBackend) Stream(ctx context.Context, req ChatRequest, fn func(Chunk) error) (Response, error) {
+	s.calls++
+	for i := 0; i < s.chunks; i++ {
+		fn(Chunk{Content: s.name})
+	}
+	if s.err != nil {
+		return Response{}, s.err
+	}
+	return Response{Content: s.name}, nil
+}
+
+func (s *stubBackend) ListModels(ctx context.Context) ([]Model, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	var models []Model
+	for _, m := range s.models {
+		models = append(models, Model{Name: m})
+	}
+	return models, nil
+}
+
+func TestPoolModelRouting(t *testing.T) {
+	a := &stubBackend{name: "a", models: []string{"llama3.2:latest"}}
+	b := &stubBackend{name: "b", models: []string{"qwen:7b"}}
+	p := NewPool(Upstream{Name: "a", Backend: a}, Upstream{Name: "b", Backend: b})
+	p.CheckHealth(context.Background(), time.Second)
+
+	tests := []struct {
+		model   string
+		want    string
+		wantErr error
+	}{
+		{model: "llama3.2", want: "a"},
+		{model: "qwen:7b", want: "b"},
+		{model: "mistral", wantErr: ErrModelUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			resp, err := p.Generate(context.Background(), GenerateRequest{Model: tt.model})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Generate() error = %v, want %v", err, tt.wantErr)
+			}
+			if resp.Content != tt.want {
+				t.Errorf("Generate() served by %q, want %q", resp.Content, tt.want)
+			}
+		})
+	}
+}
+
+func TestPoolLeastOutstanding(t *testing.T) {
+	a := &stubBackend{name: "a"}
+	b := &stubBackend{name: "b"}
+	p := NewPool(Upstream{Name: "a", Backend: a}, Upstream{Name: "b", Backend: b})
+
+	busy, err := p.pick("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		resp, err := p.Generate(context.Background(), GenerateRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Content == busy.Name {
+			t.Errorf("request %d was sent to busy upstream %s", i, busy.Name)
+		}
+	}
+}
+
+func TestPoolFailover(t *testing.T) {
+	a := &stubBackend{name: "a", err: StatusError{StatusCode: 500}}
+	b := &stubBackend{name: "b"}
+	p := NewPool(Upstream{Name: "a", Backend: a}, Upstream{Name: "b", Backend: b})
+
+	for i := 0; i < 2; i++ {
+		resp, err := p.Generate(context.Background(), GenerateRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Content != "b" {
+			t.Errorf("expected failover to b, got %q", resp.Content)
+		}
+	}
+	if a.calls != 1 {
+		t.Errorf("expected failed upstream to be skipped until next health check, got %d calls", a.calls)
+	}
+
+	p.CheckHealth(context.Background(), time.Second)
+	if _, err := p.Generate(context.Background(), GenerateRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if p.Status()[0].Healthy {
+		t.Error("expected upstream failing health checks to stay unhealthy")
+	}
+}
+
+func TestPoolClientErrorIsNotRetried(t *testing.T) {
+	a := &stubBackend{name: "a", err: StatusError{StatusCode: 400}}
+	b := &stubBackend{name: "b", err: StatusError{StatusCode: 400}}
+	p := NewPool(Upstream{Name: "a", Backend: a}, Upstream{Name: "b", Backend: b})
+
+	_, err := p.Generate(context.Background(), GenerateRequest{})
+	var statusErr StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != 400 {
+		t.Fatalf("expected 400 StatusError, got %v", err)
+	}
+	if a.calls+b.calls != 1 {
+		t.Errorf("expected a single attempt, got %d", a.calls+b.calls)
+	}
+}
+
+func TestPoolDecodeErrorIsNotRetried(t *testing.T) {
+	var v any
+	decodeErr := fmt.Errorf("failed to parse llm response: %w", json.Unmarshal([]byte(`{"model":`), &v))
+
+	a := &stubBackend{name: "a", err: decodeErr}
+	b := &stubBackend{name: "b", err: decodeErr}
+	p := NewPool(Upstream{Name: "a", Backend: a}, Upstream{Name: "b", Backend: b})
+
+	_, err := p.Generate(context.Background(), GenerateRequest{})
+	if !errors.Is(err, decodeErr) {
+		t.Fatalf("expected decode error, got %v", err)
+	}
+	if a.calls+b.calls != 1 {
+		t.Errorf("expected a single attempt, got %d", a.calls+b.calls)
+	}
+	for _, s := range p.Status() {
+		if !s.Healthy {
+			t.Errorf("upstream %s was marked unhealthy by a decode error", s.Name)
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "Server error", err: StatusError{StatusCode: 503}, expected: true},
+		{name: "Client error", err: StatusError{StatusCode: 404}},
+		{name: "Connection refused", err: &url.Error{Op: "Post", URL: "http://gpu:11434", Err: syscall.ECONNREFUSED}, expected: true},
+		{name: "Connection reset", err: fmt.Errorf("failed to request llm server: %w", syscall.ECONNRESET), expected: true},
+		{name: "Stream ended before answering", err: io.ErrUnexpectedEOF, expected: true},
+		{name: "Stream started", err: streamStartedError{err: io.ErrUnexpectedEOF}},
+		{name: "Cancelled", err: &url.Error{Op: "Post", URL: "http://gpu:11434", Err: context.Canceled}},
+		{name: "Unparsable response", err: fmt.Errorf("failed to parse llm response: %w", &json.SyntaxError{})},
+		{name: "Unknown context length", err: ErrContextUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryable(tt.err); got != tt.expected {
+				t.Errorf("retryable(%v) = %v, want %v", tt.err, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPoolStreamRetry(t *testing.T) {
+	failing := syscall.ECONNRESET
+
+	a := &stubBackend{name: "a", err: failing}
+	b := &stubBackend{name: "b"}
+	p := NewPool(Upstream{Name: "a", Backend: a}, Upstream{Name: "b", Backend: b})
+	resp, err := p.Stream(context.Background(), ChatRequest{}, func(Chunk) error { return nil })
+	if err != nil || resp.Content != "b" {
+		t.Errorf("expected stream failing before first chunk to be retried, got %q, %v", resp.Content, err)
+	}
+
+	a = &stubBackend{name: "a", err: failing, chunks: 1}
+	b = &stubBackend{name: "b", err: failing, chunks: 1}
+	p = NewPool(Upstream{Name: "a", Backend: a}, Upstream{Name: "b", Backend: b})
+	_, err = p.Stream(context.Background(), ChatRequest{}, func(Chunk) error { return nil })
+	if !errors.Is(err, failing) {
+		t.Errorf("expected stream error, got %v", err)
+	}
+	if a.calls+b.calls != 1 {
+		t.Errorf("expected started stream not to be retried, got %d attempts", a.calls+b.calls)
+	}
+}
+
+func TestPoolContextUnknown(t *testing.T) {
+	a := &stubBackend{name: "a"}
+	b := &stubBackend{name: "b"}
+	p := NewPool(Upstream{Name: "a", Backend: a}, Upstream{Name: "b", Backend: b})
+
+	_, err := p.ContextSize(context.Background(), "llama3.2")
+	if !errors.Is(err, ErrContextUnknown) {
+		t.Fatalf("expected ErrContextUnknown, got %v", err)
+	}
+	for _, s := range p.Status() {
+		if !s.Healthy {
+			t.Errorf("upstream %s was marked unhealthy by an unknown context length", s.Name)
+		}
+	}
+	if _, err := p.Generate(context.Background(), GenerateRequest{}); err != nil {
+		t.Errorf("pool stopped serving: %v", err)
+	}
+}
+
+func TestPoolNoUpstream(t *testing.T) {
+	a := &stubBackend{name: "a", err: syscall.ECONNREFUSED}
+	p := NewPool(Upstream{Name: "a", Backend: a})
+	p.CheckHealth(context.Background(), time.Second)
+
+	if _, err := p.Generate(context.Background(), GenerateRequest{}); !errors.Is(err, ErrNoUpstream) {
+		t.Errorf("expected ErrNoUpstream, got %v", err)
+	}
+}
@@ -0,0 +1,575 @@
+// Package batch runs asynchronous batch generation jobs. Jobs are stored on
+// disk, one directory per job, so that unfinished jobs resume after restart:
+//
+//	<dir>/<id>/job.json       job metadata
+//	<dir>/<id>/items.jsonl    submitted items
+//	<dir>/<id>/results.jsonl  results in completion order
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/auth"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/queue"
+)
+
+const (
+	jobFile     = "job.json"
+	itemsFile   = "items.jsonl"
+	resultsFile = "results.jsonl"
+
+	maxLineSize = 1 << 20
+	// overloadRetry is the minimal delay before retrying an item the queue rejected.
+	overloadRetry = time.Second
+)
+
+var (
+	ErrNotFound = errors.New("batch job not found")
+	ErrNoItems  = errors.New("batch is empty")
+)
+
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusCompleted Status = "completed"
+)
+
+// Item is a single prompt of a batch.
+type Item struct {
+	// ID is an optional identifier chosen by the client and copied to the result.
+	ID      string          `json:"id,omitempty"`
+	Prompt  string          `json:"prompt"`
+	System  string          `json:"system,omitempty"`
+	Model   string          `json:"model,omitempty"`
+	Options backend.Options `json:"options,omitempty"`
+}
+
+// Result is the outcome of the item at Index.
+type Result struct {
+	Index    int           `json:"index"`
+	ID       string        `json:"id,omitempty"`
+	Model    string        `json:"model"`
+	Response string        `json:"response,omitempty"`
+	Usage    backend.Usage `json:"usage"`
+	Error    string        `json:"error,omitempty"`
+}
+
+type Job struct {
+	ID         string        `json:"id"`
+	Client     string        `json:"client,omitempty"`
+	Status     Status        `json:"status"`
+	Total      int           `json:"total"`
+	Succeeded  int           `json:"succeeded"`
+	Failed     int           `json:"failed"`
+	Usage      backend.Usage `json:"usage"`
+	CreatedAt  time.Time     `json:"createdAt"`
+	FinishedAt *time.Time    `json:"finishedAt,omitempty"`
+}
+
+type Config struct {
+	Dir string
+	// Concurrency is the number of items processed at once across all jobs.
+	Concurrency int
+	// ItemTimeout bounds the generation of a single item; 0 means no limit.
+	ItemTimeout time.Duration
+	// Limiter, if set, is consulted before every item of a job with a client,
+	// as the quota may run out long after the job was accepted.
+	Limiter Limiter
+}
+
+// Limiter enforces the rate limit and token quotas of a client, see auth.Manager.
+type Limiter interface {
+	Allow(client string) (auth.Remaining, error)
+}
+
+type job struct {
+	Job
+	items []Item
+	done  map[int]bool
+	// size is the length of the results file up to the last complete result.
+	size int64
+}
+
+type task struct {
+	job   *job
+	index int
+}
+
+// Manager stores jobs and processes them in submission order.
+type Manager struct {
+	backend backend.Backend
+	cfg     Config
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	pending []*job
+	notify  chan struct{}
+}
+
+// Open loads jobs stored in cfg.Dir. Unfinished jobs are queued again and
+// processed once Run is called.
+func Open(b backend.Backend, cfg Config) (*Manager, error) {
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+
+	err := os.MkdirAll(cfg.Dir, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create batch directory: %w", err)
+	}
+
+	m := &Manager{
+		backend: b,
+		cfg:     cfg,
+		jobs:    make(map[string]*job),
+		notify:  make(chan struct{}, 1),
+	}
+
+	entries, err := os.ReadDir(cfg.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read batch directory: %w", err)
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		j, err := m.load(e.Name())
+		if err != nil {
+			// The job may have been only partly stored when the server stopped.
+			log.Printf("Skipping batch job %s: %v", e.Name(), err)
+			continue
+		}
+		m.jobs[j.ID] = j
+		if j.Status != StatusCompleted {
+			m.pending = append(m.pending, j)
+		}
+	}
+	slices.SortFunc(m.pending, func(a, b *job) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	return m, nil
+}
+
+func (m *Manager) load(id string) (*job, error) {
+	dir := filepath.Join(m.cfg.Dir, id)
+
+	b, err := os.ReadFile(filepath.Join(dir, jobFile))
+	if err != nil {
+		return nil, err
+	}
+	j := &job{done: make(map[int]bool)}
+	err = json.Unmarshal(b, &j.Job)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err = os.ReadFile(filepath.Join(dir, itemsFile))
+	if err != nil {
+		return nil, err
+	}
+	j.items, err = ParseItems(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	// Counters are rebuilt from the results, which are the source of truth.
+	j.Succeeded, j.Failed, j.Usage = 0, 0, backend.Usage{}
+	b, err = os.ReadFile(filepath.Join(dir, resultsFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	// A result cut short by a crash is dropped and its item processed again.
+	if i := bytes.LastIndexByte(b, '\n'); i+1 < len(b) {
+		b = b[:i+1]
+		err = os.Truncate(filepath.Join(dir, resultsFile), int64(len(b)))
+		if err != nil {
+			return nil, err
+		}
+	}
+	j.size = int64(len(b))
+	for line := range bytes.Lines(b) {
+		var res Result
+		if json.Unmarshal(line, &res) != nil || res.Index < 0 || res.Index >= len(j.items) {
+			continue
+		}
+		j.count(res)
+	}
+	return j, nil
+}
+
+// count must be called with the manager's mutex held or before the job is shared.
+func (j *job) count(res Result) {
+	if j.done[res.Index] {
+		return
+	}
+	j.done[res.Index] = true
+	if res.Error != "" {
+		j.Failed++
+	} else {
+		j.Succeeded++
+	}
+	j.Usage.PromptTokens += res.Usage.PromptTokens
+	j.Usage.CompletionTokens += res.Usage.CompletionTokens
+}
+
+// ParseItems reads items from JSONL, one item per line. Empty lines are skipped.
+func ParseItems(r io.Reader) ([]Item, error) {
+	var items []Item
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), maxLineSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var item Item
+		err := json.Unmarshal(scanner.Bytes(), &item)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if item.Prompt == "" {
+			return nil, fmt.Errorf("line %d: prompt is empty", line)
+		}
+		items = append(items, item)
+	}
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, ErrNoItems
+	}
+	return items, nil
+}
+
+// Submit stores a new job and queues it for processing.
+func (m *Manager) Submit(client string, items []Item) (Job, error) {
+	if len(items) == 0 {
+		return Job{}, ErrNoItems
+	}
+
+	j := &job{
+		Job: Job{
+			ID:        rand.Text(),
+			Client:    client,
+			Status:    StatusQueued,
+			Total:     len(items),
+			CreatedAt: time.Now().UTC(),
+		},
+		items: items,
+		done:  make(map[int]bool),
+	}
+
+	dir := filepath.Join(m.cfg.Dir, j.ID)
+	err := os.Mkdir(dir, 0o755)
+	if err != nil {
+		return Job{}, fmt.Errorf("failed to create batch job: %w", err)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, item := range items {
+		enc.Encode(item)
+	}
+	err = writeFile(filepath.Join(dir, itemsFile), buf.Bytes())
+	if err == nil {
+		err = m.save(j.Job)
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return Job{}, fmt.Errorf("failed to store batch job: %w", err)
+	}
+
+	submitted := j.Job
+	m.mu.Lock()
+	m.jobs[j.ID] = j
+	m.pending = append(m.pending, j)
+	m.mu.Unlock()
+
+	select {
+	case m.notify <- struct{}{}:
+	default:
+	}
+
+	return submitted, nil
+}
+
+// Get returns the current state of the job.
+func (m *Manager) Get(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	return j.Job, nil
+}
+
+// Results returns a reader of the results written so far as JSONL.
+func (m *Manager) Results(id string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	var size int64
+	if ok {
+		size = j.size
+	}
+	m.mu.Unlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	f, err := os.Open(filepath.Join(m.cfg.Dir, id, resultsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// A result may be in the middle of being appended, so only complete ones are read.
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(f, size), f}, nil
+}
+
+// Run processes queued jobs until ctx is done. Items interrupted by the
+// cancellation are not recorded and are processed again by the next Run.
+func (m *Manager) Run(ctx context.Context) {
+	tasks := make(chan task)
+	var wg sync.WaitGroup
+	for range m.cfg.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range tasks {
+				m.process(ctx, t)
+			}
+		}()
+	}
+	defer wg.Wait()
+	defer close(tasks)
+
+	for {
+		j := m.next()
+		if j == nil {
+			select {
+			case <-m.notify:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		for i := range j.items {
+			m.mu.Lock()
+			done := j.done[i]
+			m.mu.Unlock()
+			if done {
+				continue
+			}
+
+			select {
+			case tasks <- task{job: j, index: i}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// next removes the first pending job from the queue and marks it as running.
+func (m *Manager) next() *job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.pending) == 0 {
+		return nil
+	}
+	j := m.pending[0]
+	m.pending = m.pending[1:]
+
+	if j.Status == StatusQueued {
+		j.Status = StatusRunning
+		m.saveLocked(j)
+	}
+	// Jobs with all results recorded before a restart only need to be finished.
+	m.finishLocked(j)
+	return j
+}
+
+func (m *Manager) process(ctx context.Context, t task) {
+	item := t.job.items[t.index]
+	res := Result{Index: t.index, ID: item.ID, Model: item.Model}
+
+	resp, err := m.generate(ctx, t.job.Client, item)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		res.Error = err.Error()
+	} else {
+		res.Response = resp.Content
+		res.Usage = resp.Usage
+		if resp.Model != "" {
+			res.Model = resp.Model
+		}
+	}
+
+	err = m.record(t.job, res)
+	if err != nil {
+		log.Printf("Error recording batch result of job %s: %v", t.job.ID, err)
+	}
+}
+
+// generate runs the item in the batch queue lane, waiting whenever the queue
+// is full or the client is over its rate limit. An exhausted quota fails the item.
+func (m *Manager) generate(ctx context.Context, client string, item Item) (backend.Response, error) {
+	ctx = queue.WithPriority(ctx, queue.Batch, 0)
+	if client != "" {
+		ctx = auth.WithClient(ctx, client)
+	}
+
+	for {
+		if m.cfg.Limiter != nil && client != "" {
+			_, err := m.cfg.Limiter.Allow(client)
+			var rateLimit auth.RateLimitError
+			if errors.As(err, &rateLimit) {
+				err = wait(ctx, rateLimit.RetryAfter)
+				if err != nil {
+					return backend.Response{}, err
+				}
+				continue
+			}
+			if err != nil {
+				return backend.Response{}, err
+			}
+		}
+
+		itemCtx, cancel := ctx, context.CancelFunc(func() {})
+		if m.cfg.ItemTimeout > 0 {
+			itemCtx, cancel = context.WithTimeout(ctx, m.cfg.ItemTimeout)
+		}
+		resp, err := m.backend.Generate(itemCtx, backend.GenerateRequest{
+			Model:   item.Model,
+			Prompt:  item.Prompt,
+			System:  item.System,
+			Options: item.Options,
+		})
+		cancel()
+
+		var overload queue.OverloadError
+		if !errors.As(err, &overload) {
+			return resp, err
+		}
+		err = wait(ctx, max(overload.RetryAfter, overloadRetry))
+		if err != nil {
+			return resp, err
+		}
+	}
+}
+
+// wait sleeps for d unless ctx is done first.
+func wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *Manager) record(j *job, res Result) error {
+	b, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(filepath.Join(m.cfg.Dir, j.ID, resultsFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	n, err := f.Write(b)
+	closeErr := f.Close()
+	if err != nil || closeErr != nil {
+		// A partially written result is truncated on the next load.
+		return errors.Join(err, closeErr)
+	}
+	j.size += int64(n)
+
+	j.count(res)
+	m.finishLocked(j)
+	return nil
+}
+
+// finishLocked completes the job once every item has a result.
+func (m *Manager) finishLocked(j *job) {
+	if j.Status == StatusCompleted || len(j.done) < j.Total {
+		return
+	}
+
+	now := time.Now().UTC()
+	j.Status = StatusCompleted
+	j.FinishedAt = &now
+	m.saveLocked(j)
+}
+
+func (m *Manager) saveLocked(j *job) {
+	err := m.save(j.Job)
+	if err != nil {
+		log.Printf("Error saving batch job %s: %v", j.ID, err)
+	}
+}
+
+func (m *Manager) save(j Job) error {
+	b, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(m.cfg.Dir, j.ID, jobFile), b)
+}
+
+// writeFile replaces the file atomically.
+func writeFile(path string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(content)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,247 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/auth"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+)
+
+func TestParseItems(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		expectedItems int
+		expectedErr   string
+	}{
+		{name: "Items", input: `{"prompt":"a"}` + "\n\n" + `{"id":"x","prompt":"b","model":"llama3.2"}` + "\n", expectedItems: 2},
+		{name: "No trailing newline", input: `{"prompt":"a"}`, expectedItems: 1},
+		{name: "Empty", input: "\n", expectedErr: "batch is empty"},
+		{name: "Invalid JSON", input: `{"prompt":"a"}` + "\n{", expectedErr: "line 2"},
+		{name: "Empty prompt", input: `{"id":"x"}`, expectedErr: "line 1: prompt is empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, err := ParseItems(strings.NewReader(tt.input))
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Fatalf("expected error %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(items) != tt.expectedItems {
+				t.Errorf("got %d items, want %d", len(items), tt.expectedItems)
+			}
+		})
+	}
+}
+
+// waitCompleted polls the job until it is completed.
+func waitCompleted(t *testing.T, m *Manager, id string) Job {
+	t.Helper()
+
+	for range 200 {
+		job, err := m.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status == StatusCompleted {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("job did not complete")
+	return Job{}
+}
+
+func readResults(t *testing.T, m *Manager, id string) map[int]Result {
+	t.Helper()
+
+	r, err := m.Results(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := make(map[int]Result)
+	for line := range bytes.Lines(b) {
+		var res Result
+		if err := json.Unmarshal(line, &res); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := results[res.Index]; ok {
+			t.Errorf("duplicate result for item %d", res.Index)
+		}
+		results[res.Index] = res
+	}
+	return results
+}
+
+func TestManager(t *testing.T) {
+	fake := backend.NewFake()
+	m, err := Open(fake, Config{Dir: t.TempDir(), Concurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx)
+
+	items := []Item{
+		{ID: "a", Prompt: "one", Model: "llama3.2"},
+		{ID: "b", Prompt: "two", Model: "llama3.2"},
+		{ID: "c", Prompt: "three", Model: "llama3.2"},
+	}
+	job, err := m.Submit("alice", items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != StatusQueued || job.Total != 3 {
+		t.Errorf("unexpected submitted job %+v", job)
+	}
+
+	job = waitCompleted(t, m, job.ID)
+	if job.Succeeded != 3 || job.Failed != 0 || job.FinishedAt == nil {
+		t.Errorf("unexpected completed job %+v", job)
+	}
+
+	results := readResults(t, m, job.ID)
+	for i, item := range items {
+		res := results[i]
+		if res.ID != item.ID || res.Response != "echo: "+item.Prompt || res.Model != "llama3.2" {
+			t.Errorf("unexpected result %+v for item %+v", res, item)
+		}
+	}
+
+	if _, err := m.Get("missing"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := m.Submit("alice", nil); err != ErrNoItems {
+		t.Errorf("expected ErrNoItems, got %v", err)
+	}
+}
+
+func TestManagerLimits(t *testing.T) {
+	tests := []struct {
+		name              string
+		client            auth.ClientConfig
+		expectedSucceeded int
+		expectedError     string
+	}{
+		// Every item uses 3 tokens.
+		{name: "Quota exhausted midway", client: auth.ClientConfig{DailyTokens: 4}, expectedSucceeded: 2, expectedError: "daily token quota exceeded"},
+		{name: "Rate limited", client: auth.ClientConfig{RequestsPerSecond: 100, Burst: 1}, expectedSucceeded: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.client.Name, tt.client.KeyHash = "alice", auth.HashKey("secret")
+			manager, err := auth.NewManager(auth.Config{Clients: []auth.ClientConfig{tt.client}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := Open(auth.NewBackend(backend.NewFake(), manager), Config{Dir: t.TempDir(), Limiter: manager})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go m.Run(ctx)
+
+			job, err := m.Submit("alice", []Item{
+				{Prompt: "one", Model: "llama3.2"},
+				{Prompt: "two", Model: "llama3.2"},
+				{Prompt: "three", Model: "llama3.2"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			job = waitCompleted(t, m, job.ID)
+			if job.Succeeded != tt.expectedSucceeded || job.Failed != 3-tt.expectedSucceeded {
+				t.Errorf("unexpected completed job %+v", job)
+			}
+			results := readResults(t, m, job.ID)
+			for i := tt.expectedSucceeded; i < 3; i++ {
+				if results[i].Error != tt.expectedError {
+					t.Errorf("unexpected result %+v, want error %q", results[i], tt.expectedError)
+				}
+			}
+		})
+	}
+}
+
+func TestManagerResume(t *testing.T) {
+	dir := t.TempDir()
+	fake := backend.NewFake()
+
+	m, err := Open(fake, Config{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := m.Submit("", []Item{
+		{Prompt: "one", Model: "llama3.2"},
+		{Prompt: "two", Model: "llama3.2"},
+		{Prompt: "three", Model: "llama3.2"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a server stopped after one result and in the middle of writing another.
+	results := `{"index":1,"model":"llama3.2","response":"echo: two","usage":{"promptTokens":1,"completionTokens":2}}` + "\n" + `{"index":0,"mod`
+	err = os.WriteFile(filepath.Join(dir, job.ID, resultsFile), []byte(results), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err = Open(fake, Config{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err = m.Get(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != StatusQueued || job.Succeeded != 1 || job.Usage.CompletionTokens != 2 {
+		t.Errorf("unexpected resumed job %+v", job)
+	}
+	if got := readResults(t, m, job.ID); len(got) != 1 {
+		t.Errorf("expected the partial result to be dropped, got %+v", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx)
+
+	job = waitCompleted(t, m, job.ID)
+	if job.Succeeded != 3 {
+		t.Errorf("unexpected completed job %+v", job)
+	}
+	if got := readResults(t, m, job.ID); len(got) != 3 {
+		t.Errorf("expected 3 results, got %+v", got)
+	}
+
+	// Completed jobs are loaded but not processed again.
+	m, err = Open(fake, Config{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.pending) != 0 {
+		t.Errorf("completed job was queued again")
+	}
+}
@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+)
+
+// Cache is an LRU cache of generated responses with TTL, entry count
+// and total size limits. Zero limits mean unlimited.
+type Cache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	maxBytes   int
+	size       int
+	lru        *list.List
+	items      map[string]*list.Element
+	now        func() time.Time
+}
+
+type entry struct {
+	key     string
+	resp    backend.Response
+	size    int
+	expires time.Time
+}
+
+func New(ttl time.Duration, maxEntries, maxBytes int) *Cache {
+	return &Cache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		lru:        list.New(),
+		items:      make(map[string]*list.Element),
+		now:        time.Now,
+	}
+}
+
+// Key returns a hash identifying the generation: kind of the call, model,
+// messages and sampling options. Insignificant differences such as
+// surrounding whitespace or the order of stop sequences are normalized away.
+func Key(kind string, req backend.ChatRequest) string {
+	normalized := struct {
+		Kind     string
+		Model    string
+		Messages []backend.Message
+		Options  backend.Options
+		Format   json.RawMessage
+	}{
+		Kind:    kind,
+		Model:   strings.ToLower(strings.TrimSpace(req.Model)),
+		Options: req.Options,
+		Format:  req.Format,
+	}
+	for _, m := range req.Messages {
+		normalized.Messages = append(normalized.Messages, backend.Message{
+			Role:    m.Role,
+			Content: strings.TrimSpace(m.Content),
+			Images:  m.Images,
+		})
+	}
+	normalized.Options.Stop = slices.Sorted(slices.Values(req.Options.Stop))
+
+	b, _ := json.Marshal(normalized)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *Cache) Get(key string) (backend.Response, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return backend.Response{}, false
+	}
+
+	e := el.Value.(*entry)
+	if c.ttl > 0 && c.now().After(e.expires) {
+		c.remove(el)
+		return backend.Response{}, false
+	}
+
+	c.lru.MoveToFront(el)
+	return e.resp, true
+}
+
+func (c *Cache) Set(key string, resp backend.Response) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	size := len(key) + len(resp.Model) + len(resp.Content)
+	if c.maxBytes > 0 && size > c.maxBytes {
+		return
+	}
+
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+
+	e := &entry{key: key, resp: resp, size: size, expires: c.now().Add(c.ttl)}
+	c.items[key] = c.lru.PushFront(e)
+	c.size += size
+
+	for (c.maxEntries > 0 && c.lru.Len() > c.maxEntries) || (c.maxBytes > 0 && c.size > c.maxBytes) {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lru.Len()
+}
+
+func (c *Cache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*entry)
+	delete(c.items, e.key)
+	c.size -= e.size
+}
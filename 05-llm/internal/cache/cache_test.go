@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+)
+
+func TestKey(t *testing.T) {
+	zero := 0.0
+	one := 1.0
+	base := backend.ChatRequest{
+		Model:    "llama3.2",
+		Messages: []backend.Message{{Role: "user", Content: "hello"}},
+		Options:  backend.Options{Temperature: &zero, Stop: []string{"a", "b"}},
+	}
+
+	tests := []struct {
+		name string
+		req  backend.ChatRequest
+		same bool
+	}{
+		{
+			name: "Whitespace and case are normalized",
+			req: backend.ChatRequest{
+				Model:    " Llama3.2",
+				Messages: []backend.Message{{Role: "user", Content: "  hello\n"}},
+				Options:  backend.Options{Temperature: &zero, Stop: []string{"b", "a"}},
+			},
+			same: true,
+		},
+		{
+			name: "Different prompt",
+			req: backend.ChatRequest{
+				Model:    "llama3.2",
+				Messages: []backend.Message{{Role: "user", Content: "hi"}},
+				Options:  backend.Options{Temperature: &zero, Stop: []string{"a", "b"}},
+			},
+		},
+		{
+			name: "Different options",
+			req: backend.ChatRequest{
+				Model:    "llama3.2",
+				Messages: []backend.Message{{Role: "user", Content: "hello"}},
+				Options:  backend.Options{Temperature: &one, Stop: []string{"a", "b"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Key("generate", tt.req) == Key("generate", base); got != tt.same {
+				t.Errorf("keys equal = %v, want %v", got, tt.same)
+			}
+		})
+	}
+
+	if Key("generate", base) == Key("chat", base) {
+		t.Error("generate and chat requests must have different keys")
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	now := time.Now()
+	c := New(time.Minute, 0, 0)
+	c.now = func() time.Time { return now }
+
+	c.Set("k", backend.Response{Content: "v"})
+	if resp, ok := c.Get("k"); !ok || resp.Content != "v" {
+		t.Fatalf("Get() = %+v, %v; want hit", resp, ok)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := c.Get("k"); ok {
+		t.Error("expected expired entry to miss")
+	}
+	if c.Len() != 0 {
+		t.Errorf("expected expired entry to be removed, got %d entries", c.Len())
+	}
+}
+
+func TestCacheLimits(t *testing.T) {
+	c := New(time.Minute, 2, 0)
+	c.Set("a", backend.Response{Content: "1"})
+	c.Set("b", backend.Response{Content: "2"})
+	c.Get("a")
+	c.Set("c", backend.Response{Content: "3"})
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("expected least recently used entry to be evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error("expected recently used entry to be kept")
+	}
+
+	c = New(time.Minute, 0, 10)
+	c.Set("a", backend.Response{Content: "12345"})
+	c.Set("b", backend.Response{Content: "12345"})
+	if c.Len() != 1 {
+		t.Errorf("expected size limit to keep 1 entry, got %d", c.Len())
+	}
+	c.Set("c", backend.Response{Content: "too large to fit"})
+	if _, ok := c.Get("c"); ok {
+		t.Error("expected entry larger than the limit not to be cached")
+	}
+}
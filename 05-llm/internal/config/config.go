@@ -0,0 +1,262 @@
+// Package config holds the settings of the service that can be changed
+// without a restart. They come from the environment variables and can be
+// overridden by a YAML or JSON file, which is reloaded when it changes.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/auth"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/models"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/session"
+)
+
+const DefaultTargetURL = "http://localhost:11434"
+
+// Config is the service configuration.
+//
+//	port: "8080"
+//	backend:
+//	  kind: ollama
+//	  urls: [http://gpu-1:11434, http://gpu-2:11434]
+//	models:
+//	  default: llama3.2
+//	  allowed: [llama3.2, llava]
+//	  vision: [llava]
+//	  contextSizes: {llama3.2: 8192}
+//	limits:
+//	  queue: {maxInFlight: 4, maxWaiting: 64, timeout: 30s}
+//	apiKeys:
+//	  clients:
+//	    - {name: team-a, keyHash: "sha256:...", dailyTokens: 100000}
+//	templates:
+//	  dir: /var/lib/llm/templates
+//	timeouts:
+//	  request: 2m
+//	  maxRequest: 10m
+//	  shutdown: 30s
+//	context:
+//	  strategy: summarize
+//	  summaryModel: llama3.2:1b
+type Config struct {
+	// Port is only read at startup.
+	Port    string        `yaml:"port"`
+	Backend Backend       `yaml:"backend"`
+	Models  models.Config `yaml:"models"`
+	Limits  Limits        `yaml:"limits"`
+	// APIKeys enables authentication when set.
+	APIKeys   *auth.Config `yaml:"apiKeys"`
+	Templates Templates    `yaml:"templates"`
+	Timeouts  Timeouts     `yaml:"timeouts"`
+	Context   Context      `yaml:"context"`
+}
+
+type Backend struct {
+	Kind string   `yaml:"kind"`
+	URLs []string `yaml:"urls"`
+	// APIKey authenticates to OpenAI-compatible servers.
+	APIKey string `yaml:"apiKey"`
+	// Models served by the fake backend.
+	Models              []string      `yaml:"models"`
+	HealthCheckInterval time.Duration `yaml:"healthCheckInterval"`
+	// ContextLength is the num_ctx every Ollama generation runs with, zero
+	// leaves it to the Modelfile of the model.
+	ContextLength int `yaml:"contextLength"`
+}
+
+type Limits struct {
+	Queue Queue `yaml:"queue"`
+	// StructuredRetries limits the repair attempts of structured output.
+	StructuredRetries int   `yaml:"structuredRetries"`
+	ToolIterations    int   `yaml:"toolIterations"`
+	Images            Image `yaml:"images"`
+}
+
+// Queue limits the concurrent llm requests; MaxInFlight below one disables the queue.
+type Queue struct {
+	MaxInFlight int           `yaml:"maxInFlight"`
+	MaxWaiting  int           `yaml:"maxWaiting"`
+	Timeout     time.Duration `yaml:"timeout"`
+}
+
+type Image struct {
+	MaxBytes     int64 `yaml:"maxBytes"`
+	MaxDimension int   `yaml:"maxDimension"`
+}
+
+// Templates enables the prompt template library when Dir is set.
+type Templates struct {
+	Dir string `yaml:"dir"`
+}
+
+// Context controls how session history exceeding the model context is
+// fitted into it.
+type Context struct {
+	Strategy session.Strategy `yaml:"strategy"`
+	// Budget in tokens applies to models of unknown context size; zero
+	// sends them the whole history.
+	Budget int `yaml:"budget"`
+	// SummaryModel writes the summaries, the session model by default.
+	SummaryModel string `yaml:"summaryModel"`
+}
+
+type Timeouts struct {
+	Request    time.Duration `yaml:"request"`
+	MaxRequest time.Duration `yaml:"maxRequest"`
+	// Shutdown bounds the wait for in-flight requests on SIGINT or SIGTERM.
+	Shutdown time.Duration `yaml:"shutdown"`
+}
+
+// Load reads the file on top of base. Settings missing in the file keep
+// their values from base; an empty path reads no file. Ollama and OpenAI
+// backends without URLs default to DefaultTargetURL.
+func Load(path string, base Config) (Config, error) {
+	cfg := base.clone()
+	if path != "" {
+		err := decodeFile(path, &cfg)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+
+	if len(cfg.Backend.URLs) == 0 && cfg.Backend.Kind != backend.KindFake {
+		cfg.Backend.URLs = []string{DefaultTargetURL}
+	}
+	return cfg, nil
+}
+
+func decodeFile(path string, cfg *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	defer f.Close()
+
+	// JSON is valid YAML, so both are decoded the same way and report
+	// errors with line numbers.
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	err = dec.Decode(cfg)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return nil
+}
+
+// clone copies the maps and pointers, so that decoding a file into the
+// copy doesn't change c.
+func (c Config) clone() Config {
+	c.Backend.URLs = slices.Clone(c.Backend.URLs)
+	c.Backend.Models = slices.Clone(c.Backend.Models)
+	c.Models.Allowed = slices.Clone(c.Models.Allowed)
+	c.Models.Aliases = maps.Clone(c.Models.Aliases)
+	c.Models.Rules = slices.Clone(c.Models.Rules)
+	c.Models.Vision = slices.Clone(c.Models.Vision)
+	c.Models.ContextSizes = maps.Clone(c.Models.ContextSizes)
+	if c.APIKeys != nil {
+		keys := auth.Config{Clients: slices.Clone(c.APIKeys.Clients)}
+		c.APIKeys = &keys
+	}
+	return c
+}
+
+// Validate reports every invalid setting prefixed with its path in the file.
+func (c Config) Validate() error {
+	var problems []error
+	add := func(path, format string, args ...any) {
+		problems = append(problems, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...)))
+	}
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		add("port", "invalid port %q", c.Port)
+	}
+
+	switch c.Backend.Kind {
+	case backend.KindOllama, backend.KindOpenAI:
+		if len(c.Backend.URLs) == 0 {
+			add("backend.urls", "at least one URL is required for the %s backend", c.Backend.Kind)
+		}
+	case backend.KindFake:
+	default:
+		add("backend.kind", "unknown kind %q, expected %s, %s or %s", c.Backend.Kind, backend.KindOllama, backend.KindOpenAI, backend.KindFake)
+	}
+	for i, rawURL := range c.Backend.URLs {
+		u, err := url.Parse(rawURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add(fmt.Sprintf("backend.urls[%d]", i), "invalid URL %q", rawURL)
+		}
+	}
+	if c.Backend.HealthCheckInterval <= 0 {
+		add("backend.healthCheckInterval", "must be positive")
+	}
+	if c.Backend.ContextLength < 0 {
+		add("backend.contextLength", "must not be negative")
+	}
+
+	router, err := models.NewRouter(c.Models)
+	if err != nil {
+		add("models", "%v", err)
+	} else if c.Context.SummaryModel != "" {
+		if _, err := router.Resolve(c.Context.SummaryModel, "", nil); err != nil {
+			add("context.summaryModel", "%v", err)
+		}
+	}
+
+	if c.Limits.Queue.MaxWaiting < 0 {
+		add("limits.queue.maxWaiting", "must not be negative")
+	}
+	if c.Limits.Queue.Timeout < 0 {
+		add("limits.queue.timeout", "must not be negative")
+	}
+	if c.Limits.StructuredRetries < 0 {
+		add("limits.structuredRetries", "must not be negative")
+	}
+	if c.Limits.ToolIterations < 1 {
+		add("limits.toolIterations", "must be at least 1")
+	}
+	if c.Limits.Images.MaxBytes < 0 {
+		add("limits.images.maxBytes", "must not be negative")
+	}
+	if c.Limits.Images.MaxDimension < 0 {
+		add("limits.images.maxDimension", "must not be negative")
+	}
+
+	if c.APIKeys != nil {
+		if _, err := auth.NewManager(*c.APIKeys); err != nil {
+			add("apiKeys", "%v", err)
+		}
+	}
+
+	if c.Timeouts.Request < 0 {
+		add("timeouts.request", "must not be negative")
+	}
+	if c.Timeouts.MaxRequest < 0 {
+		add("timeouts.maxRequest", "must not be negative")
+	}
+	if c.Timeouts.Request > 0 && c.Timeouts.MaxRequest > 0 && c.Timeouts.Request > c.Timeouts.MaxRequest {
+		add("timeouts.request", "%v exceeds timeouts.maxRequest %v", c.Timeouts.Request, c.Timeouts.MaxRequest)
+	}
+	if c.Timeouts.Shutdown <= 0 {
+		add("timeouts.shutdown", "must be positive")
+	}
+
+	if !slices.Contains(session.Strategies, c.Context.Strategy) {
+		add("context.strategy", "unknown strategy %q, expected %s, %s or %s", c.Context.Strategy, session.TruncateOldest, session.TruncateMiddle, session.Summarize)
+	}
+	if c.Context.Budget < 0 {
+		add("context.budget", "must not be negative")
+	}
+
+	return errors.Join(problems...)
+}
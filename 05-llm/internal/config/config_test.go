@@ -0,0 +1,340 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/auth"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/session"
+)
+
+func getenv(vars map[string]string) func(string) string {
+	return func(name string) string { return vars[name] }
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func validConfig(t *testing.T) Config {
+	t.Helper()
+
+	base, err := FromEnv(getenv(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load("", base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestFromEnv(t *testing.T) {
+	cfg, err := FromEnv(getenv(map[string]string{
+		"PORT":                "9090",
+		"LLM_BACKEND":         "openai",
+		"TARGET_SERVER":       "http://a:1, http://b:2",
+		"QUEUE_MAX_IN_FLIGHT": "8",
+		"QUEUE_TIMEOUT":       "5s",
+		"LLM_MODEL":           "qwen",
+		"VISION_MODELS":       "llava",
+		"TEMPLATES_DIR":       "/templates",
+		"SESSION_SUMMARIZE":   "true",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Port != "9090" || cfg.Backend.Kind != "openai" || !slices.Equal(cfg.Backend.URLs, []string{"http://a:1", "http://b:2"}) {
+		t.Errorf("unexpected backend %+v on port %s", cfg.Backend, cfg.Port)
+	}
+	if cfg.Limits.Queue != (Queue{MaxInFlight: 8, MaxWaiting: 64, Timeout: 5 * time.Second}) {
+		t.Errorf("unexpected queue limits %+v", cfg.Limits.Queue)
+	}
+	if cfg.Models.Default != "qwen" || !slices.Equal(cfg.Models.Vision, []string{"llava"}) {
+		t.Errorf("unexpected models %+v", cfg.Models)
+	}
+	if cfg.Context != (Context{Strategy: session.Summarize, Budget: DefaultContextBudget}) {
+		t.Errorf("unexpected context %+v", cfg.Context)
+	}
+	if cfg.Templates.Dir != "/templates" || cfg.Timeouts != (Timeouts{Request: 2 * time.Minute, MaxRequest: 10 * time.Minute, Shutdown: 30 * time.Second}) {
+		t.Errorf("unexpected templates %+v or timeouts %+v", cfg.Templates, cfg.Timeouts)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("config from the environment is invalid: %v", err)
+	}
+}
+
+func TestFromEnvInvalid(t *testing.T) {
+	_, err := FromEnv(getenv(map[string]string{
+		"QUEUE_MAX_WAITING": "many",
+		"REQUEST_TIMEOUT":   "10",
+		"MODELS_CONFIG":     "/does/not/exist.json",
+	}))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, name := range []string{"QUEUE_MAX_WAITING", "REQUEST_TIMEOUT", "MODELS_CONFIG"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("error %q does not name %s", err, name)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	base, err := FromEnv(getenv(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base.APIKeys = &auth.Config{Clients: []auth.ClientConfig{{Name: "base", KeyHash: auth.HashKey("base")}}}
+
+	tests := []struct {
+		name        string
+		file        string
+		content     string
+		expectedErr string
+		check       func(t *testing.T, cfg Config)
+	}{
+		{
+			name: "YAML",
+			file: "config.yaml",
+			content: `
+backend:
+  urls: [http://gpu:11434]
+models:
+  allowed: [llama3.2, llava]
+  aliases: {fast: llama3.2}
+limits:
+  queue:
+    maxInFlight: 2
+apiKeys:
+  clients:
+    - name: team-a
+      keyHash: ` + auth.HashKey("a") + `
+timeouts:
+  request: 30s
+`,
+			check: func(t *testing.T, cfg Config) {
+				if !slices.Equal(cfg.Backend.URLs, []string{"http://gpu:11434"}) || cfg.Backend.Kind != "ollama" {
+					t.Errorf("unexpected backend %+v", cfg.Backend)
+				}
+				if cfg.Models.Default != DefaultModel || cfg.Models.Aliases["fast"] != "llama3.2" {
+					t.Errorf("unexpected models %+v", cfg.Models)
+				}
+				if cfg.Limits.Queue.MaxInFlight != 2 || cfg.Limits.Queue.MaxWaiting != 64 {
+					t.Errorf("unexpected queue limits %+v", cfg.Limits.Queue)
+				}
+				if len(cfg.APIKeys.Clients) != 1 || cfg.APIKeys.Clients[0].Name != "team-a" {
+					t.Errorf("unexpected clients %+v", cfg.APIKeys.Clients)
+				}
+				if cfg.Timeouts.Request != 30*time.Second || cfg.Timeouts.MaxRequest != 10*time.Minute {
+					t.Errorf("unexpected timeouts %+v", cfg.Timeouts)
+				}
+			},
+		},
+		{
+			name:    "JSON",
+			file:    "config.json",
+			content: `{"port": "9000", "limits": {"toolIterations": 3, "images": {"maxDimension": 1024}}}`,
+			check: func(t *testing.T, cfg Config) {
+				if cfg.Port != "9000" || cfg.Limits.ToolIterations != 3 || cfg.Limits.Images.MaxDimension != 1024 {
+					t.Errorf("unexpected config %+v", cfg)
+				}
+				if !slices.Equal(cfg.Backend.URLs, []string{DefaultTargetURL}) {
+					t.Errorf("unexpected backend URLs %v", cfg.Backend.URLs)
+				}
+			},
+		},
+		{
+			name:    "Empty file",
+			file:    "config.yaml",
+			content: "",
+			check: func(t *testing.T, cfg Config) {
+				if cfg.Port != base.Port || cfg.APIKeys.Clients[0].Name != "base" {
+					t.Errorf("unexpected config %+v", cfg)
+				}
+			},
+		},
+		{
+			name:    "Fake backend",
+			file:    "config.yaml",
+			content: "backend:\n  kind: fake\n",
+			check: func(t *testing.T, cfg Config) {
+				if len(cfg.Backend.URLs) != 0 {
+					t.Errorf("unexpected backend URLs %v", cfg.Backend.URLs)
+				}
+			},
+		},
+		{
+			name:        "Unknown field",
+			file:        "config.yaml",
+			content:     "backend:\n  kind: ollama\n  url: http://gpu:11434\n",
+			expectedErr: "line 3: field url not found",
+		},
+		{
+			name:        "Duration without unit",
+			file:        "config.yaml",
+			content:     "timeouts:\n  request: 30\n",
+			expectedErr: "line 2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load(writeFile(t, tt.file, tt.content), base)
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, cfg)
+			if base.APIKeys.Clients[0].Name != "base" || base.Models.Aliases != nil {
+				t.Error("loading changed the base config")
+			}
+		})
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	_, err := Load(filepath.Join(t.TempDir(), "missing.yaml"), Config{})
+	if err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name          string
+		modify        func(cfg *Config)
+		expectedPaths []string
+	}{
+		{
+			name:   "Valid",
+			modify: func(cfg *Config) {},
+		},
+		{
+			name:   "Fake backend without URLs",
+			modify: func(cfg *Config) { cfg.Backend = Backend{Kind: "fake", HealthCheckInterval: time.Second} },
+		},
+		{
+			name: "Invalid backend",
+			modify: func(cfg *Config) {
+				cfg.Backend.Kind = "gemini"
+				cfg.Backend.URLs = []string{"http://ok:1", "gpu:11434"}
+				cfg.Backend.HealthCheckInterval = 0
+				cfg.Backend.ContextLength = -1
+			},
+			expectedPaths: []string{"backend.kind", "backend.urls[1]", "backend.healthCheckInterval", "backend.contextLength"},
+		},
+		{
+			name:          "Invalid port",
+			modify:        func(cfg *Config) { cfg.Port = "http" },
+			expectedPaths: []string{"port"},
+		},
+		{
+			name:          "Default model not allowed",
+			modify:        func(cfg *Config) { cfg.Models.Allowed = []string{"qwen"} },
+			expectedPaths: []string{"models"},
+		},
+		{
+			name: "Negative limits",
+			modify: func(cfg *Config) {
+				cfg.Limits.Queue.MaxWaiting = -1
+				cfg.Limits.ToolIterations = 0
+				cfg.Limits.Images.MaxBytes = -1
+			},
+			expectedPaths: []string{"limits.queue.maxWaiting", "limits.toolIterations", "limits.images.maxBytes"},
+		},
+		{
+			name: "Invalid API key",
+			modify: func(cfg *Config) {
+				cfg.APIKeys = &auth.Config{Clients: []auth.ClientConfig{{Name: "team-a", KeyHash: "secret"}}}
+			},
+			expectedPaths: []string{"apiKeys"},
+		},
+		{
+			name: "Invalid context",
+			modify: func(cfg *Config) {
+				cfg.Context = Context{Strategy: "drop_all", Budget: -1, SummaryModel: "qwen"}
+				cfg.Models.Allowed = []string{DefaultModel}
+			},
+			expectedPaths: []string{"context.summaryModel", "context.strategy", "context.budget"},
+		},
+		{
+			name:          "Default timeout above maximum",
+			modify:        func(cfg *Config) { cfg.Timeouts.Request = time.Hour },
+			expectedPaths: []string{"timeouts.request"},
+		},
+		{
+			name:          "No shutdown timeout",
+			modify:        func(cfg *Config) { cfg.Timeouts.Shutdown = 0 },
+			expectedPaths: []string{"timeouts.shutdown"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig(t)
+			tt.modify(&cfg)
+
+			err := cfg.Validate()
+			if len(tt.expectedPaths) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			lines := strings.Split(err.Error(), "\n")
+			if len(lines) != len(tt.expectedPaths) {
+				t.Fatalf("expected %d problems, got %q", len(tt.expectedPaths), lines)
+			}
+			for i, path := range tt.expectedPaths {
+				if !strings.HasPrefix(lines[i], path+": ") {
+					t.Errorf("problem %q is not about %s", lines[i], path)
+				}
+			}
+		})
+	}
+}
+
+func TestWatch(t *testing.T) {
+	path := writeFile(t, "config.yaml", "port: \"8080\"\n")
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := Watch(ctx, path, 10*time.Millisecond)
+
+	select {
+	case <-changes:
+		t.Fatal("unexpected change before the file was written")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := os.WriteFile(path, []byte("port: \"9090\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Fatal("change was not noticed")
+	}
+
+	cancel()
+	for range changes {
+	}
+}
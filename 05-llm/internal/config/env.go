@@ -0,0 +1,140 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/auth"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/models"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/session"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/vision"
+)
+
+const (
+	DefaultModel         = "llama3.2"
+	DefaultContextBudget = 4096
+)
+
+// FromEnv returns the configuration set by the environment variables, with
+// defaults for the unset ones.
+func FromEnv(getenv func(string) string) (Config, error) {
+	env := envReader{getenv: getenv}
+
+	cfg := Config{
+		Port: env.string("PORT", "8080"),
+		Backend: Backend{
+			Kind:                env.string("LLM_BACKEND", backend.KindOllama),
+			URLs:                SplitList(getenv("TARGET_SERVER")),
+			APIKey:              getenv("LLM_API_KEY"),
+			Models:              SplitList(getenv("LLM_MODELS")),
+			HealthCheckInterval: env.duration("HEALTH_CHECK_INTERVAL", 10*time.Second),
+			ContextLength:       env.int("OLLAMA_NUM_CTX", 4096),
+		},
+		Limits: Limits{
+			Queue: Queue{
+				MaxInFlight: env.int("QUEUE_MAX_IN_FLIGHT", 4),
+				MaxWaiting:  env.int("QUEUE_MAX_WAITING", 64),
+				Timeout:     env.duration("QUEUE_TIMEOUT", 30*time.Second),
+			},
+			StructuredRetries: env.int("STRUCTURED_MAX_RETRIES", 2),
+			ToolIterations:    env.int("TOOLS_MAX_ITERATIONS", 5),
+			Images: Image{
+				MaxBytes:     int64(env.int("IMAGE_MAX_BYTES", vision.DefaultMaxBytes)),
+				MaxDimension: env.int("IMAGE_MAX_DIMENSION", 0),
+			},
+		},
+		Templates: Templates{Dir: getenv("TEMPLATES_DIR")},
+		Timeouts: Timeouts{
+			Request:    env.duration("REQUEST_TIMEOUT", 2*time.Minute),
+			MaxRequest: env.duration("REQUEST_MAX_TIMEOUT", 10*time.Minute),
+			Shutdown:   env.duration("SHUTDOWN_TIMEOUT", 30*time.Second),
+		},
+		Context: Context{
+			Strategy:     session.Strategy(env.string("CONTEXT_STRATEGY", string(session.TruncateOldest))),
+			Budget:       env.int("SESSION_CONTEXT_BUDGET", DefaultContextBudget),
+			SummaryModel: getenv("SUMMARY_MODEL"),
+		},
+	}
+	// SESSION_SUMMARIZE predates the strategies.
+	if summarize, _ := strconv.ParseBool(getenv("SESSION_SUMMARIZE")); summarize && getenv("CONTEXT_STRATEGY") == "" {
+		cfg.Context.Strategy = session.Summarize
+	}
+
+	if path := getenv("MODELS_CONFIG"); path != "" {
+		var err error
+		cfg.Models, err = models.LoadConfig(path)
+		if err != nil {
+			env.errs = append(env.errs, fmt.Errorf("MODELS_CONFIG: %w", err))
+		}
+	}
+	cfg.Models.Default = env.string("LLM_MODEL", cfg.Models.Default)
+	if cfg.Models.Default == "" {
+		cfg.Models.Default = DefaultModel
+	}
+	cfg.Models.Vision = append(cfg.Models.Vision, SplitList(getenv("VISION_MODELS"))...)
+
+	if path := getenv("API_KEYS_CONFIG"); path != "" {
+		keys, err := auth.LoadConfig(path)
+		if err != nil {
+			env.errs = append(env.errs, fmt.Errorf("API_KEYS_CONFIG: %w", err))
+		}
+		cfg.APIKeys = &keys
+	}
+
+	return cfg, errors.Join(env.errs...)
+}
+
+// SplitList splits a comma-separated list ignoring empty items.
+func SplitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// envReader collects the errors of all invalid variables instead of
+// stopping at the first one.
+type envReader struct {
+	getenv func(string) string
+	errs   []error
+}
+
+func (e *envReader) string(name, def string) string {
+	if v := e.getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
+func (e *envReader) int(name string, def int) int {
+	v := e.getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s: invalid integer %q", name, v))
+		return def
+	}
+	return n
+}
+
+func (e *envReader) duration(name string, def time.Duration) time.Duration {
+	v := e.getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s: invalid duration %q", name, v))
+		return def
+	}
+	return d
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// Watch checks the file every interval and sends to the returned channel
+// when its modification time or size changes. A missing file is not a
+// change, so the one being replaced by an editor is seen once it is written.
+// The channel is closed when ctx is done.
+func Watch(ctx context.Context, path string, interval time.Duration) <-chan struct{} {
+	changes := make(chan struct{}, 1)
+	last, _ := os.Stat(path)
+
+	go func() {
+		defer close(changes)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			if last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+				continue
+			}
+			last = info
+
+			// A pending notification already covers this change.
+			select {
+			case changes <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	return changes
+}
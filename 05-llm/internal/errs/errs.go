@@ -0,0 +1,155 @@
+package errs
+
+import "time"
+
+type ErrBadRequest struct {
+	msg string
+}
+
+func (e ErrBadRequest) Error() string {
+	return e.msg
+}
+
+func NewErrBadRequest(msg string) ErrBadRequest {
+	return ErrBadRequest{msg: msg}
+}
+
+type ErrNotFound struct {
+	msg string
+}
+
+func (e ErrNotFound) Error() string {
+	return e.msg
+}
+
+func NewErrNotFound(msg string) ErrNotFound {
+	return ErrNotFound{msg: msg}
+}
+
+type ErrBadGateway struct {
+	msg string
+}
+
+func (e ErrBadGateway) Error() string {
+	return e.msg
+}
+
+func NewErrBadGateway(msg string) ErrBadGateway {
+	return ErrBadGateway{msg: msg}
+}
+
+type ErrUnavailable struct {
+	msg        string
+	retryAfter time.Duration
+}
+
+func (e ErrUnavailable) Error() string {
+	return e.msg
+}
+
+// RetryAfter is the suggested delay before the client retries the request.
+func (e ErrUnavailable) RetryAfter() time.Duration {
+	return e.retryAfter
+}
+
+func NewErrUnavailable(msg string, retryAfter time.Duration) ErrUnavailable {
+	return ErrUnavailable{msg: msg, retryAfter: retryAfter}
+}
+
+type ErrUnauthorized struct {
+	msg string
+}
+
+func (e ErrUnauthorized) Error() string {
+	return e.msg
+}
+
+func NewErrUnauthorized(msg string) ErrUnauthorized {
+	return ErrUnauthorized{msg: msg}
+}
+
+type ErrTooManyRequests struct {
+	msg        string
+	retryAfter time.Duration
+}
+
+func (e ErrTooManyRequests) Error() string {
+	return e.msg
+}
+
+// RetryAfter is the suggested delay before the client retries the request.
+func (e ErrTooManyRequests) RetryAfter() time.Duration {
+	return e.retryAfter
+}
+
+func NewErrTooManyRequests(msg string, retryAfter time.Duration) ErrTooManyRequests {
+	return ErrTooManyRequests{msg: msg, retryAfter: retryAfter}
+}
+
+type ErrUnprocessable struct {
+	msg     string
+	details any
+}
+
+func (e ErrUnprocessable) Error() string {
+	return e.msg
+}
+
+// Details is additional information about the failure returned to the client.
+func (e ErrUnprocessable) Details() any {
+	return e.details
+}
+
+func NewErrUnprocessable(msg string, details any) ErrUnprocessable {
+	return ErrUnprocessable{msg: msg, details: details}
+}
+
+type ErrGatewayTimeout struct {
+	msg string
+}
+
+func (e ErrGatewayTimeout) Error() string {
+	return e.msg
+}
+
+func NewErrGatewayTimeout(msg string) ErrGatewayTimeout {
+	return ErrGatewayTimeout{msg: msg}
+}
+
+// ErrClientClosed is returned when the client gave up on the request
+// before it was answered.
+type ErrClientClosed struct {
+	msg string
+}
+
+func (e ErrClientClosed) Error() string {
+	return e.msg
+}
+
+func NewErrClientClosed(msg string) ErrClientClosed {
+	return ErrClientClosed{msg: msg}
+}
+
+type ErrTooLarge struct {
+	msg string
+}
+
+func (e ErrTooLarge) Error() string {
+	return e.msg
+}
+
+func NewErrTooLarge(msg string) ErrTooLarge {
+	return ErrTooLarge{msg: msg}
+}
+
+type ErrUnsupportedMediaType struct {
+	msg string
+}
+
+func (e ErrUnsupportedMediaType) Error() string {
+	return e.msg
+}
+
+func NewErrUnsupportedMediaType(msg string) ErrUnsupportedMediaType {
+	return ErrUnsupportedMediaType{msg: msg}
+}
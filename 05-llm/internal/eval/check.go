@@ -0,0 +1,224 @@
+package eval
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/structured"
+)
+
+// Checker scores a response. Check returns an error describing why the
+// response failed the check.
+type Checker interface {
+	Check(output string) error
+}
+
+// CheckerFunc adapts a function to the Checker interface.
+type CheckerFunc func(output string) error
+
+func (f CheckerFunc) Check(output string) error {
+	return f(output)
+}
+
+// Factory creates a checker; decode decodes the check's parameters into a struct.
+type Factory func(decode func(params any) error) (Checker, error)
+
+var (
+	mu        sync.RWMutex
+	factories = map[string]Factory{
+		"exact":       newExact,
+		"regex":       newRegex,
+		"contains":    newContains,
+		"json_schema": newJSONSchema,
+		"number":      newNumber,
+	}
+)
+
+// Register makes a checker type available to suites. It replaces a
+// previously registered checker of the same type.
+func Register(typ string, f Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	factories[typ] = f
+}
+
+func newChecker(spec CheckSpec) (Checker, error) {
+	mu.RLock()
+	f, ok := factories[spec.Type]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown check type %q", spec.Type)
+	}
+
+	checker, err := f(spec.params.Decode)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s check: %w", spec.Type, err)
+	}
+	return checker, nil
+}
+
+// newExact matches the whole response, ignoring surrounding whitespace.
+func newExact(decode func(any) error) (Checker, error) {
+	var params struct {
+		Value      string `yaml:"value"`
+		IgnoreCase bool   `yaml:"ignore_case"`
+	}
+	err := decode(&params)
+	if err != nil {
+		return nil, err
+	}
+
+	return CheckerFunc(func(output string) error {
+		output = strings.TrimSpace(output)
+		if output == params.Value || (params.IgnoreCase && strings.EqualFold(output, params.Value)) {
+			return nil
+		}
+		return fmt.Errorf("expected %q, got %q", params.Value, truncate(output))
+	}), nil
+}
+
+// newRegex matches the response against a regular expression.
+func newRegex(decode func(any) error) (Checker, error) {
+	var params struct {
+		Pattern string `yaml:"pattern"`
+	}
+	err := decode(&params)
+	if err != nil {
+		return nil, err
+	}
+	if params.Pattern == "" {
+		return nil, errors.New("pattern is empty")
+	}
+	re, err := regexp.Compile(params.Pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return CheckerFunc(func(output string) error {
+		if re.MatchString(output) {
+			return nil
+		}
+		return fmt.Errorf("%q does not match %s", truncate(output), params.Pattern)
+	}), nil
+}
+
+// newContains requires all of the keywords to appear in the response.
+func newContains(decode func(any) error) (Checker, error) {
+	var params struct {
+		Keywords   []string `yaml:"keywords"`
+		IgnoreCase bool     `yaml:"ignore_case"`
+	}
+	err := decode(&params)
+	if err != nil {
+		return nil, err
+	}
+	if len(params.Keywords) == 0 {
+		return nil, errors.New("keywords are empty")
+	}
+
+	return CheckerFunc(func(output string) error {
+		if params.IgnoreCase {
+			output = strings.ToLower(output)
+		}
+		var missing []string
+		for _, kw := range params.Keywords {
+			if params.IgnoreCase {
+				kw = strings.ToLower(kw)
+			}
+			if !strings.Contains(output, kw) {
+				missing = append(missing, kw)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("missing keywords %q", missing)
+		}
+		return nil
+	}), nil
+}
+
+// newJSONSchema requires the response to be JSON valid against the schema.
+func newJSONSchema(decode func(any) error) (Checker, error) {
+	var params struct {
+		Schema map[string]any `yaml:"schema"`
+	}
+	err := decode(&params)
+	if err != nil {
+		return nil, err
+	}
+	if params.Schema == nil {
+		return nil, errors.New("schema is empty")
+	}
+	raw, err := json.Marshal(params.Schema)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := structured.Compile(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return CheckerFunc(func(output string) error {
+		_, violations := schema.Validate(output)
+		if len(violations) == 0 {
+			return nil
+		}
+		problems := make([]string, len(violations))
+		for i, v := range violations {
+			problems[i] = v.Path + ": " + v.Message
+		}
+		return errors.New(strings.Join(problems, "; "))
+	}), nil
+}
+
+var numberPattern = regexp.MustCompile(`-?\d+(?:\.\d+)?`)
+
+// newNumber compares the first number in the response to the expected value.
+func newNumber(decode func(any) error) (Checker, error) {
+	var params struct {
+		Value     *float64 `yaml:"value"`
+		Tolerance float64  `yaml:"tolerance"`
+	}
+	err := decode(&params)
+	if err != nil {
+		return nil, err
+	}
+	if params.Value == nil {
+		return nil, errors.New("value is missing")
+	}
+	if params.Tolerance < 0 {
+		return nil, errors.New("tolerance is negative")
+	}
+	expected := *params.Value
+
+	return CheckerFunc(func(output string) error {
+		match := numberPattern.FindString(strings.ReplaceAll(output, ",", ""))
+		if match == "" {
+			return fmt.Errorf("no number in %q", truncate(output))
+		}
+		n, err := strconv.ParseFloat(match, 64)
+		if err != nil {
+			return err
+		}
+		if math.Abs(n-expected) > params.Tolerance {
+			return fmt.Errorf("expected %g ± %g, got %g", expected, params.Tolerance, n)
+		}
+		return nil
+	}), nil
+}
+
+const maxQuoted = 80
+
+// truncate shortens the response quoted in failure messages.
+func truncate(s string) string {
+	r := []rune(s)
+	if len(r) <= maxQuoted {
+		return s
+	}
+	return string(r[:maxQuoted]) + "..."
+}
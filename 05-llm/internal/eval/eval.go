@@ -0,0 +1,247 @@
+package eval
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// DefaultModel labels the results of suites that do not list models.
+const DefaultModel = "default"
+
+// Target produces the response to a case. An empty model means the target's default.
+type Target interface {
+	Generate(ctx context.Context, c Case, model string) (string, error)
+}
+
+// Result is the outcome of a case for one model.
+type Result struct {
+	Model    string
+	Case     string
+	Output   string
+	Duration time.Duration
+	// Failures lists the failed checks.
+	Failures []string
+	// Err is set when the response could not be produced.
+	Err error
+}
+
+func (r Result) Passed() bool {
+	return r.Err == nil && len(r.Failures) == 0
+}
+
+// Report holds the results of a suite run in the order of models and cases.
+type Report struct {
+	Suite   string
+	Models  []string
+	Results []Result
+}
+
+// Summary counts the results of a model.
+type Summary struct {
+	Model  string
+	Total  int
+	Passed int
+	Failed int
+	Errors int
+}
+
+func (s Summary) PassRate() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	return float64(s.Passed) / float64(s.Total)
+}
+
+// Run evaluates every case of the suite with each of the models. The
+// models override the suite's own list when not empty. Timeout bounds
+// every case unless it is zero.
+func Run(ctx context.Context, suite Suite, target Target, models []string, timeout time.Duration) Report {
+	if len(models) == 0 {
+		models = suite.Models
+	}
+	labels := models
+	if len(models) == 0 {
+		models, labels = []string{""}, []string{DefaultModel}
+	}
+
+	report := Report{Suite: suite.Name, Models: labels}
+	for i, model := range models {
+		for _, c := range suite.Cases {
+			res := runCase(ctx, target, c, model, timeout)
+			res.Model = labels[i]
+			report.Results = append(report.Results, res)
+		}
+	}
+	return report
+}
+
+func runCase(ctx context.Context, target Target, c Case, model string, timeout time.Duration) Result {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	res := Result{Case: c.Name}
+	start := time.Now()
+	res.Output, res.Err = target.Generate(ctx, c, model)
+	res.Duration = time.Since(start)
+	if res.Err != nil {
+		return res
+	}
+
+	for i, checker := range c.checkers {
+		err := checker.Check(res.Output)
+		if err != nil {
+			res.Failures = append(res.Failures, fmt.Sprintf("%s: %v", c.Checks[i].Type, err))
+		}
+	}
+	return res
+}
+
+// Summaries returns the counts per model.
+func (r Report) Summaries() []Summary {
+	summaries := make([]Summary, len(r.Models))
+	index := make(map[string]int)
+	for i, model := range r.Models {
+		summaries[i].Model = model
+		index[model] = i
+	}
+	for _, res := range r.Results {
+		s := &summaries[index[res.Model]]
+		s.Total++
+		switch {
+		case res.Err != nil:
+			s.Errors++
+		case len(res.Failures) > 0:
+			s.Failed++
+		default:
+			s.Passed++
+		}
+	}
+	return summaries
+}
+
+// Passed reports whether every case passed for every model.
+func (r Report) Passed() bool {
+	for _, res := range r.Results {
+		if !res.Passed() {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteTable writes the failed cases followed by the pass rates per model.
+func (r Report) WriteTable(w io.Writer) error {
+	for _, res := range r.Results {
+		switch {
+		case res.Err != nil:
+			fmt.Fprintf(w, "ERROR %s/%s: %v\n", res.Model, res.Case, res.Err)
+		case len(res.Failures) > 0:
+			fmt.Fprintf(w, "FAIL  %s/%s: %s\n", res.Model, res.Case, strings.Join(res.Failures, "; "))
+		}
+	}
+	if !r.Passed() {
+		fmt.Fprintln(w)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MODEL\tPASSED\tFAILED\tERRORS\tPASS RATE")
+	for _, s := range r.Summaries() {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.1f%%\n", s.Model, s.Passed, s.Failed, s.Errors, s.PassRate()*100)
+	}
+	return tw.Flush()
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr,omitempty"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// WriteJUnit writes the report as JUnit XML with a test suite per model.
+func (r Report) WriteJUnit(w io.Writer) error {
+	out := junitSuites{Name: r.Suite}
+	var total time.Duration
+	for _, s := range r.Summaries() {
+		suite := junitSuite{Name: s.Model, Tests: s.Total, Failures: s.Failed, Errors: s.Errors}
+		var elapsed time.Duration
+		for _, res := range r.Results {
+			if res.Model != s.Model {
+				continue
+			}
+			elapsed += res.Duration
+
+			tc := junitCase{Name: res.Case, Classname: s.Model, Time: seconds(res.Duration)}
+			switch {
+			case res.Err != nil:
+				tc.Error = &junitProblem{Message: res.Err.Error()}
+			case len(res.Failures) > 0:
+				tc.Failure = &junitProblem{
+					Message: fmt.Sprintf("%d of the checks failed", len(res.Failures)),
+					Text:    strings.Join(res.Failures, "\n"),
+				}
+				tc.SystemOut = res.Output
+			}
+			suite.Cases = append(suite.Cases, tc)
+		}
+		suite.Time = seconds(elapsed)
+		total += elapsed
+
+		out.Tests += suite.Tests
+		out.Failures += suite.Failures
+		out.Errors += suite.Errors
+		out.Suites = append(out.Suites, suite)
+	}
+	out.Time = seconds(total)
+
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	err = enc.Encode(out)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
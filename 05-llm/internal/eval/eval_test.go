@@ -0,0 +1,174 @@
+package eval
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// fakeTarget replies with the canned responses of the model by case name.
+type fakeTarget map[string]map[string]string
+
+func (f fakeTarget) Generate(ctx context.Context, c Case, model string) (string, error) {
+	reply, ok := f[model][c.Name]
+	if !ok {
+		return "", errors.New("proxy responded with 502 Bad Gateway")
+	}
+	return reply, nil
+}
+
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, expected) {
+		t.Errorf("%s differs from the output:\n%s", path, got)
+	}
+}
+
+func TestRunGolden(t *testing.T) {
+	suite, err := Load("testdata/suite.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target := fakeTarget{
+		"good": {
+			"greeting":   "hello, world!",
+			"arithmetic": "The answer is 42.",
+			"person":     "```json\n{\"name\": \"Ada Lovelace\", \"born\": 1815}\n```",
+			"summary":    "A fox jumps over a dog.",
+		},
+		"bad": {
+			"greeting":   "Hi there",
+			"arithmetic": "It is 48.",
+			"person":     `{"name": "Ada Lovelace", "born": "1815"}`,
+		},
+	}
+	report := Run(context.Background(), suite, target, nil, 0)
+	for i := range report.Results {
+		report.Results[i].Duration = 0
+	}
+
+	if report.Passed() {
+		t.Error("expected the report to fail")
+	}
+	summaries := report.Summaries()
+	if len(summaries) != 2 || summaries[0].PassRate() != 1 || summaries[1].Errors != 1 || summaries[1].Failed != 3 {
+		t.Errorf("unexpected summaries %+v", summaries)
+	}
+
+	var table, junit bytes.Buffer
+	if err := report.WriteTable(&table); err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "report.golden", table.Bytes())
+	if err := report.WriteJUnit(&junit); err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "junit.golden", junit.Bytes())
+}
+
+func TestRunModels(t *testing.T) {
+	suite, err := Parse(strings.NewReader(`
+cases:
+  - prompt: hi
+    checks:
+      - type: exact
+        value: hello
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		models         []string
+		expectedModels []string
+	}{
+		{name: "Proxy default", expectedModels: []string{DefaultModel}},
+		{name: "Override", models: []string{"a", "b"}, expectedModels: []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := fakeTarget{"": {"case-1": "hello"}, "a": {"case-1": "hello"}, "b": {"case-1": "bye"}}
+			report := Run(context.Background(), suite, target, tt.models, 0)
+
+			var got []string
+			for _, res := range report.Results {
+				got = append(got, res.Model)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.expectedModels, ",") {
+				t.Errorf("results for %v, want %v", got, tt.expectedModels)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		suite         string
+		expectedError string
+	}{
+		{name: "No cases", suite: "name: empty", expectedError: "suite has no cases"},
+		{name: "Unknown field", suite: "cases: []\nmodel: x", expectedError: "field model not found"},
+		{name: "No prompt", suite: "cases: [{checks: [{type: exact}]}]", expectedError: "neither a prompt nor a template"},
+		{name: "No checks", suite: "cases: [{prompt: hi}]", expectedError: "has no checks"},
+		{name: "Duplicate", suite: "cases: [{name: a, prompt: hi, checks: [{type: exact}]}, {name: a, prompt: hi, checks: [{type: exact}]}]", expectedError: `duplicate case "a"`},
+		{name: "Unknown check", suite: "cases: [{prompt: hi, checks: [{type: bleu}]}]", expectedError: `unknown check type "bleu"`},
+		{name: "Invalid regex", suite: "cases: [{prompt: hi, checks: [{type: regex, pattern: '('}]}]", expectedError: "invalid regex check"},
+		{name: "Invalid schema", suite: "cases: [{prompt: hi, checks: [{type: json_schema, schema: {type: 5}}]}]", expectedError: "invalid json_schema check"},
+		{name: "Number without value", suite: "cases: [{prompt: hi, checks: [{type: number}]}]", expectedError: "value is missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.suite))
+			if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+				t.Errorf("expected error containing %q, got %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("length", func(decode func(any) error) (Checker, error) {
+		var params struct {
+			Max int `yaml:"max"`
+		}
+		if err := decode(&params); err != nil {
+			return nil, err
+		}
+		return CheckerFunc(func(output string) error {
+			if len(output) > params.Max {
+				return errors.New("too long")
+			}
+			return nil
+		}), nil
+	})
+
+	suite, err := Parse(strings.NewReader("cases: [{prompt: hi, checks: [{type: length, max: 5}]}]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := Run(context.Background(), suite, fakeTarget{"": {"case-1": "far too long"}}, nil, 0)
+	if report.Passed() || report.Results[0].Failures[0] != "length: too long" {
+		t.Errorf("unexpected results %+v", report.Results)
+	}
+}
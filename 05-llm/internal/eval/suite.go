@@ -0,0 +1,122 @@
+// Package eval runs suites of prompts against a model and scores the
+// responses with checkers, so that changes of models and templates can be
+// compared by their pass rates.
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Suite is a set of cases run against each of the models.
+//
+//	name: smoke
+//	models: [llama3.2, fast]
+//	cases:
+//	  - name: capital
+//	    prompt: What is the capital of France?
+//	    checks:
+//	      - type: contains
+//	        keywords: [Paris]
+type Suite struct {
+	Name string `yaml:"name"`
+	// Models lists the models to evaluate; empty means the proxy's default.
+	Models []string `yaml:"models"`
+	Cases  []Case   `yaml:"cases"`
+}
+
+// Case is a single prompt and the checks its response must pass. A case
+// either sends the prompt, optionally with a system prompt, or runs a
+// prompt template with the variables.
+type Case struct {
+	Name      string         `yaml:"name"`
+	Prompt    string         `yaml:"prompt"`
+	System    string         `yaml:"system"`
+	Template  string         `yaml:"template"`
+	Version   int            `yaml:"version"`
+	Variables map[string]any `yaml:"variables"`
+	Checks    []CheckSpec    `yaml:"checks"`
+
+	checkers []Checker
+}
+
+// CheckSpec selects a checker by type; the other fields of the YAML mapping
+// are the checker's parameters.
+type CheckSpec struct {
+	Type   string
+	params yaml.Node
+}
+
+func (s *CheckSpec) UnmarshalYAML(node *yaml.Node) error {
+	var head struct {
+		Type string `yaml:"type"`
+	}
+	err := node.Decode(&head)
+	if err != nil {
+		return err
+	}
+	s.Type = head.Type
+	s.params = *node
+	return nil
+}
+
+// Load reads the suite from the YAML file.
+func Load(path string) (Suite, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Suite{}, fmt.Errorf("failed to read suite: %w", err)
+	}
+	defer f.Close()
+
+	suite, err := Parse(f)
+	if err != nil {
+		return Suite{}, fmt.Errorf("invalid suite %s: %w", path, err)
+	}
+	return suite, nil
+}
+
+// Parse decodes the suite and creates the checkers of its cases.
+func Parse(r io.Reader) (Suite, error) {
+	var suite Suite
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	err := dec.Decode(&suite)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return Suite{}, err
+	}
+	if len(suite.Cases) == 0 {
+		return Suite{}, errors.New("suite has no cases")
+	}
+
+	names := make(map[string]bool)
+	for i := range suite.Cases {
+		c := &suite.Cases[i]
+		if c.Name == "" {
+			c.Name = fmt.Sprintf("case-%d", i+1)
+		}
+		if names[c.Name] {
+			return Suite{}, fmt.Errorf("duplicate case %q", c.Name)
+		}
+		names[c.Name] = true
+
+		if c.Prompt == "" && c.Template == "" {
+			return Suite{}, fmt.Errorf("case %q has neither a prompt nor a template", c.Name)
+		}
+		if len(c.Checks) == 0 {
+			return Suite{}, fmt.Errorf("case %q has no checks", c.Name)
+		}
+		for _, spec := range c.Checks {
+			checker, err := newChecker(spec)
+			if err != nil {
+				return Suite{}, fmt.Errorf("case %q: %w", c.Name, err)
+			}
+			c.checkers = append(c.checkers, checker)
+		}
+	}
+
+	return suite, nil
+}
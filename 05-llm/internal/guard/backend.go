@@ -0,0 +1,121 @@
+package guard
+
+import (
+	"context"
+	"strings"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+)
+
+// Backend applies the guard to prompts sent to the wrapped backend and to
+// the responses it generates. Findings are added to the report stored in
+// the request context.
+type Backend struct {
+	backend.Backend
+	guard *Guard
+}
+
+func NewBackend(b backend.Backend, g *Guard) *Backend {
+	return &Backend{Backend: b, guard: g}
+}
+
+func (b *Backend) Generate(ctx context.Context, req backend.GenerateRequest) (backend.Response, error) {
+	var err error
+	req.Prompt, err = b.apply(ctx, b.guard.Input, StageInput, req.Prompt)
+	if err != nil {
+		return backend.Response{}, err
+	}
+
+	resp, err := b.Backend.Generate(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	resp.Content, err = b.apply(ctx, b.guard.Output, StageOutput, resp.Content)
+	return resp, err
+}
+
+func (b *Backend) Chat(ctx context.Context, req backend.ChatRequest) (backend.Response, error) {
+	req, err := b.input(ctx, req)
+	if err != nil {
+		return backend.Response{}, err
+	}
+
+	resp, err := b.Backend.Chat(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	resp.Content, err = b.apply(ctx, b.guard.Output, StageOutput, resp.Content)
+	return resp, err
+}
+
+// Stream filters the output line by line, so that the filters see whole
+// emails, phone numbers and keywords rather than arbitrary token fragments.
+func (b *Backend) Stream(ctx context.Context, req backend.ChatRequest, fn func(backend.Chunk) error) (backend.Response, error) {
+	req, err := b.input(ctx, req)
+	if err != nil {
+		return backend.Response{}, err
+	}
+	if len(b.guard.Output) == 0 {
+		return b.Backend.Stream(ctx, req, fn)
+	}
+
+	var pending, content strings.Builder
+	emit := func(text string) error {
+		out, err := b.apply(ctx, b.guard.Output, StageOutput, text)
+		if err != nil {
+			return err
+		}
+		content.WriteString(out)
+		if out == "" {
+			return nil
+		}
+		return fn(backend.Chunk{Content: out})
+	}
+
+	resp, err := b.Backend.Stream(ctx, req, func(chunk backend.Chunk) error {
+		pending.WriteString(chunk.Content)
+		s := pending.String()
+		i := strings.LastIndexByte(s, '\n')
+		if i < 0 {
+			return nil
+		}
+		pending.Reset()
+		pending.WriteString(s[i+1:])
+		return emit(s[:i+1])
+	})
+	if err == nil && pending.Len() > 0 {
+		err = emit(pending.String())
+	}
+	resp.Content = content.String()
+	return resp, err
+}
+
+// input filters the user messages of the request. Internal messages are built
+// by the proxy, and what they quote of the client was filtered when it was sent.
+func (b *Backend) input(ctx context.Context, req backend.ChatRequest) (backend.ChatRequest, error) {
+	if len(b.guard.Input) == 0 {
+		return req, nil
+	}
+
+	messages := make([]backend.Message, len(req.Messages))
+	copy(messages, req.Messages)
+	for i, m := range messages {
+		if m.Role != "user" || m.Internal {
+			continue
+		}
+		var err error
+		messages[i].Content, err = b.apply(ctx, b.guard.Input, StageInput, m.Content)
+		if err != nil {
+			return req, err
+		}
+	}
+
+	req.Messages = messages
+	return req, nil
+}
+
+func (b *Backend) apply(ctx context.Context, chain Chain, stage, text string) (string, error) {
+	out, findings, err := chain.Apply(stage, text)
+	reportFromContext(ctx).add(findings)
+	return out, err
+}
@@ -0,0 +1,242 @@
+// Package guard implements guardrails around generation: chains of filters
+// which check and redact prompts before they reach the llm server and
+// generated responses before they reach the client.
+package guard
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	StageInput  = "input"
+	StageOutput = "output"
+
+	ActionBlocked  = "blocked"
+	ActionRedacted = "redacted"
+)
+
+// Finding reports a rule which fired.
+type Finding struct {
+	Stage  string `json:"stage"`
+	Filter string `json:"filter"`
+	Rule   string `json:"rule"`
+	Action string `json:"action"`
+}
+
+func (f Finding) String() string {
+	return f.Stage + ":" + f.Filter + ":" + f.Rule
+}
+
+// BlockedError is returned when a filter rejects the prompt or the response.
+type BlockedError struct {
+	Finding Finding
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("%s blocked by guardrail %s rule %q", e.Finding.Stage, e.Finding.Filter, e.Finding.Rule)
+}
+
+// Filter checks text. It returns the possibly redacted text and the rules
+// which fired, or a *BlockedError to reject the text.
+type Filter interface {
+	Apply(text string) (string, []Finding, error)
+}
+
+// Chain applies filters in order.
+type Chain []Filter
+
+func (c Chain) Apply(stage, text string) (string, []Finding, error) {
+	var findings []Finding
+	for _, f := range c {
+		out, fired, err := f.Apply(text)
+		for i := range fired {
+			fired[i].Stage = stage
+		}
+		findings = append(findings, fired...)
+
+		if blocked, ok := err.(*BlockedError); ok {
+			blocked.Finding.Stage = stage
+			return "", findings, blocked
+		}
+		if err != nil {
+			return "", findings, err
+		}
+		text = out
+	}
+	return text, findings, nil
+}
+
+// MaxLength blocks text longer than Max characters.
+type MaxLength struct {
+	Max int
+}
+
+func (m MaxLength) Apply(text string) (string, []Finding, error) {
+	if utf8.RuneCountInString(text) > m.Max {
+		f := Finding{Filter: "max_length", Rule: strconv.Itoa(m.Max), Action: ActionBlocked}
+		return "", []Finding{f}, &BlockedError{Finding: f}
+	}
+	return text, nil, nil
+}
+
+// Blocklist blocks text matching any of the patterns or containing any of the keywords.
+// Keywords are matched case-insensitively.
+type Blocklist struct {
+	patterns []*regexp.Regexp
+	keywords []string
+}
+
+func NewBlocklist(patterns, keywords []string) (*Blocklist, error) {
+	b := &Blocklist{}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid block pattern %q: %w", p, err)
+		}
+		b.patterns = append(b.patterns, re)
+	}
+	for _, k := range keywords {
+		if k = strings.TrimSpace(k); k != "" {
+			b.keywords = append(b.keywords, strings.ToLower(k))
+		}
+	}
+	return b, nil
+}
+
+func (b *Blocklist) Apply(text string) (string, []Finding, error) {
+	for _, re := range b.patterns {
+		if re.MatchString(text) {
+			return b.block(re.String())
+		}
+	}
+
+	lower := strings.ToLower(text)
+	for _, k := range b.keywords {
+		if strings.Contains(lower, k) {
+			return b.block(k)
+		}
+	}
+	return text, nil, nil
+}
+
+func (b *Blocklist) block(rule string) (string, []Finding, error) {
+	f := Finding{Filter: "blocklist", Rule: rule, Action: ActionBlocked}
+	return "", []Finding{f}, &BlockedError{Finding: f}
+}
+
+// Config describes filter chains for prompts and responses.
+type Config struct {
+	Input  StageConfig `json:"input"`
+	Output StageConfig `json:"output"`
+}
+
+type StageConfig struct {
+	// MaxLength limits the text length in characters; 0 means unlimited.
+	MaxLength     int      `json:"maxLength"`
+	BlockPatterns []string `json:"blockPatterns"`
+	BlockKeywords []string `json:"blockKeywords"`
+	// Redact lists kinds of personal data to redact: email, phone, card.
+	Redact []string `json:"redact"`
+}
+
+func (c StageConfig) chain() (Chain, error) {
+	var chain Chain
+	if c.MaxLength > 0 {
+		chain = append(chain, MaxLength{Max: c.MaxLength})
+	}
+	if len(c.BlockPatterns) > 0 || len(c.BlockKeywords) > 0 {
+		b, err := NewBlocklist(c.BlockPatterns, c.BlockKeywords)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, b)
+	}
+	if len(c.Redact) > 0 {
+		r, err := NewRedactor(c.Redact...)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, r)
+	}
+	return chain, nil
+}
+
+// LoadConfig reads guardrails configuration from a JSON file.
+func LoadConfig(path string) (Config, error) {
+	var cfg Config
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read guardrails config: %w", err)
+	}
+
+	err = json.Unmarshal(b, &cfg)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to parse guardrails config %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// Guard holds the input and output filter chains.
+type Guard struct {
+	Input  Chain
+	Output Chain
+}
+
+func New(cfg Config) (*Guard, error) {
+	input, err := cfg.Input.chain()
+	if err != nil {
+		return nil, fmt.Errorf("input: %w", err)
+	}
+	output, err := cfg.Output.chain()
+	if err != nil {
+		return nil, fmt.Errorf("output: %w", err)
+	}
+	return &Guard{Input: input, Output: output}, nil
+}
+
+// Report collects findings of all generations made for a request.
+type Report struct {
+	mu       sync.Mutex
+	findings []Finding
+}
+
+func (r *Report) add(findings []Finding) {
+	if r == nil || len(findings) == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.findings = append(r.findings, findings...)
+}
+
+func (r *Report) Findings() []Finding {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Finding(nil), r.findings...)
+}
+
+type reportKey struct{}
+
+// WithReport returns a context collecting guardrail findings into a new report.
+func WithReport(ctx context.Context) (context.Context, *Report) {
+	r := &Report{}
+	return context.WithValue(ctx, reportKey{}, r), r
+}
+
+func reportFromContext(ctx context.Context) *Report {
+	r, _ := ctx.Value(reportKey{}).(*Report)
+	return r
+}
@@ -0,0 +1,103 @@
+package guard
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	PIIEmail = "email"
+	PIIPhone = "phone"
+	PIICard  = "card"
+)
+
+type detector struct {
+	kind    string
+	pattern *regexp.Regexp
+	// valid filters out false positives among pattern matches.
+	valid func(match string) bool
+}
+
+// Cards go before phones, so that card numbers aren't redacted as phones.
+var detectors = []detector{
+	{
+		kind:    PIIEmail,
+		pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	},
+	{
+		kind:    PIICard,
+		pattern: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+		valid:   luhn,
+	},
+	{
+		kind:    PIIPhone,
+		pattern: regexp.MustCompile(`(?:\+\d{1,3}[\s-]?)?(?:\(\d{3}\)|\b\d{3})[\s.-]?\d{3}[\s.-]?\d{2}[\s.-]?\d{2}\b`),
+	},
+}
+
+// Redactor replaces personal data with a [REDACTED <KIND>] placeholder.
+type Redactor struct {
+	detectors []detector
+}
+
+func NewRedactor(kinds ...string) (*Redactor, error) {
+	for _, kind := range kinds {
+		if kind != PIIEmail && kind != PIIPhone && kind != PIICard {
+			return nil, fmt.Errorf("unknown personal data kind %q", kind)
+		}
+	}
+
+	r := &Redactor{}
+	for _, d := range detectors {
+		if slices.Contains(kinds, d.kind) {
+			r.detectors = append(r.detectors, d)
+		}
+	}
+	return r, nil
+}
+
+func (r *Redactor) Apply(text string) (string, []Finding, error) {
+	var findings []Finding
+	for _, d := range r.detectors {
+		fired := false
+		text = d.pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if d.valid != nil && !d.valid(match) {
+				return match
+			}
+			fired = true
+			return "[REDACTED " + strings.ToUpper(d.kind) + "]"
+		})
+		if fired {
+			findings = append(findings, Finding{Filter: "pii", Rule: d.kind, Action: ActionRedacted})
+		}
+	}
+	return text, findings, nil
+}
+
+// luhn validates the checksum of a card number, ignoring separators.
+func luhn(number string) bool {
+	var digits []int
+	for _, c := range number {
+		if c >= '0' && c <= '9' {
+			digits = append(digits, int(c-'0'))
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+
+	sum := 0
+	for i := range digits {
+		d := digits[len(digits)-1-i]
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
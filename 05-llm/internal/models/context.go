@@ -0,0 +1,69 @@
+package models
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// lookupRetry is how long a failed lookup is not repeated for the model.
+const lookupRetry = time.Minute
+
+// ContextSizes tells the context length of models: the configured one or,
+// for other models, the one reported by the backend.
+type ContextSizes struct {
+	configured map[string]int
+	lookup     func(ctx context.Context, model string) (int, error)
+	now        func() time.Time
+
+	mu     sync.Mutex
+	known  map[string]int
+	failed map[string]time.Time
+}
+
+// NewContextSizes creates ContextSizes; lookup may be nil if the backend
+// cannot tell context sizes.
+func NewContextSizes(configured map[string]int, lookup func(ctx context.Context, model string) (int, error)) *ContextSizes {
+	return &ContextSizes{
+		configured: configured,
+		lookup:     lookup,
+		now:        time.Now,
+		known:      make(map[string]int),
+		failed:     make(map[string]time.Time),
+	}
+}
+
+// Get returns the context length of the model or zero if it is unknown.
+func (c *ContextSizes) Get(ctx context.Context, model string) int {
+	if size, ok := c.configured[model]; ok {
+		return size
+	}
+	if c.lookup == nil {
+		return 0
+	}
+
+	c.mu.Lock()
+	size, ok := c.known[model]
+	failed, retry := c.failed[model]
+	c.mu.Unlock()
+	if ok || (retry && c.now().Sub(failed) < lookupRetry) {
+		return size
+	}
+
+	size, err := c.lookup(ctx, model)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		// A cancelled request says nothing about the backend.
+		if ctx.Err() == nil {
+			log.Printf("Failed to get context size of %s: %v", model, err)
+			c.failed[model] = c.now()
+		}
+		return 0
+	}
+	delete(c.failed, model)
+	c.known[model] = size
+	return size
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestContextSizes(t *testing.T) {
+	lookups := make(map[string]int)
+	sizes := NewContextSizes(map[string]int{"small": 2048}, func(ctx context.Context, model string) (int, error) {
+		lookups[model]++
+		if model == "broken" {
+			return 0, errors.New("model not found")
+		}
+		return 8192, nil
+	})
+	now := time.Now()
+	sizes.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	tests := []struct {
+		name            string
+		model           string
+		after           time.Duration
+		expectedSize    int
+		expectedLookups int
+	}{
+		{name: "Configured", model: "small", expectedSize: 2048},
+		{name: "Looked up", model: "llama3.2", expectedSize: 8192, expectedLookups: 1},
+		{name: "Cached", model: "llama3.2", expectedSize: 8192, expectedLookups: 1},
+		{name: "Failed", model: "broken", expectedLookups: 1},
+		{name: "Failure cached", model: "broken", after: lookupRetry / 2, expectedLookups: 1},
+		{name: "Failure retried", model: "broken", after: lookupRetry, expectedLookups: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = now.Add(tt.after)
+			if size := sizes.Get(ctx, tt.model); size != tt.expectedSize {
+				t.Errorf("expected size %d, got %d", tt.expectedSize, size)
+			}
+			if lookups[tt.model] != tt.expectedLookups {
+				t.Errorf("expected %d lookups, got %d", tt.expectedLookups, lookups[tt.model])
+			}
+		})
+	}
+}
+
+func TestContextSizesWithoutLookup(t *testing.T) {
+	sizes := NewContextSizes(nil, nil)
+	if size := sizes.Get(context.Background(), "llama3.2"); size != 0 {
+		t.Errorf("expected unknown size, got %d", size)
+	}
+}
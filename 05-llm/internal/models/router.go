@@ -0,0 +1,172 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+)
+
+var (
+	ErrNotAllowed = errors.New("model is not allowed")
+	ErrNoVision   = errors.New("model does not accept images")
+)
+
+// Rule picks Model for requests matching all of its non-zero conditions.
+type Rule struct {
+	MinPromptLength int    `json:"minPromptLength" yaml:"minPromptLength"`
+	MaxPromptLength int    `json:"maxPromptLength" yaml:"maxPromptLength"`
+	Header          string `json:"header" yaml:"header"`
+	HeaderValue     string `json:"headerValue" yaml:"headerValue"`
+	Model           string `json:"model" yaml:"model"`
+}
+
+func (r Rule) matches(prompt string, header http.Header) bool {
+	if r.MinPromptLength > 0 && len(prompt) < r.MinPromptLength {
+		return false
+	}
+	if r.MaxPromptLength > 0 && len(prompt) > r.MaxPromptLength {
+		return false
+	}
+	if r.Header != "" {
+		value := header.Get(r.Header)
+		if value == "" || (r.HeaderValue != "" && value != r.HeaderValue) {
+			return false
+		}
+	}
+	return true
+}
+
+type Config struct {
+	Default string            `json:"default" yaml:"default"`
+	Allowed []string          `json:"allowed" yaml:"allowed"`
+	Aliases map[string]string `json:"aliases" yaml:"aliases"`
+	Rules   []Rule            `json:"rules" yaml:"rules"`
+	// Vision lists the models accepting images; the first one serves image
+	// requests that do not name a model and are not routed to another one.
+	Vision []string `json:"vision" yaml:"vision"`
+	// ContextSizes sets the context length of models in tokens, overriding
+	// the one reported by the backend.
+	ContextSizes map[string]int `json:"contextSizes" yaml:"contextSizes"`
+}
+
+// LoadConfig reads routing configuration from a JSON file.
+func LoadConfig(path string) (Config, error) {
+	var cfg Config
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read models config: %w", err)
+	}
+
+	err = json.Unmarshal(b, &cfg)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to parse models config %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// Router decides which model serves a request.
+type Router struct {
+	defaultModel string
+	allowed      map[string]bool
+	aliases      map[string]string
+	rules        []Rule
+	vision       []string
+}
+
+func NewRouter(cfg Config) (*Router, error) {
+	r := &Router{
+		defaultModel: cfg.Default,
+		aliases:      cfg.Aliases,
+		rules:        cfg.Rules,
+	}
+	if len(cfg.Allowed) > 0 {
+		r.allowed = make(map[string]bool, len(cfg.Allowed))
+		for _, m := range cfg.Allowed {
+			r.allowed[m] = true
+		}
+	}
+
+	if r.defaultModel == "" {
+		return nil, fmt.Errorf("default model is not set")
+	}
+	if _, err := r.check(r.defaultModel); err != nil {
+		return nil, fmt.Errorf("default model: %w", err)
+	}
+	for _, m := range cfg.Vision {
+		model, err := r.check(m)
+		if err != nil {
+			return nil, fmt.Errorf("vision model: %w", err)
+		}
+		r.vision = append(r.vision, model)
+	}
+	for model, size := range cfg.ContextSizes {
+		if size <= 0 {
+			return nil, fmt.Errorf("context size of %s must be positive", model)
+		}
+	}
+	for i, rule := range r.rules {
+		if rule.Model == "" {
+			return nil, fmt.Errorf("rule %d: model is not set", i)
+		}
+		if _, err := r.check(rule.Model); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+	}
+
+	return r, nil
+}
+
+// Default returns the model serving requests which match no rule.
+func (r *Router) Default() string {
+	model, _ := r.check(r.defaultModel)
+	return model
+}
+
+// Resolve returns the effective model for a request. An explicitly requested
+// model wins over routing rules, rules are evaluated in order and the default
+// model is used when none of them matches.
+func (r *Router) Resolve(requested, prompt string, header http.Header) (string, error) {
+	if requested != "" {
+		return r.check(requested)
+	}
+
+	for _, rule := range r.rules {
+		if rule.matches(prompt, header) {
+			return r.check(rule.Model)
+		}
+	}
+
+	return r.check(r.defaultModel)
+}
+
+// ResolveVision returns the model for a request with images. It resolves the
+// model as Resolve does, but only vision models qualify: a routed model
+// that does not accept images is replaced with the first vision model.
+func (r *Router) ResolveVision(requested, prompt string, header http.Header) (string, error) {
+	model, err := r.Resolve(requested, prompt, header)
+	if err != nil {
+		return "", err
+	}
+	if slices.Contains(r.vision, model) {
+		return model, nil
+	}
+	if requested != "" || len(r.vision) == 0 {
+		return "", fmt.Errorf("%w: %s", ErrNoVision, model)
+	}
+	return r.vision[0], nil
+}
+
+func (r *Router) check(model string) (string, error) {
+	if alias, ok := r.aliases[model]; ok {
+		model = alias
+	}
+	if r.allowed != nil && !r.allowed[model] {
+		return "", fmt.Errorf("%w: %s", ErrNotAllowed, model)
+	}
+	return model, nil
+}
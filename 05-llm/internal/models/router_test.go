@@ -0,0 +1,115 @@
+package models
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRouterResolve(t *testing.T) {
+	router, err := NewRouter(Config{
+		Default: "llama3.2",
+		Allowed: []string{"llama3.2", "llama3.2:1b", "llama3.1:70b"},
+		Aliases: map[string]string{"fast": "llama3.2:1b", "smart": "llama3.1:70b"},
+		Rules: []Rule{
+			{Header: "X-Tier", HeaderValue: "premium", Model: "smart"},
+			{MinPromptLength: 100, Model: "smart"},
+			{MaxPromptLength: 10, Model: "fast"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		requested string
+		prompt    string
+		header    http.Header
+		want      string
+		wantErr   error
+	}{
+		{name: "Default model", prompt: "medium sized prompt", want: "llama3.2"},
+		{name: "Explicit model", requested: "llama3.2:1b", prompt: strings.Repeat("a", 200), want: "llama3.2:1b"},
+		{name: "Alias", requested: "smart", prompt: "hi", want: "llama3.1:70b"},
+		{name: "Not allowed", requested: "mistral", prompt: "hi", wantErr: ErrNotAllowed},
+		{name: "Header rule", prompt: "hi", header: http.Header{"X-Tier": {"premium"}}, want: "llama3.1:70b"},
+		{name: "Long prompt rule", prompt: strings.Repeat("a", 200), want: "llama3.1:70b"},
+		{name: "Short prompt rule", prompt: "hi", want: "llama3.2:1b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := router.Resolve(tt.requested, tt.prompt, tt.header)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Resolve() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Resolve() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRouterValidation(t *testing.T) {
+	_, err := NewRouter(Config{Default: "mistral", Allowed: []string{"llama3.2"}})
+	if !errors.Is(err, ErrNotAllowed) {
+		t.Errorf("expected ErrNotAllowed for disallowed default model, got %v", err)
+	}
+
+	_, err = NewRouter(Config{})
+	if err == nil {
+		t.Error("expected error for missing default model")
+	}
+
+	_, err = NewRouter(Config{Default: "llama3.2", Allowed: []string{"llama3.2"}, Vision: []string{"llava"}})
+	if !errors.Is(err, ErrNotAllowed) {
+		t.Errorf("expected ErrNotAllowed for disallowed vision model, got %v", err)
+	}
+}
+
+func TestRouterResolveVision(t *testing.T) {
+	router, err := NewRouter(Config{
+		Default: "llama3.2",
+		Allowed: []string{"llama3.2", "llava", "llama3.2-vision"},
+		Aliases: map[string]string{"eyes": "llama3.2-vision"},
+		Rules:   []Rule{{Header: "X-Tier", HeaderValue: "premium", Model: "llama3.2-vision"}},
+		Vision:  []string{"llava", "eyes"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		requested string
+		header    http.Header
+		want      string
+		wantErr   error
+	}{
+		{name: "Default is not a vision model", want: "llava"},
+		{name: "Routed vision model", header: http.Header{"X-Tier": {"premium"}}, want: "llama3.2-vision"},
+		{name: "Explicit alias", requested: "eyes", want: "llama3.2-vision"},
+		{name: "Explicit text model", requested: "llama3.2", wantErr: ErrNoVision},
+		{name: "Not allowed", requested: "bakllava", wantErr: ErrNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := router.ResolveVision(tt.requested, "describe", tt.header)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ResolveVision() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveVision() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	noVision, err := NewRouter(Config{Default: "llama3.2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := noVision.ResolveVision("", "describe", nil); !errors.Is(err, ErrNoVision) {
+		t.Errorf("expected ErrNoVision without vision models, got %v", err)
+	}
+}
@@ -0,0 +1,503 @@
+// Package ollamatest provides a fake Ollama server for tests.
+//
+// The server implements /api/generate, /api/chat, /api/embeddings,
+// /api/tags and /api/show. By default it echoes the prompt like backend.Fake; replies can
+// be scripted per prompt or as a sequence, latency and errors can be
+// injected, and every request is recorded for assertions.
+package ollamatest
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	embeddingSize = 64
+	// ContextLength is reported by /api/show as the trained context length.
+	ContextLength = 8192
+)
+
+// Message is a chat message in the Ollama wire format.
+type Message struct {
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	ToolName  string     `json:"tool_name,omitempty"`
+	Images    []string   `json:"images,omitempty"`
+}
+
+type ToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+// NewToolCall builds a call of the named tool with arguments marshalled to JSON.
+func NewToolCall(name string, args any) ToolCall {
+	var c ToolCall
+	c.Function.Name = name
+	c.Function.Arguments, _ = json.Marshal(args)
+	return c
+}
+
+// Reply is a scripted answer. A non-zero Status makes the server fail
+// the request with Error as the message.
+type Reply struct {
+	Content   string
+	ToolCalls []ToolCall
+	Status    int
+	Error     string
+}
+
+// Request is a request received by the server.
+type Request struct {
+	Path     string
+	Model    string
+	Prompt   string
+	System   string
+	Images   []string
+	Messages []Message
+	Stream   bool
+	Format   json.RawMessage
+	Tools    json.RawMessage
+	Options  map[string]any
+	Body     json.RawMessage
+}
+
+// LastUserMessage returns the prompt of a generate request or the last user message of a chat.
+func (r Request) LastUserMessage() string {
+	if r.Path == "/api/generate" || r.Path == "/api/embeddings" {
+		return r.Prompt
+	}
+	for i := len(r.Messages) - 1; i >= 0; i-- {
+		if r.Messages[i].Role == "user" {
+			return r.Messages[i].Content
+		}
+	}
+	return ""
+}
+
+type request struct {
+	Model    string          `json:"model"`
+	Prompt   string          `json:"prompt"`
+	System   string          `json:"system"`
+	Images   []string        `json:"images"`
+	Messages []Message       `json:"messages"`
+	Stream   *bool           `json:"stream"`
+	Format   json.RawMessage `json:"format"`
+	Tools    json.RawMessage `json:"tools"`
+	Options  map[string]any  `json:"options"`
+}
+
+type response struct {
+	Model           string   `json:"model"`
+	CreatedAt       string   `json:"created_at"`
+	Response        *string  `json:"response,omitempty"`
+	Message         *Message `json:"message,omitempty"`
+	Done            bool     `json:"done"`
+	DoneReason      string   `json:"done_reason,omitempty"`
+	PromptEvalCount int      `json:"prompt_eval_count,omitempty"`
+	EvalCount       int      `json:"eval_count,omitempty"`
+	TotalDuration   int64    `json:"total_duration,omitempty"`
+	LoadDuration    int64    `json:"load_duration,omitempty"`
+	EvalDuration    int64    `json:"eval_duration,omitempty"`
+}
+
+// Server is a fake Ollama server. Use NewServer to start one.
+type Server struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	models     []string
+	contexts   map[string]int
+	replies    map[string]Reply
+	script     []Reply
+	failures   []Reply
+	latency    time.Duration
+	chunkDelay time.Duration
+	requests   []Request
+	cancelled  int
+}
+
+// NewServer starts a server serving the given models ("llama3.2" if none).
+// Requests may name any model; the list is only reported by /api/tags.
+func NewServer(models ...string) *Server {
+	if len(models) == 0 {
+		models = []string{"llama3.2"}
+	}
+	s := &Server{models: models, contexts: make(map[string]int), replies: make(map[string]Reply)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/generate", s.handleGenerate)
+	mux.HandleFunc("POST /api/chat", s.handleChat)
+	mux.HandleFunc("POST /api/embeddings", s.handleEmbeddings)
+	mux.HandleFunc("GET /api/tags", s.handleTags)
+	mux.HandleFunc("POST /api/show", s.handleShow)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// SetReply answers requests whose last user message is prompt with reply.
+func (s *Server) SetReply(prompt string, reply Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.replies[prompt] = reply
+}
+
+// Script queues replies returned in order to the next generation requests
+// regardless of their prompts. Scripted replies take precedence over SetReply.
+func (s *Server) Script(replies ...Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.script = append(s.script, replies...)
+}
+
+// FailNext makes the next n requests to any endpoint fail with status and message.
+func (s *Server) FailNext(n, status int, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for range n {
+		s.failures = append(s.failures, Reply{Status: status, Error: message})
+	}
+}
+
+// SetLatency delays every response, i.e. the time to the first token.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.latency = d
+}
+
+// SetChunkDelay delays every streamed chunk after the first one.
+func (s *Server) SetChunkDelay(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.chunkDelay = d
+}
+
+// Requests returns the recorded requests in the order they were received.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Request(nil), s.requests...)
+}
+
+// Cancelled returns the number of requests aborted by the client.
+func (s *Server) Cancelled() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.cancelled
+}
+
+// SetContextLength makes /api/show report n as num_ctx of the Modelfile of
+// model, the context it runs with unless the client sets another.
+func (s *Server) SetContextLength(model string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.contexts[model] = n
+}
+
+// Reset forgets recorded requests and all scripted behaviour.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.replies = make(map[string]Reply)
+	s.contexts = make(map[string]int)
+	s.script = nil
+	s.failures = nil
+	s.latency = 0
+	s.chunkDelay = 0
+	s.requests = nil
+	s.cancelled = 0
+}
+
+// receive decodes and records the request. It returns false if the response was already written.
+func (s *Server) receive(w http.ResponseWriter, r *http.Request) (Request, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return Request{}, false
+	}
+
+	var in request
+	err = json.Unmarshal(body, &in)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return Request{}, false
+	}
+
+	req := Request{
+		Path:     r.URL.Path,
+		Model:    in.Model,
+		Prompt:   in.Prompt,
+		System:   in.System,
+		Images:   in.Images,
+		Messages: in.Messages,
+		Stream:   in.Stream == nil || *in.Stream,
+		Format:   in.Format,
+		Tools:    in.Tools,
+		Options:  in.Options,
+		Body:     body,
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	var failure *Reply
+	if len(s.failures) > 0 {
+		failure = &s.failures[0]
+		s.failures = s.failures[1:]
+	}
+	latency := s.latency
+	s.mu.Unlock()
+
+	if failure != nil {
+		writeError(w, failure.Status, failure.Error)
+		return Request{}, false
+	}
+	if in.Model == "" {
+		writeError(w, http.StatusBadRequest, "model is required")
+		return Request{}, false
+	}
+	if !s.sleep(r, latency) {
+		return Request{}, false
+	}
+	return req, true
+}
+
+// sleep waits for d and reports false if the client went away meanwhile.
+func (s *Server) sleep(r *http.Request, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-r.Context().Done():
+		s.mu.Lock()
+		s.cancelled++
+		s.mu.Unlock()
+		return false
+	}
+}
+
+func (s *Server) reply(prompt string) Reply {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.script) > 0 {
+		reply := s.script[0]
+		s.script = s.script[1:]
+		return reply
+	}
+	if reply, ok := s.replies[prompt]; ok {
+		return reply
+	}
+	return Reply{Content: "echo: " + prompt}
+}
+
+func (s *Server) handleGenerate(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.receive(w, r)
+	if !ok {
+		return
+	}
+	s.generate(w, r, req, func(resp *response, content string) {
+		resp.Response = &content
+	})
+}
+
+func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.receive(w, r)
+	if !ok {
+		return
+	}
+	s.generate(w, r, req, func(resp *response, content string) {
+		resp.Message = &Message{Role: "assistant", Content: content}
+	})
+}
+
+// generate writes the reply either as a single JSON object or as a stream
+// of NDJSON chunks, one per word, followed by the final chunk with counts.
+func (s *Server) generate(w http.ResponseWriter, r *http.Request, req Request, set func(*response, string)) {
+	reply := s.reply(req.LastUserMessage())
+	if reply.Status != 0 {
+		writeError(w, reply.Status, reply.Error)
+		return
+	}
+
+	promptTokens := countWords(req.Prompt) + countWords(req.System)
+	for _, m := range req.Messages {
+		promptTokens += countWords(m.Content)
+	}
+	final := response{
+		Model:           req.Model,
+		CreatedAt:       time.Now().UTC().Format(time.RFC3339Nano),
+		Done:            true,
+		DoneReason:      "stop",
+		PromptEvalCount: promptTokens,
+		EvalCount:       countWords(reply.Content),
+		TotalDuration:   int64(2 * time.Millisecond),
+		LoadDuration:    int64(time.Millisecond),
+		EvalDuration:    int64(time.Millisecond),
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	if !req.Stream {
+		set(&final, reply.Content)
+		if final.Message != nil {
+			final.Message.ToolCalls = reply.ToolCalls
+		}
+		json.NewEncoder(w).Encode(final)
+		return
+	}
+
+	s.mu.Lock()
+	chunkDelay := s.chunkDelay
+	s.mu.Unlock()
+
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	for i, word := range strings.SplitAfter(reply.Content, " ") {
+		if word == "" {
+			continue
+		}
+		if i > 0 && !s.sleep(r, chunkDelay) {
+			return
+		}
+		chunk := response{Model: req.Model, CreatedAt: final.CreatedAt}
+		set(&chunk, word)
+		if enc.Encode(chunk) != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	set(&final, "")
+	if final.Message != nil {
+		final.Message.ToolCalls = reply.ToolCalls
+	}
+	enc.Encode(final)
+}
+
+func (s *Server) handleEmbeddings(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.receive(w, r)
+	if !ok {
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string][]float64{"embedding": Embedding(req.Prompt)})
+}
+
+func (s *Server) handleTags(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{Path: r.URL.Path})
+	var failure *Reply
+	if len(s.failures) > 0 {
+		failure = &s.failures[0]
+		s.failures = s.failures[1:]
+	}
+	models := s.models
+	s.mu.Unlock()
+
+	if failure != nil {
+		writeError(w, failure.Status, failure.Error)
+		return
+	}
+
+	type model struct {
+		Name  string `json:"name"`
+		Model string `json:"model"`
+	}
+	out := struct {
+		Models []model `json:"models"`
+	}{}
+	for _, name := range models {
+		out.Models = append(out.Models, model{Name: name, Model: name})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+func (s *Server) handleShow(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.receive(w, r)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	n, pinned := s.contexts[req.Model]
+	known := pinned || slices.Contains(s.models, req.Model)
+	s.mu.Unlock()
+	if !known {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("model '%s' not found", req.Model))
+		return
+	}
+
+	out := map[string]any{
+		"model_info": map[string]any{
+			"general.architecture": "llama",
+			"llama.context_length": max(n, ContextLength),
+		},
+	}
+	if pinned {
+		out["parameters"] = fmt.Sprintf("num_ctx %d", n)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+// Embedding returns the normalized bag-of-words vector the server answers for text.
+func Embedding(text string) []float64 {
+	vector := make([]float64, embeddingSize)
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		h := fnv.New32a()
+		h.Write([]byte(strings.Trim(word, ".,!?;:\"'()")))
+		vector[h.Sum32()%embeddingSize]++
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += v * v
+	}
+	if norm > 0 {
+		norm = math.Sqrt(norm)
+		for i := range vector {
+			vector[i] /= norm
+		}
+	}
+	return vector
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "{\"error\":%q}\n", message)
+}
+
+func countWords(s string) int {
+	return len(strings.Fields(s))
+}
@@ -0,0 +1,90 @@
+package ollamatest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+)
+
+func newClient(t *testing.T, s *Server) *backend.Ollama {
+	t.Helper()
+
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return backend.NewOllama(u, s.Client())
+}
+
+func TestServer(t *testing.T) {
+	s := NewServer("llama3.2", "nomic-embed-text")
+	defer s.Close()
+	client := newClient(t, s)
+	ctx := context.Background()
+
+	models, err := client.ListModels(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 2 || models[1].Name != "nomic-embed-text" {
+		t.Errorf("unexpected models %+v", models)
+	}
+
+	s.SetChunkDelay(time.Millisecond)
+	var chunks []string
+	resp, err := client.Stream(ctx, backend.ChatRequest{
+		Model:    "llama3.2",
+		Messages: []backend.Message{{Role: "user", Content: "one two"}},
+	}, func(c backend.Chunk) error {
+		chunks = append(chunks, c.Content)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 3 || resp.Content != "echo: one two" {
+		t.Errorf("unexpected stream %q of %q", chunks, resp.Content)
+	}
+	if resp.Usage.PromptTokens != 2 || resp.Usage.CompletionTokens != 3 {
+		t.Errorf("unexpected usage %+v", resp.Usage)
+	}
+
+	s.FailNext(1, http.StatusInternalServerError, "boom")
+	_, err = client.Generate(ctx, backend.GenerateRequest{Model: "llama3.2", Prompt: "hi"})
+	var statusErr backend.StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected injected error, got %v", err)
+	}
+
+	requests := s.Requests()
+	if len(requests) != 3 || requests[2].Path != "/api/generate" || requests[2].LastUserMessage() != "hi" {
+		t.Errorf("unexpected recorded requests %+v", requests)
+	}
+}
+
+func TestServerLatency(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.SetLatency(time.Second)
+	client := newClient(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := client.Generate(ctx, backend.GenerateRequest{Model: "llama3.2", Prompt: "hi"})
+	if err == nil {
+		t.Fatal("expected deadline error")
+	}
+
+	for range 50 {
+		if s.Cancelled() == 1 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("server saw %d cancellations, want 1", s.Cancelled())
+}
@@ -0,0 +1,58 @@
+package queue
+
+import (
+	"context"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+)
+
+// Backend runs generations of the wrapped backend under the limiter.
+// Listing models is cheap and is not limited.
+type Backend struct {
+	backend.Backend
+	limiter *Limiter
+}
+
+func NewBackend(b backend.Backend, limiter *Limiter) *Backend {
+	return &Backend{Backend: b, limiter: limiter}
+}
+
+func (b *Backend) Generate(ctx context.Context, req backend.GenerateRequest) (backend.Response, error) {
+	release, err := b.limiter.Acquire(ctx)
+	if err != nil {
+		return backend.Response{}, err
+	}
+	defer release()
+
+	return b.Backend.Generate(ctx, req)
+}
+
+func (b *Backend) Chat(ctx context.Context, req backend.ChatRequest) (backend.Response, error) {
+	release, err := b.limiter.Acquire(ctx)
+	if err != nil {
+		return backend.Response{}, err
+	}
+	defer release()
+
+	return b.Backend.Chat(ctx, req)
+}
+
+func (b *Backend) Stream(ctx context.Context, req backend.ChatRequest, fn func(backend.Chunk) error) (backend.Response, error) {
+	release, err := b.limiter.Acquire(ctx)
+	if err != nil {
+		return backend.Response{}, err
+	}
+	defer release()
+
+	return b.Backend.Stream(ctx, req, fn)
+}
+
+func (b *Backend) Embed(ctx context.Context, req backend.EmbedRequest) (backend.EmbedResponse, error) {
+	release, err := b.limiter.Acquire(ctx)
+	if err != nil {
+		return backend.EmbedResponse{}, err
+	}
+	defer release()
+
+	return b.Backend.Embed(ctx, req)
+}
@@ -0,0 +1,251 @@
+package queue
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type Priority int
+
+const (
+	Interactive Priority = iota
+	Batch
+)
+
+var priorityNames = [...]string{Interactive: "interactive", Batch: "batch"}
+
+func (p Priority) String() string {
+	return priorityNames[p]
+}
+
+// ParsePriority converts a lane name into Priority. Unknown names fall back to Interactive.
+func ParsePriority(s string) Priority {
+	if s == priorityNames[Batch] {
+		return Batch
+	}
+	return Interactive
+}
+
+// OverloadError is returned when a request could not get an execution slot.
+type OverloadError struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e OverloadError) Error() string {
+	return fmt.Sprintf("llm backend is overloaded: %s", e.Reason)
+}
+
+type waiter struct {
+	ready    chan struct{}
+	enqueued time.Time
+	elem     *list.Element
+	lane     Priority
+}
+
+type Stats struct {
+	InFlight  int            `json:"inFlight"`
+	Queued    map[string]int `json:"queued"`
+	Acquired  int64          `json:"acquired"`
+	Rejected  int64          `json:"rejected"`
+	TimedOut  int64          `json:"timedOut"`
+	AvgWaitMs float64        `json:"avgWaitMs"`
+	MaxWaitMs float64        `json:"maxWaitMs"`
+}
+
+// Limiter bounds the number of requests executed concurrently. Requests over
+// the limit wait in per-priority FIFO queues; interactive requests are always
+// served before batch ones.
+type Limiter struct {
+	mu          sync.Mutex
+	maxInFlight int
+	maxQueued   int
+	timeout     time.Duration
+	inFlight    int
+	lanes       [len(priorityNames)]*list.List
+
+	acquired  int64
+	rejected  int64
+	timedOut  int64
+	totalWait time.Duration
+	maxWait   time.Duration
+	avgHold   time.Duration
+}
+
+func NewLimiter(maxInFlight, maxQueued int, timeout time.Duration) *Limiter {
+	l := &Limiter{maxInFlight: maxInFlight, maxQueued: maxQueued, timeout: timeout}
+	for i := range l.lanes {
+		l.lanes[i] = list.New()
+	}
+	return l
+}
+
+// Acquire waits for an execution slot. The returned function must be called
+// to free the slot once the request is done.
+func (l *Limiter) Acquire(ctx context.Context) (func(), error) {
+	priority, timeout := fromContext(ctx)
+	if timeout <= 0 || (l.timeout > 0 && timeout > l.timeout) {
+		timeout = l.timeout
+	}
+
+	l.mu.Lock()
+	if l.inFlight < l.maxInFlight && l.queued() == 0 {
+		l.inFlight++
+		l.acquired++
+		l.mu.Unlock()
+		return l.releaseFunc(time.Now()), nil
+	}
+	if l.queued() >= l.maxQueued {
+		l.rejected++
+		err := OverloadError{Reason: "queue is full", RetryAfter: l.retryAfter()}
+		l.mu.Unlock()
+		return nil, err
+	}
+
+	w := &waiter{ready: make(chan struct{}), enqueued: time.Now(), lane: priority}
+	w.elem = l.lanes[priority].PushBack(w)
+	l.mu.Unlock()
+
+	var timer <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		timer = t.C
+	}
+
+	select {
+	case <-w.ready:
+		return l.releaseFunc(time.Now()), nil
+	case <-timer:
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		// The slot was granted while we were giving up.
+		if ctx.Err() == nil {
+			return l.releaseFunc(time.Now()), nil
+		}
+		l.release(0)
+		return nil, ctx.Err()
+	default:
+	}
+
+	l.lanes[w.lane].Remove(w.elem)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	l.timedOut++
+	return nil, OverloadError{Reason: "timed out waiting in queue", RetryAfter: l.retryAfter()}
+}
+
+// SetLimits changes the limits of a running limiter. Requests in flight
+// keep their slots even if there are more of them than the new limit;
+// waiters are admitted right away if the limit grows.
+func (l *Limiter) SetLimits(maxInFlight, maxQueued int, timeout time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.maxInFlight = maxInFlight
+	l.maxQueued = maxQueued
+	l.timeout = timeout
+	l.dispatch()
+}
+
+func (l *Limiter) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	s := Stats{
+		InFlight:  l.inFlight,
+		Queued:    make(map[string]int, len(l.lanes)),
+		Acquired:  l.acquired,
+		Rejected:  l.rejected,
+		TimedOut:  l.timedOut,
+		MaxWaitMs: float64(l.maxWait) / float64(time.Millisecond),
+	}
+	for p, lane := range l.lanes {
+		s.Queued[Priority(p).String()] = lane.Len()
+	}
+	if l.acquired > 0 {
+		s.AvgWaitMs = float64(l.totalWait) / float64(l.acquired) / float64(time.Millisecond)
+	}
+	return s
+}
+
+func (l *Limiter) releaseFunc(start time.Time) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.release(time.Since(start))
+		})
+	}
+}
+
+// release frees a slot and hands it to the next waiter. Must be called with l.mu held.
+func (l *Limiter) release(hold time.Duration) {
+	if hold > 0 {
+		// Exponentially weighted average of the time a slot is held.
+		l.avgHold = (l.avgHold*7 + hold) / 8
+	}
+	l.inFlight--
+	l.dispatch()
+}
+
+// dispatch hands free slots to the waiters. Must be called with l.mu held.
+func (l *Limiter) dispatch() {
+	for _, lane := range l.lanes {
+		if l.inFlight >= l.maxInFlight {
+			return
+		}
+		for lane.Len() > 0 && l.inFlight < l.maxInFlight {
+			w := lane.Remove(lane.Front()).(*waiter)
+			wait := time.Since(w.enqueued)
+			l.totalWait += wait
+			l.maxWait = max(l.maxWait, wait)
+			l.inFlight++
+			l.acquired++
+			close(w.ready)
+		}
+	}
+}
+
+func (l *Limiter) queued() int {
+	total := 0
+	for _, lane := range l.lanes {
+		total += lane.Len()
+	}
+	return total
+}
+
+// retryAfter estimates when the queue drains. Must be called with l.mu held.
+func (l *Limiter) retryAfter() time.Duration {
+	estimate := l.avgHold * time.Duration(l.queued()+1) / time.Duration(max(l.maxInFlight, 1))
+	return max(estimate, time.Second)
+}
+
+type contextKey struct{}
+
+type requestOptions struct {
+	priority Priority
+	timeout  time.Duration
+}
+
+// WithPriority returns a context which makes Acquire put the request into the given lane.
+// A positive timeout overrides the limiter's queue timeout but can't exceed it.
+func WithPriority(ctx context.Context, p Priority, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, contextKey{}, requestOptions{priority: p, timeout: timeout})
+}
+
+func fromContext(ctx context.Context) (Priority, time.Duration) {
+	opts, _ := ctx.Value(contextKey{}).(requestOptions)
+	return opts.priority, opts.timeout
+}
@@ -0,0 +1,159 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimiterPriority(t *testing.T) {
+	l := NewLimiter(1, 10, time.Second)
+
+	release, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	order := make(chan string, 3)
+	start := func(name string, p Priority) {
+		queued := l.Stats().Queued[p.String()]
+		go func() {
+			release, err := l.Acquire(WithPriority(context.Background(), p, 0))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			order <- name
+			release()
+		}()
+		waitFor(t, func() bool { return l.Stats().Queued[p.String()] > queued })
+	}
+
+	start("batch", Batch)
+	start("interactive-1", Interactive)
+	start("interactive-2", Interactive)
+
+	if s := l.Stats(); s.InFlight != 1 || s.Queued["interactive"] != 2 || s.Queued["batch"] != 1 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+
+	release()
+
+	for _, want := range []string{"interactive-1", "interactive-2", "batch"} {
+		if got := <-order; got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+}
+
+func TestLimiterOverload(t *testing.T) {
+	l := NewLimiter(1, 1, 20*time.Millisecond)
+
+	release, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	waited := make(chan error)
+	go func() {
+		_, err := l.Acquire(context.Background())
+		waited <- err
+	}()
+	waitFor(t, func() bool { return l.Stats().Queued["interactive"] == 1 })
+
+	var overload OverloadError
+	if _, err = l.Acquire(context.Background()); !errors.As(err, &overload) || overload.Reason != "queue is full" {
+		t.Errorf("expected full queue error, got %v", err)
+	}
+	if overload.RetryAfter < time.Second {
+		t.Errorf("expected retry after at least a second, got %v", overload.RetryAfter)
+	}
+
+	if err = <-waited; !errors.As(err, &overload) || overload.Reason != "timed out waiting in queue" {
+		t.Errorf("expected timeout error, got %v", err)
+	}
+
+	s := l.Stats()
+	if s.Rejected != 1 || s.TimedOut != 1 || s.Queued["interactive"] != 0 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestLimiterCancel(t *testing.T) {
+	l := NewLimiter(1, 1, time.Minute)
+
+	release, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	waited := make(chan error)
+	go func() {
+		_, err := l.Acquire(ctx)
+		waited <- err
+	}()
+	waitFor(t, func() bool { return l.Stats().Queued["interactive"] == 1 })
+
+	cancel()
+	if err = <-waited; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if s := l.Stats(); s.Queued["interactive"] != 0 {
+		t.Errorf("expected canceled request to leave the queue, got %+v", s)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition was not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLimiterSetLimits(t *testing.T) {
+	l := NewLimiter(1, 10, time.Second)
+
+	release, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	acquired := make(chan func())
+	go func() {
+		release, err := l.Acquire(context.Background())
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		acquired <- release
+	}()
+	waitFor(t, func() bool { return l.Stats().Queued["interactive"] == 1 })
+
+	// The waiter gets the new slot without the first request finishing.
+	l.SetLimits(2, 10, time.Second)
+	second := <-acquired
+
+	// Shrinking the limit keeps both requests running but queues new ones.
+	l.SetLimits(1, 0, time.Second)
+	if s := l.Stats(); s.InFlight != 2 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+	var overload OverloadError
+	if _, err := l.Acquire(context.Background()); !errors.As(err, &overload) {
+		t.Errorf("expected OverloadError with a zero queue, got %v", err)
+	}
+
+	release()
+	second()
+	if s := l.Stats(); s.InFlight != 0 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
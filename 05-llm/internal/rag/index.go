@@ -0,0 +1,232 @@
+package rag
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+var ErrNotFound = errors.New("document not found")
+
+type Chunk struct {
+	Text   string    `json:"text"`
+	Vector []float64 `json:"vector"`
+}
+
+type Document struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Source    string    `json:"source,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	Chunks    []Chunk   `json:"chunks"`
+}
+
+type Result struct {
+	DocumentID string  `json:"documentId"`
+	Title      string  `json:"title"`
+	Source     string  `json:"source,omitempty"`
+	Chunk      int     `json:"chunk"`
+	Text       string  `json:"text"`
+	Score      float64 `json:"score"`
+}
+
+// Index is an in-memory vector index with optional persistence to a JSON file.
+type Index struct {
+	mu        sync.RWMutex
+	path      string
+	model     string
+	documents map[string]*Document
+}
+
+type indexFile struct {
+	Model     string      `json:"model"`
+	Documents []*Document `json:"documents"`
+}
+
+// Open loads the index from path. A missing file gives an empty index and
+// an empty path gives an index which is never persisted.
+func Open(path string) (*Index, error) {
+	ix := &Index{path: path, documents: make(map[string]*Document)}
+	if path == "" {
+		return ix, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ix, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+
+	var f indexFile
+	err = json.Unmarshal(b, &f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse index %s: %w", path, err)
+	}
+
+	ix.model = f.Model
+	for _, doc := range f.Documents {
+		ix.documents[doc.ID] = doc
+	}
+	return ix, nil
+}
+
+// Put adds or replaces a document. All documents must be embedded by the same model.
+func (ix *Index) Put(model string, doc Document) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	if ix.model != "" && ix.model != model && len(ix.documents) > 0 {
+		return fmt.Errorf("index is built with embedding model %s, got %s", ix.model, model)
+	}
+
+	prev, existed := ix.documents[doc.ID]
+	prevModel := ix.model
+	ix.model = model
+	ix.documents[doc.ID] = &doc
+
+	err := ix.save()
+	if err != nil {
+		if existed {
+			ix.documents[doc.ID] = prev
+		} else {
+			delete(ix.documents, doc.ID)
+		}
+		ix.model = prevModel
+	}
+	return err
+}
+
+func (ix *Index) Delete(id string) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	doc, ok := ix.documents[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(ix.documents, id)
+
+	err := ix.save()
+	if err != nil {
+		ix.documents[id] = doc
+	}
+	return err
+}
+
+// Documents returns stored documents without their chunks.
+func (ix *Index) Documents() []Document {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	docs := make([]Document, 0, len(ix.documents))
+	for _, doc := range ix.documents {
+		d := *doc
+		d.Chunks = nil
+		docs = append(docs, d)
+	}
+	slices.SortFunc(docs, func(a, b Document) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return docs
+}
+
+// Model returns the embedding model the index is built with.
+func (ix *Index) Model() string {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	return ix.model
+}
+
+// Search returns k chunks most similar to vector by cosine similarity.
+func (ix *Index) Search(vector []float64, k int) []Result {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	var results []Result
+	for _, doc := range ix.documents {
+		for i, chunk := range doc.Chunks {
+			if len(chunk.Vector) != len(vector) {
+				continue
+			}
+			results = append(results, Result{
+				DocumentID: doc.ID,
+				Title:      doc.Title,
+				Source:     doc.Source,
+				Chunk:      i,
+				Text:       chunk.Text,
+				Score:      cosine(vector, chunk.Vector),
+			})
+		}
+	}
+
+	slices.SortFunc(results, func(a, b Result) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		}
+		return 0
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+
+// save writes the index atomically. Must be called with ix.mu held.
+func (ix *Index) save() error {
+	if ix.path == "" {
+		return nil
+	}
+
+	f := indexFile{Model: ix.model, Documents: make([]*Document, 0, len(ix.documents))}
+	for _, doc := range ix.documents {
+		f.Documents = append(f.Documents, doc)
+	}
+	b, err := json.Marshal(f)
+	if err != nil {
+		return fmt.Errorf("failed to marshal index: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(ix.path), filepath.Base(ix.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to save index: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(b)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), ix.path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save index: %w", err)
+	}
+	return nil
+}
+
+func cosine(a, b []float64) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
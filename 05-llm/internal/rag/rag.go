@@ -0,0 +1,135 @@
+package rag
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+)
+
+const systemPrompt = `Answer the question using only the numbered context passages below.
+Cite the passages you used as [n] right after the statement they support.
+If the context does not contain the answer, say that you don't know.`
+
+var (
+	ErrEmptyDocument = errors.New("document is empty")
+	// ErrEmbedding wraps the failures of the embedding backend, as opposed
+	// to those of the index.
+	ErrEmbedding = errors.New("failed to embed document")
+)
+
+// Chunker splits text into overlapping chunks of Size words.
+type Chunker struct {
+	Size    int
+	Overlap int
+}
+
+// Validate reports a chunk size below one word or an overlap which is
+// negative or not smaller than the size.
+func (c Chunker) Validate() error {
+	if c.Size < 1 {
+		return fmt.Errorf("chunk size %d must be positive", c.Size)
+	}
+	if c.Overlap < 0 || c.Overlap >= c.Size {
+		return fmt.Errorf("chunk overlap %d must be between 0 and the chunk size %d", c.Overlap, c.Size)
+	}
+	return nil
+}
+
+func (c Chunker) Split(text string) []string {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return nil
+	}
+
+	step := max(c.Size-c.Overlap, 1)
+	var chunks []string
+	for start := 0; ; start += step {
+		end := min(start+c.Size, len(words))
+		chunks = append(chunks, strings.Join(words[start:end], " "))
+		if end == len(words) {
+			return chunks
+		}
+	}
+}
+
+// Retriever ingests documents into the index and finds passages relevant to a question.
+type Retriever struct {
+	index   *Index
+	backend backend.Backend
+	model   string
+	chunker Chunker
+}
+
+func NewRetriever(index *Index, b backend.Backend, embedModel string, chunker Chunker) *Retriever {
+	return &Retriever{index: index, backend: b, model: embedModel, chunker: chunker}
+}
+
+func (r *Retriever) Index() *Index {
+	return r.index
+}
+
+// Ingest chunks and embeds the document and stores it in the index.
+// A document without ID gets a random one.
+func (r *Retriever) Ingest(ctx context.Context, doc Document, text string) (Document, error) {
+	texts := r.chunker.Split(text)
+	if len(texts) == 0 {
+		return Document{}, ErrEmptyDocument
+	}
+
+	resp, err := r.backend.Embed(ctx, backend.EmbedRequest{Model: r.model, Input: texts})
+	if err != nil {
+		return Document{}, fmt.Errorf("%w: %w", ErrEmbedding, err)
+	}
+	if len(resp.Embeddings) != len(texts) {
+		return Document{}, fmt.Errorf("%w: expected %d embeddings, got %d", ErrEmbedding, len(texts), len(resp.Embeddings))
+	}
+
+	if doc.ID == "" {
+		doc.ID = rand.Text()
+	}
+	doc.CreatedAt = time.Now()
+	doc.Chunks = make([]Chunk, len(texts))
+	for i, t := range texts {
+		doc.Chunks[i] = Chunk{Text: t, Vector: resp.Embeddings[i]}
+	}
+
+	err = r.index.Put(r.model, doc)
+	if err != nil {
+		return Document{}, err
+	}
+	return doc, nil
+}
+
+// Retrieve returns k passages most relevant to query.
+func (r *Retriever) Retrieve(ctx context.Context, query string, k int) ([]Result, error) {
+	resp, err := r.backend.Embed(ctx, backend.EmbedRequest{Model: r.model, Input: []string{query}})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Embeddings) != 1 {
+		return nil, fmt.Errorf("expected 1 embedding, got %d", len(resp.Embeddings))
+	}
+
+	return r.index.Search(resp.Embeddings[0], k), nil
+}
+
+// Messages builds the chat prompt with retrieved passages numbered for citation.
+// The passages are internal, so that only the question goes through the input
+// guardrails.
+func Messages(question string, results []Result) []backend.Message {
+	var context strings.Builder
+	for i, res := range results {
+		fmt.Fprintf(&context, "[%d] %s\n%s\n\n", i+1, res.Title, res.Text)
+	}
+
+	return []backend.Message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: "Context:\n" + context.String(), Internal: true},
+		{Role: "user", Content: question},
+	}
+}
@@ -0,0 +1,103 @@
+package rag
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+)
+
+func TestChunkerSplit(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "Empty", text: "  ", want: nil},
+		{name: "Shorter than chunk", text: "a b", want: []string{"a b"}},
+		{name: "Overlapping chunks", text: "a b c d e f g", want: []string{"a b c", "c d e", "e f g"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Chunker{Size: 3, Overlap: 1}.Split(tt.text)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("Split() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChunkerValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		chunker     Chunker
+		expectedErr bool
+	}{
+		{name: "Valid", chunker: Chunker{Size: 200, Overlap: 40}},
+		{name: "No overlap", chunker: Chunker{Size: 1}},
+		{name: "Zero size", chunker: Chunker{}, expectedErr: true},
+		{name: "Negative size", chunker: Chunker{Size: -5}, expectedErr: true},
+		{name: "Negative overlap", chunker: Chunker{Size: 10, Overlap: -1}, expectedErr: true},
+		{name: "Overlap of whole chunk", chunker: Chunker{Size: 10, Overlap: 10}, expectedErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.chunker.Validate(); (err != nil) != tt.expectedErr {
+				t.Errorf("unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestRetrieverPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.json")
+	index, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	r := NewRetriever(index, backend.NewFake(), "fake-embed", Chunker{Size: 50, Overlap: 10})
+
+	docs := map[string]string{
+		"cats":  "Cats are small domestic animals that purr and like to sleep in the sun.",
+		"go":    "Go is a programming language with goroutines, channels and a garbage collector.",
+		"ocean": "The ocean covers most of the planet and is home to whales and fish.",
+	}
+	for id, text := range docs {
+		if _, err = r.Ingest(ctx, Document{ID: id, Title: id}, text); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reopened.Documents()) != len(docs) {
+		t.Fatalf("expected %d documents after reopening, got %d", len(docs), len(reopened.Documents()))
+	}
+
+	r = NewRetriever(reopened, backend.NewFake(), "fake-embed", Chunker{Size: 50, Overlap: 10})
+	results, err := r.Retrieve(ctx, "which programming language has goroutines?", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].DocumentID != "go" {
+		t.Errorf("expected go document to be the best match, got %+v", results)
+	}
+
+	if err = reopened.Delete("go"); err != nil {
+		t.Fatal(err)
+	}
+	reopened, _ = Open(path)
+	if len(reopened.Documents()) != len(docs)-1 {
+		t.Errorf("expected deletion to be persisted")
+	}
+
+	if err = reopened.Put("other-model", Document{ID: "x"}); err == nil {
+		t.Error("expected error when mixing embedding models")
+	}
+}
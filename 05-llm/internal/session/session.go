@@ -0,0 +1,151 @@
+package session
+
+import (
+	"crypto/rand"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+)
+
+var ErrNotFound = errors.New("session not found")
+
+type Session struct {
+	ID        string            `json:"id"`
+	Model     string            `json:"model"`
+	System    string            `json:"system,omitempty"`
+	Messages  []backend.Message `json:"messages"`
+	Summary   string            `json:"summary,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+
+	// Summarized is the number of leading messages folded into Summary.
+	Summarized int `json:"summarized,omitempty"`
+}
+
+type entry struct {
+	mu      sync.Mutex
+	session Session
+	// used is the time of the last access, guarded by the store's mutex.
+	used time.Time
+}
+
+func (e *entry) snapshot() Session {
+	s := e.session
+	s.Messages = append([]backend.Message{}, s.Messages...)
+	return s
+}
+
+// Store keeps conversation history of chat sessions in memory. Sessions
+// not accessed for the TTL are removed; zero TTL keeps them until deleted.
+type Store struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	sessions map[string]*entry
+	// swept is when expired sessions were last removed.
+	swept time.Time
+	now   func() time.Time
+}
+
+func NewStore(ttl time.Duration) *Store {
+	return &Store{ttl: ttl, sessions: make(map[string]*entry), now: time.Now}
+}
+
+func (s *Store) Create(model, system string) Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	e := &entry{used: now, session: Session{
+		ID:        rand.Text(),
+		Model:     model,
+		System:    system,
+		Messages:  []backend.Message{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}}
+	s.sessions[e.session.ID] = e
+	// Abandoned sessions are never looked up again, so they are swept
+	// here, at most once per TTL to keep creation cheap.
+	if s.ttl > 0 && now.Sub(s.swept) >= s.ttl {
+		s.swept = now
+		for id, e := range s.sessions {
+			if s.expired(e, now) {
+				delete(s.sessions, id)
+			}
+		}
+	}
+
+	return e.snapshot()
+}
+
+func (s *Store) Get(id string) (Session, error) {
+	e, err := s.find(id)
+	if err != nil {
+		return Session{}, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.snapshot(), nil
+}
+
+// Update runs fn with exclusive access to the session, so turns of the same
+// conversation are never interleaved.
+func (s *Store) Update(id string, fn func(*Session) error) error {
+	e, err := s.find(id)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	err = fn(&e.session)
+	e.session.UpdatedAt = s.touch(e)
+	return err
+}
+
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.sessions[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.sessions, id)
+	return nil
+}
+
+// find returns the session and marks it as used, unless it has expired.
+func (s *Store) find(id string) (*entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.sessions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	now := s.now()
+	if s.expired(e, now) {
+		delete(s.sessions, id)
+		return nil, ErrNotFound
+	}
+	e.used = now
+	return e, nil
+}
+
+// touch marks the session as used once a turn, which may take long, is over.
+func (s *Store) touch(e *entry) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e.used = s.now()
+	return e.used
+}
+
+func (s *Store) expired(e *entry, now time.Time) bool {
+	return s.ttl > 0 && now.Sub(e.used) >= s.ttl
+}
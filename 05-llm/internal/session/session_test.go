@@ -0,0 +1,66 @@
+package session
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStoreExpiry(t *testing.T) {
+	tests := []struct {
+		name          string
+		ttl           time.Duration
+		accessAfter   time.Duration
+		idle          time.Duration
+		expectedFound bool
+	}{
+		{name: "Within TTL", ttl: time.Hour, idle: 59 * time.Minute, expectedFound: true},
+		{name: "Idle for TTL", ttl: time.Hour, idle: time.Hour},
+		{name: "Access extends TTL", ttl: time.Hour, accessAfter: 40 * time.Minute, idle: 40 * time.Minute, expectedFound: true},
+		{name: "No TTL", idle: 1000 * time.Hour, expectedFound: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			s := NewStore(tt.ttl)
+			s.now = func() time.Time { return now }
+
+			sess := s.Create("llama3.2", "")
+			if tt.accessAfter > 0 {
+				now = now.Add(tt.accessAfter)
+				if err := s.Update(sess.ID, func(*Session) error { return nil }); err != nil {
+					t.Fatal(err)
+				}
+			}
+			now = now.Add(tt.idle)
+
+			_, err := s.Get(sess.ID)
+			if tt.expectedFound && err != nil {
+				t.Fatalf("expected session to be kept, got %v", err)
+			}
+			if !tt.expectedFound && !errors.Is(err, ErrNotFound) {
+				t.Fatalf("expected ErrNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestStoreSweep(t *testing.T) {
+	now := time.Now()
+	s := NewStore(time.Hour)
+	s.now = func() time.Time { return now }
+
+	abandoned := s.Create("llama3.2", "")
+	now = now.Add(30 * time.Minute)
+	active := s.Create("llama3.2", "")
+
+	// Sessions nobody asks for again are removed when new ones are created.
+	now = now.Add(45 * time.Minute)
+	s.Create("llama3.2", "")
+	if _, ok := s.sessions[abandoned.ID]; ok {
+		t.Error("expected the abandoned session to be swept")
+	}
+	if _, ok := s.sessions[active.ID]; !ok {
+		t.Error("expected the session idle for less than the TTL to be kept")
+	}
+}
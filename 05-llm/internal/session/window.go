@@ -0,0 +1,232 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+)
+
+// messageOverhead roughly accounts for role markers and separators
+// the model adds around every message.
+const messageOverhead = 4
+
+var ErrContextExceeded = errors.New("prompt exceeds the model context")
+
+// The model context holds the reply as well, 1/replyShare of it is left for one.
+const replyShare = 4
+
+// Strategy is the way history exceeding the context is fitted into it.
+type Strategy string
+
+const (
+	// TruncateOldest drops the oldest turns.
+	TruncateOldest Strategy = "truncate_oldest"
+	// TruncateMiddle keeps the first message, which usually sets the task,
+	// and drops the turns following it.
+	TruncateMiddle Strategy = "truncate_middle"
+	// Summarize folds old turns into a summary, trimming the rest if the
+	// summary does not make it fit.
+	Summarize Strategy = "summarize"
+)
+
+// Strategies lists the valid strategies.
+var Strategies = []Strategy{TruncateOldest, TruncateMiddle, Summarize}
+
+// SummarizeFunc folds messages into an existing summary of the conversation.
+type SummarizeFunc func(ctx context.Context, model, summary string, messages []backend.Message) (string, error)
+
+// Window fits conversation history into the context of the model, measured
+// in tokens.
+type Window struct {
+	// Budget applies to models of unknown context size; zero sends them
+	// the whole history.
+	Budget int
+	// ContextSize returns the context length of the model or zero if it is unknown.
+	ContextSize func(ctx context.Context, model string) int
+	// Strategy defaults to Summarize if Summarize is set and to
+	// TruncateOldest otherwise.
+	Strategy  Strategy
+	Summarize SummarizeFunc
+	// SummaryModel writes the summaries instead of the session model,
+	// usually a cheaper one.
+	SummaryModel string
+}
+
+// Fit reports how the history was fitted into the context.
+type Fit struct {
+	Strategy Strategy `json:"strategy"`
+	// Budget and Tokens are estimates.
+	Budget int `json:"budget"`
+	Tokens int `json:"tokens"`
+	// Dropped messages were not sent to the model.
+	Dropped int `json:"dropped,omitempty"`
+	// Summarized messages were folded into the summary by this request.
+	Summarized int `json:"summarized,omitempty"`
+}
+
+// EstimateTokens approximates the number of tokens in s,
+// assuming about four characters per token.
+func EstimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+func countTokens(messages []backend.Message) int {
+	total := 0
+	for _, m := range messages {
+		total += EstimateTokens(m.Content) + messageOverhead
+	}
+	return total
+}
+
+// Messages returns the message list to send to the model for sess and
+// reports the fit if the history exceeded the context. It may fold old turns
+// into sess.Summary, so it must be called with exclusive access to the session.
+func (w Window) Messages(ctx context.Context, sess *Session) ([]backend.Message, *Fit, error) {
+	budget := w.budget(ctx, sess.Model)
+	history := sess.Messages[sess.Summarized:]
+	if budget <= 0 || countTokens(prefix(sess))+countTokens(history) <= budget {
+		return append(prefix(sess), history...), nil, nil
+	}
+
+	fit := &Fit{Strategy: w.strategy(), Budget: budget}
+	if fit.Strategy == Summarize && len(history) > 1 {
+		// Leave a quarter of the budget for the summary itself.
+		available := budget - countTokens(prefix(sess)) - budget/4
+		k := cut(history, available)
+		if k > 0 {
+			model := w.SummaryModel
+			if model == "" {
+				model = sess.Model
+			}
+			summary, err := w.Summarize(ctx, model, sess.Summary, history[:k])
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to summarize conversation: %w", err)
+			}
+			sess.Summary = summary
+			sess.Summarized += k
+			fit.Summarized = k
+			history = history[k:]
+		}
+	}
+
+	messages := prefix(sess)
+	available := budget - countTokens(messages)
+	var kept []backend.Message
+	if fit.Strategy == TruncateMiddle {
+		kept = truncateMiddle(history, available)
+	}
+	if kept == nil {
+		kept = history[cut(history, available):]
+	}
+	fit.Dropped = len(history) - len(kept)
+
+	messages = append(messages, kept...)
+	fit.Tokens = countTokens(messages)
+	return messages, fit, nil
+}
+
+// Check returns ErrContextExceeded if messages, none of which may be dropped,
+// exceed the context of the model. Models of unknown context size pass.
+func (w Window) Check(ctx context.Context, model string, messages []backend.Message) error {
+	if w.ContextSize == nil {
+		return nil
+	}
+	size := w.ContextSize(ctx, model)
+	budget := size - size/replyShare
+	if tokens := countTokens(messages); size > 0 && tokens > budget {
+		return fmt.Errorf("%w: about %d tokens, %s fits %d", ErrContextExceeded, tokens, model, budget)
+	}
+	return nil
+}
+
+func (w Window) budget(ctx context.Context, model string) int {
+	if w.ContextSize != nil {
+		if size := w.ContextSize(ctx, model); size > 0 {
+			return size - size/replyShare
+		}
+	}
+	return w.Budget
+}
+
+func (w Window) strategy() Strategy {
+	switch {
+	case w.Strategy == Summarize && w.Summarize == nil:
+		return TruncateOldest
+	case w.Strategy != "":
+		return w.Strategy
+	case w.Summarize != nil:
+		return Summarize
+	}
+	return TruncateOldest
+}
+
+// truncateMiddle keeps the first message and the newest ones fitting into
+// budget. It returns nil if the first and the last message do not fit together.
+func truncateMiddle(history []backend.Message, budget int) []backend.Message {
+	if len(history) < 3 {
+		return nil
+	}
+	first, rest := history[:1], history[1:]
+	available := budget - countTokens(first)
+	if countTokens(rest[len(rest)-1:]) > available {
+		return nil
+	}
+	return append(slices.Clip(first), rest[cut(rest, available):]...)
+}
+
+// cut returns how many leading messages must be dropped for the rest
+// to fit into budget. The last message is always kept.
+func cut(history []backend.Message, budget int) int {
+	k := 0
+	for k < len(history)-1 && countTokens(history[k:]) > budget {
+		k++
+	}
+	return k
+}
+
+func prefix(sess *Session) []backend.Message {
+	var messages []backend.Message
+	if sess.System != "" {
+		messages = append(messages, backend.Message{Role: "system", Content: sess.System})
+	}
+	if sess.Summary != "" {
+		messages = append(messages, backend.Message{
+			Role:    "system",
+			Content: "Summary of the earlier conversation: " + sess.Summary,
+		})
+	}
+	return messages
+}
+
+// BackendSummarizer asks the model itself to summarize old turns.
+func BackendSummarizer(b backend.Backend) SummarizeFunc {
+	return func(ctx context.Context, model, summary string, messages []backend.Message) (string, error) {
+		var transcript strings.Builder
+		if summary != "" {
+			fmt.Fprintf(&transcript, "Earlier summary: %s\n\n", summary)
+		}
+		for _, m := range messages {
+			fmt.Fprintf(&transcript, "%s: %s\n", m.Role, m.Content)
+		}
+
+		resp, err := b.Chat(ctx, backend.ChatRequest{
+			Model: model,
+			Messages: []backend.Message{
+				{
+					Role:    "system",
+					Content: "Summarize the conversation below in a few sentences. Keep names, facts and decisions, omit pleasantries.",
+				},
+				{Role: "user", Content: transcript.String(), Internal: true},
+			},
+		})
+		if err != nil {
+			return "", err
+		}
+
+		return strings.TrimSpace(resp.Content), nil
+	}
+}
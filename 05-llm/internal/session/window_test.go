@@ -0,0 +1,183 @@
+package session
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+)
+
+func newTestSession(turns int) *Session {
+	sess := &Session{Model: "llama3.2", System: "Be brief."}
+	for i := 0; i < turns; i++ {
+		sess.Messages = append(sess.Messages,
+			backend.Message{Role: "user", Content: strings.Repeat("q", 40)},
+			backend.Message{Role: "assistant", Content: strings.Repeat("a", 40)},
+		)
+	}
+	return sess
+}
+
+func TestWindowTrim(t *testing.T) {
+	sess := newTestSession(5)
+	w := Window{Budget: 50}
+
+	messages, fit, err := w.Messages(context.Background(), sess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fit == nil || fit.Strategy != TruncateOldest || fit.Dropped != 10-len(messages)+1 || fit.Tokens != countTokens(messages) {
+		t.Errorf("unexpected fit %+v", fit)
+	}
+
+	if messages[0].Role != "system" {
+		t.Errorf("expected system prompt to be kept, got %+v", messages[0])
+	}
+	if got := countTokens(messages); got > w.Budget {
+		t.Errorf("messages exceed budget: got %d tokens, budget %d", got, w.Budget)
+	}
+	if last := messages[len(messages)-1]; !reflect.DeepEqual(last, sess.Messages[len(sess.Messages)-1]) {
+		t.Errorf("expected last message to be kept, got %+v", last)
+	}
+	if len(sess.Messages) != 10 {
+		t.Errorf("trimming must not change stored history, got %d messages", len(sess.Messages))
+	}
+}
+
+func TestWindowSummarize(t *testing.T) {
+	sess := newTestSession(5)
+	var summarized []backend.Message
+	var summaryModel string
+	w := Window{
+		Budget: 80,
+		Summarize: func(ctx context.Context, model, summary string, messages []backend.Message) (string, error) {
+			summarized = messages
+			summaryModel = model
+			return "they talked about q and a", nil
+		},
+		SummaryModel: "llama3.2:1b",
+	}
+
+	messages, fit, err := w.Messages(context.Background(), sess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fit == nil || fit.Strategy != Summarize || fit.Summarized != len(summarized) {
+		t.Errorf("unexpected fit %+v", fit)
+	}
+	if summaryModel != "llama3.2:1b" {
+		t.Errorf("expected the summary model, got %q", summaryModel)
+	}
+
+	if sess.Summary != "they talked about q and a" {
+		t.Errorf("unexpected summary %q", sess.Summary)
+	}
+	if sess.Summarized != len(summarized) || sess.Summarized == 0 {
+		t.Errorf("unexpected summarized count: got %d, summarized %d messages", sess.Summarized, len(summarized))
+	}
+	if !strings.Contains(messages[1].Content, sess.Summary) {
+		t.Errorf("expected summary to be sent, got %+v", messages[1])
+	}
+	if got := countTokens(messages); got > w.Budget {
+		t.Errorf("messages exceed budget: got %d tokens, budget %d", got, w.Budget)
+	}
+}
+
+func TestWindowStrategies(t *testing.T) {
+	contextSize := func(ctx context.Context, model string) int {
+		if model == "big" {
+			return 1000
+		}
+		return 0
+	}
+
+	tests := []struct {
+		name             string
+		window           Window
+		model            string
+		expectedFit      *Fit
+		expectedMessages int
+		keepsFirst       bool
+	}{
+		{
+			name:             "Fits",
+			window:           Window{Budget: 500},
+			expectedMessages: 11,
+		},
+		{
+			name:             "No budget",
+			window:           Window{},
+			expectedMessages: 11,
+		},
+		{
+			name:             "Truncate oldest",
+			window:           Window{Budget: 100, Strategy: TruncateOldest},
+			expectedFit:      &Fit{Strategy: TruncateOldest, Budget: 100, Tokens: 91, Dropped: 4},
+			expectedMessages: 7,
+		},
+		{
+			name:             "Truncate middle",
+			window:           Window{Budget: 100, Strategy: TruncateMiddle},
+			expectedFit:      &Fit{Strategy: TruncateMiddle, Budget: 100, Tokens: 93, Dropped: 4},
+			expectedMessages: 7,
+			keepsFirst:       true,
+		},
+		{
+			name:             "Truncate middle with the first message too long",
+			window:           Window{Budget: 30, Strategy: TruncateMiddle},
+			expectedFit:      &Fit{Strategy: TruncateMiddle, Budget: 30, Tokens: 21, Dropped: 9},
+			expectedMessages: 2,
+		},
+		{
+			name:             "Summarize without summarizer",
+			window:           Window{Budget: 100, Strategy: Summarize},
+			expectedFit:      &Fit{Strategy: TruncateOldest, Budget: 100, Tokens: 91, Dropped: 4},
+			expectedMessages: 7,
+		},
+		{
+			name:             "Model context size",
+			window:           Window{Budget: 100, ContextSize: contextSize},
+			model:            "big",
+			expectedMessages: 11,
+		},
+		{
+			name:             "Unknown model context size",
+			window:           Window{Budget: 100, ContextSize: contextSize},
+			model:            "small",
+			expectedFit:      &Fit{Strategy: TruncateOldest, Budget: 100, Tokens: 91, Dropped: 4},
+			expectedMessages: 7,
+		},
+		{
+			name:             "Reply space left in the model context",
+			window:           Window{Budget: 1000, ContextSize: func(context.Context, string) int { return 133 }},
+			expectedFit:      &Fit{Strategy: TruncateOldest, Budget: 100, Tokens: 91, Dropped: 4},
+			expectedMessages: 7,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sess := newTestSession(5)
+			sess.Model = tt.model
+			sess.Messages[0].Content = "first " + sess.Messages[0].Content
+
+			messages, fit, err := tt.window.Messages(context.Background(), sess)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(fit, tt.expectedFit) {
+				t.Errorf("expected fit %+v, got %+v", tt.expectedFit, fit)
+			}
+			if len(messages) != tt.expectedMessages {
+				t.Fatalf("expected %d messages, got %d", tt.expectedMessages, len(messages))
+			}
+			if kept := messages[1].Content == sess.Messages[0].Content; kept != tt.keepsFirst && len(messages) < 11 {
+				t.Errorf("first message kept = %v, want %v", kept, tt.keepsFirst)
+			}
+			if last := messages[len(messages)-1]; !reflect.DeepEqual(last, sess.Messages[len(sess.Messages)-1]) {
+				t.Errorf("expected last message to be kept, got %+v", last)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+// Package structured makes the model answer with JSON matching a JSON schema.
+// Invalid answers are sent back to the model together with the validation
+// errors, so it can repair them.
+package structured
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+)
+
+const schemaURL = "request-schema.json"
+
+// Violation describes one place where the output does not match the schema.
+type Violation struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned when the model failed to produce valid output
+// within the allowed number of attempts.
+type ValidationError struct {
+	Attempts   int         `json:"attempts"`
+	Violations []Violation `json:"violations"`
+	Output     string      `json:"output"`
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("output does not match the schema after %d attempts", e.Attempts)
+}
+
+// Schema is a compiled JSON schema.
+type Schema struct {
+	raw    json.RawMessage
+	schema *jsonschema.Schema
+}
+
+// Compile parses and compiles the schema. Remote and file references are not resolved.
+func Compile(raw json.RawMessage) (*Schema, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+
+	c := jsonschema.NewCompiler()
+	c.UseLoader(jsonschema.SchemeURLLoader{})
+	err = c.AddResource(schemaURL, doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	compiled, err := c.Compile(schemaURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+
+	return &Schema{raw: raw, schema: compiled}, nil
+}
+
+// Validate parses output as JSON and checks it against the schema.
+// Markdown code fences around the JSON are tolerated.
+func (s *Schema) Validate(output string) (json.RawMessage, []Violation) {
+	output = trimFence(output)
+
+	inst, err := jsonschema.UnmarshalJSON(strings.NewReader(output))
+	if err != nil {
+		return nil, []Violation{{Path: "", Message: fmt.Sprintf("invalid JSON: %v", err)}}
+	}
+
+	err = s.schema.Validate(inst)
+	var ve *jsonschema.ValidationError
+	if errors.As(err, &ve) {
+		return nil, violations(ve)
+	}
+	if err != nil {
+		return nil, []Violation{{Path: "", Message: err.Error()}}
+	}
+
+	return json.RawMessage(output), nil
+}
+
+func violations(ve *jsonschema.ValidationError) []Violation {
+	out := ve.BasicOutput()
+
+	var result []Violation
+	for _, unit := range out.Errors {
+		if unit.Error == nil {
+			continue
+		}
+		result = append(result, Violation{Path: unit.InstanceLocation, Message: unit.Error.String()})
+	}
+	if len(result) == 0 && out.Error != nil {
+		result = append(result, Violation{Path: out.InstanceLocation, Message: out.Error.String()})
+	}
+	return result
+}
+
+func trimFence(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "```") {
+		return s
+	}
+
+	s = strings.TrimPrefix(s, "```")
+	s = strings.TrimPrefix(s, "json")
+	s = strings.TrimSuffix(s, "```")
+	return strings.TrimSpace(s)
+}
+
+// Result is the validated output of the model.
+type Result struct {
+	Data     json.RawMessage
+	Model    string
+	Attempts int
+	Usage    backend.Usage
+}
+
+// Generator asks the backend for structured output and repairs invalid answers.
+type Generator struct {
+	backend    backend.Backend
+	maxRetries int
+}
+
+// NewGenerator creates a generator that re-prompts the model at most maxRetries times.
+func NewGenerator(b backend.Backend, maxRetries int) *Generator {
+	return &Generator{backend: b, maxRetries: maxRetries}
+}
+
+func (g *Generator) MaxRetries() int {
+	return g.maxRetries
+}
+
+// Generate sends req constrained by schema. retries limits the repair attempts
+// and is capped by the generator's maximum; a negative value means the maximum.
+func (g *Generator) Generate(ctx context.Context, req backend.ChatRequest, schema *Schema, retries int) (Result, error) {
+	if retries < 0 || retries > g.maxRetries {
+		retries = g.maxRetries
+	}
+
+	req.Format = schema.raw
+	req.Messages = append([]backend.Message{{
+		Role:    "system",
+		Content: "Respond only with JSON matching this JSON schema:\n" + string(schema.raw),
+	}}, req.Messages...)
+
+	var result Result
+	for attempt := 1; ; attempt++ {
+		resp, err := g.backend.Chat(ctx, req)
+		if err != nil {
+			return Result{}, err
+		}
+
+		result.Model = resp.Model
+		result.Attempts = attempt
+		result.Usage.PromptTokens += resp.Usage.PromptTokens
+		result.Usage.CompletionTokens += resp.Usage.CompletionTokens
+
+		data, problems := schema.Validate(resp.Content)
+		if problems == nil {
+			result.Data = data
+			return result, nil
+		}
+		if attempt > retries {
+			return result, &ValidationError{Attempts: attempt, Violations: problems, Output: resp.Content}
+		}
+
+		req.Messages = append(req.Messages,
+			backend.Message{Role: "assistant", Content: resp.Content},
+			backend.Message{Role: "user", Content: repairPrompt(problems), Internal: true},
+		)
+	}
+}
+
+func repairPrompt(problems []Violation) string {
+	var sb strings.Builder
+	sb.WriteString("Your answer does not match the JSON schema:\n")
+	for _, p := range problems {
+		path := p.Path
+		if path == "" {
+			path = "/"
+		}
+		fmt.Fprintf(&sb, "- %s: %s\n", path, p.Message)
+	}
+	sb.WriteString("Reply again with only the corrected JSON.")
+	return sb.String()
+}
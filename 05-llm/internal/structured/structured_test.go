@@ -0,0 +1,111 @@
+package structured
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+)
+
+const personSchema = `{
+	"type": "object",
+	"properties": {
+		"name": {"type": "string"},
+		"age": {"type": "integer", "minimum": 0}
+	},
+	"required": ["name", "age"]
+}`
+
+// scripted answers with the next reply on every call and records the requests.
+type scripted struct {
+	*backend.Fake
+	replies  []string
+	requests []backend.ChatRequest
+}
+
+func (s *scripted) Chat(ctx context.Context, req backend.ChatRequest) (backend.Response, error) {
+	s.requests = append(s.requests, req)
+	reply := s.replies[0]
+	s.replies = s.replies[1:]
+	return backend.Response{Model: req.Model, Content: reply, Usage: backend.Usage{PromptTokens: 10, CompletionTokens: 5}}, nil
+}
+
+func TestSchemaValidate(t *testing.T) {
+	schema, err := Compile([]byte(personSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		output   string
+		valid    bool
+		wantPath string
+	}{
+		{name: "Valid", output: `{"name":"Ann","age":30}`, valid: true},
+		{name: "Code fence", output: "```json\n{\"name\":\"Ann\",\"age\":30}\n```", valid: true},
+		{name: "Not JSON", output: `Ann is 30`, wantPath: ""},
+		{name: "Wrong type", output: `{"name":"Ann","age":"thirty"}`, wantPath: "/age"},
+		{name: "Missing property", output: `{"name":"Ann"}`, wantPath: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, problems := schema.Validate(tt.output)
+			if tt.valid {
+				if problems != nil || data == nil {
+					t.Fatalf("expected valid output, got %+v", problems)
+				}
+				return
+			}
+			if len(problems) == 0 {
+				t.Fatal("expected violations")
+			}
+			if problems[0].Path != tt.wantPath {
+				t.Errorf("violation path = %q, want %q", problems[0].Path, tt.wantPath)
+			}
+		})
+	}
+
+	if _, err = Compile([]byte(`{"type": 5}`)); err == nil {
+		t.Error("expected error for invalid schema")
+	}
+}
+
+func TestGeneratorRepair(t *testing.T) {
+	schema, err := Compile([]byte(personSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := backend.ChatRequest{Model: "llama3.2", Messages: []backend.Message{{Role: "user", Content: "Ann, 30"}}}
+
+	b := &scripted{replies: []string{`{"name":"Ann"}`, `{"name":"Ann","age":30}`}}
+	result, err := NewGenerator(b, 2).Generate(context.Background(), req, schema, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Attempts != 2 || string(result.Data) != `{"name":"Ann","age":30}` {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if result.Usage.PromptTokens != 20 || result.Usage.CompletionTokens != 10 {
+		t.Errorf("usage of all attempts should be summed, got %+v", result.Usage)
+	}
+	if string(b.requests[0].Format) != personSchema {
+		t.Errorf("schema should be passed as format, got %s", b.requests[0].Format)
+	}
+	repair := b.requests[1].Messages
+	if last := repair[len(repair)-1]; !strings.Contains(last.Content, "age") || !last.Internal {
+		t.Errorf("repair prompt should be an internal message listing violations, got %+v", last)
+	}
+
+	b = &scripted{replies: []string{`{}`, `{}`, `{}`}}
+	_, err = NewGenerator(b, 2).Generate(context.Background(), req, schema, 1)
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	if invalid.Attempts != 2 || len(b.requests) != 2 {
+		t.Errorf("expected 2 attempts, got %d (%d requests)", invalid.Attempts, len(b.requests))
+	}
+}
@@ -0,0 +1,217 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/auth"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/guard"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/queue"
+)
+
+const (
+	instrumentationName = "github.com/charlie-wasp/go-masters-2025/llm"
+
+	// anonymousClient labels requests made without an API key.
+	anonymousClient = "anonymous"
+)
+
+// Backend records metrics and spans of every call to the wrapped backend.
+type Backend struct {
+	backend.Backend
+	tracer trace.Tracer
+
+	requests         metric.Int64Counter
+	errors           metric.Int64Counter
+	timeToFirstToken metric.Float64Histogram
+	duration         metric.Float64Histogram
+	tokensPerSecond  metric.Float64Histogram
+	promptTokens     metric.Int64Counter
+	completionTokens metric.Int64Counter
+}
+
+func NewBackend(b backend.Backend, mp metric.MeterProvider, tp trace.TracerProvider) (*Backend, error) {
+	meter := mp.Meter(instrumentationName)
+	t := &Backend{Backend: b, tracer: tp.Tracer(instrumentationName)}
+
+	var err error
+	t.requests, err = meter.Int64Counter("llm.requests",
+		metric.WithDescription("Number of calls to the llm server"),
+		metric.WithUnit("{request}"))
+	if err != nil {
+		return nil, err
+	}
+	t.errors, err = meter.Int64Counter("llm.errors",
+		metric.WithDescription("Number of failed calls to the llm server by error type"),
+		metric.WithUnit("{request}"))
+	if err != nil {
+		return nil, err
+	}
+	t.timeToFirstToken, err = meter.Float64Histogram("llm.time_to_first_token",
+		metric.WithDescription("Time until the first streamed token"),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	t.duration, err = meter.Float64Histogram("llm.generation.duration",
+		metric.WithDescription("Total time of a call to the llm server"),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	t.tokensPerSecond, err = meter.Float64Histogram("llm.tokens_per_second",
+		metric.WithDescription("Completion tokens generated per second"),
+		metric.WithUnit("{token}/s"))
+	if err != nil {
+		return nil, err
+	}
+	t.promptTokens, err = meter.Int64Counter("llm.tokens.prompt",
+		metric.WithDescription("Number of prompt tokens"),
+		metric.WithUnit("{token}"))
+	if err != nil {
+		return nil, err
+	}
+	t.completionTokens, err = meter.Int64Counter("llm.tokens.completion",
+		metric.WithDescription("Number of completion tokens"),
+		metric.WithUnit("{token}"))
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (b *Backend) Generate(ctx context.Context, req backend.GenerateRequest) (backend.Response, error) {
+	ctx, call := b.start(ctx, "generate", req.Model)
+	resp, err := b.Backend.Generate(ctx, req)
+	call.end(resp, err)
+	return resp, err
+}
+
+func (b *Backend) Chat(ctx context.Context, req backend.ChatRequest) (backend.Response, error) {
+	ctx, call := b.start(ctx, "chat", req.Model)
+	resp, err := b.Backend.Chat(ctx, req)
+	call.end(resp, err)
+	return resp, err
+}
+
+func (b *Backend) Stream(ctx context.Context, req backend.ChatRequest, fn func(backend.Chunk) error) (backend.Response, error) {
+	ctx, call := b.start(ctx, "stream", req.Model)
+	first := true
+	resp, err := b.Backend.Stream(ctx, req, func(chunk backend.Chunk) error {
+		if first {
+			first = false
+			ttft := time.Since(call.start)
+			b.timeToFirstToken.Record(ctx, ttft.Seconds(), call.attrs)
+			call.span.AddEvent("first token")
+		}
+		return fn(chunk)
+	})
+	call.end(resp, err)
+	return resp, err
+}
+
+func (b *Backend) Embed(ctx context.Context, req backend.EmbedRequest) (backend.EmbedResponse, error) {
+	ctx, call := b.start(ctx, "embed", req.Model)
+	resp, err := b.Backend.Embed(ctx, req)
+	call.end(backend.Response{}, err)
+	return resp, err
+}
+
+// call is an instrumented call in progress.
+type call struct {
+	b     *Backend
+	ctx   context.Context
+	span  trace.Span
+	start time.Time
+	attrs metric.MeasurementOption
+}
+
+func (b *Backend) start(ctx context.Context, op, model string) (context.Context, *call) {
+	client := auth.ClientFromContext(ctx)
+	if client == "" {
+		client = anonymousClient
+	}
+	attrs := []attribute.KeyValue{
+		attribute.String("llm.operation", op),
+		attribute.String("llm.model", model),
+		attribute.String("llm.client", client),
+	}
+
+	ctx, span := b.tracer.Start(ctx, "llm."+op, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	c := &call{b: b, ctx: ctx, span: span, start: time.Now(), attrs: metric.WithAttributes(attrs...)}
+	b.requests.Add(ctx, 1, c.attrs)
+	return ctx, c
+}
+
+func (c *call) end(resp backend.Response, err error) {
+	defer c.span.End()
+
+	elapsed := time.Since(c.start)
+	c.b.duration.Record(c.ctx, elapsed.Seconds(), c.attrs)
+
+	if err != nil {
+		kind := errorType(err)
+		c.b.errors.Add(c.ctx, 1, c.attrs, metric.WithAttributes(attribute.String("error.type", kind)))
+		c.span.RecordError(err)
+		c.span.SetStatus(codes.Error, kind)
+		return
+	}
+
+	usage := resp.Usage
+	c.b.promptTokens.Add(c.ctx, int64(usage.PromptTokens), c.attrs)
+	c.b.completionTokens.Add(c.ctx, int64(usage.CompletionTokens), c.attrs)
+
+	// Ollama reports the time spent generating tokens, which excludes model loading and prompt evaluation.
+	generation := resp.Timings.Eval
+	if generation <= 0 {
+		generation = elapsed
+	}
+	if usage.CompletionTokens > 0 && generation > 0 {
+		c.b.tokensPerSecond.Record(c.ctx, float64(usage.CompletionTokens)/generation.Seconds(), c.attrs)
+	}
+
+	c.span.SetAttributes(
+		attribute.Int("llm.usage.prompt_tokens", usage.PromptTokens),
+		attribute.Int("llm.usage.completion_tokens", usage.CompletionTokens),
+	)
+	if t := resp.Timings; t != (backend.Timings{}) {
+		c.span.SetAttributes(
+			attribute.Int64("ollama.total_duration", t.Total.Nanoseconds()),
+			attribute.Int64("ollama.load_duration", t.Load.Nanoseconds()),
+			attribute.Int64("ollama.prompt_eval_duration", t.PromptEval.Nanoseconds()),
+			attribute.Int64("ollama.eval_duration", t.Eval.Nanoseconds()),
+		)
+	}
+}
+
+// errorType classifies errors into a small set of values suitable as a metric label.
+func errorType(err error) string {
+	var overload queue.OverloadError
+	var blocked *guard.BlockedError
+	var status backend.StatusError
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "cancelled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &overload):
+		return "overload"
+	case errors.As(err, &blocked):
+		return "guardrail"
+	case errors.Is(err, backend.ErrNoUpstream), errors.Is(err, backend.ErrModelUnavailable):
+		return "unavailable"
+	case errors.As(err, &status):
+		return "http_" + strconv.Itoa(status.StatusCode)
+	default:
+		return "upstream"
+	}
+}
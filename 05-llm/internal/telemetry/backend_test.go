@@ -0,0 +1,174 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/auth"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/ollamatest"
+)
+
+// collect returns the sum of counters and the count of histogram records by metric name.
+func collect(t *testing.T, reader *sdkmetric.ManualReader) map[string]int64 {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+
+	values := make(map[string]int64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				for _, dp := range data.DataPoints {
+					values[m.Name] += dp.Value
+				}
+			case metricdata.Histogram[float64]:
+				for _, dp := range data.DataPoints {
+					values[m.Name] += int64(dp.Count)
+				}
+			}
+		}
+	}
+	return values
+}
+
+func TestBackend(t *testing.T) {
+	tests := []struct {
+		name            string
+		call            func(context.Context, backend.Backend) error
+		fail            bool
+		expectedMetrics map[string]int64
+		expectedSpan    string
+		expectedAttr    attribute.Key
+	}{
+		{
+			name: "Generate",
+			call: func(ctx context.Context, b backend.Backend) error {
+				_, err := b.Generate(ctx, backend.GenerateRequest{Model: "llama3.2", Prompt: "one two"})
+				return err
+			},
+			expectedMetrics: map[string]int64{
+				"llm.requests":            1,
+				"llm.generation.duration": 1,
+				"llm.tokens.prompt":       2,
+				"llm.tokens.completion":   3,
+				"llm.tokens_per_second":   1,
+			},
+			expectedSpan: "llm.generate",
+			expectedAttr: "ollama.load_duration",
+		},
+		{
+			name: "Stream",
+			call: func(ctx context.Context, b backend.Backend) error {
+				_, err := b.Stream(ctx, backend.ChatRequest{
+					Model:    "llama3.2",
+					Messages: []backend.Message{{Role: "user", Content: "one two"}},
+				}, func(backend.Chunk) error { return nil })
+				return err
+			},
+			expectedMetrics: map[string]int64{
+				"llm.requests":            1,
+				"llm.time_to_first_token": 1,
+				"llm.tokens.completion":   3,
+			},
+			expectedSpan: "llm.stream",
+			expectedAttr: "ollama.eval_duration",
+		},
+		{
+			name: "Upstream error",
+			call: func(ctx context.Context, b backend.Backend) error {
+				_, err := b.Chat(ctx, backend.ChatRequest{Model: "llama3.2"})
+				return err
+			},
+			fail: true,
+			expectedMetrics: map[string]int64{
+				"llm.requests":            1,
+				"llm.errors":              1,
+				"llm.generation.duration": 1,
+			},
+			expectedSpan: "llm.chat",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := ollamatest.NewServer()
+			defer srv.Close()
+			if tt.fail {
+				srv.FailNext(1, http.StatusInternalServerError, "boom")
+			}
+			u, _ := url.Parse(srv.URL)
+
+			reader := sdkmetric.NewManualReader()
+			mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+			spans := tracetest.NewSpanRecorder()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+
+			b, err := NewBackend(backend.NewOllama(u, srv.Client()), mp, tp)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = tt.call(auth.WithClient(context.Background(), "alice"), b)
+			if (err != nil) != tt.fail {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			values := collect(t, reader)
+			for name, expected := range tt.expectedMetrics {
+				if values[name] != expected {
+					t.Errorf("%s = %d, want %d", name, values[name], expected)
+				}
+			}
+
+			ended := spans.Ended()
+			if len(ended) != 1 || ended[0].Name() != tt.expectedSpan {
+				t.Fatalf("expected a single %s span, got %d", tt.expectedSpan, len(ended))
+			}
+			span := ended[0]
+			attrs := map[attribute.Key]attribute.Value{}
+			for _, kv := range span.Attributes() {
+				attrs[kv.Key] = kv.Value
+			}
+			if attrs["llm.client"].AsString() != "alice" || attrs["llm.model"].AsString() != "llama3.2" {
+				t.Errorf("unexpected span attributes %v", span.Attributes())
+			}
+			if tt.expectedAttr != "" && attrs[tt.expectedAttr].AsInt64() <= 0 {
+				t.Errorf("span is missing %s: %v", tt.expectedAttr, span.Attributes())
+			}
+			if tt.fail && span.Status().Code != codes.Error {
+				t.Errorf("expected error status, got %v", span.Status())
+			}
+		})
+	}
+}
+
+func TestErrorType(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{err: context.Canceled, expected: "cancelled"},
+		{err: context.DeadlineExceeded, expected: "timeout"},
+		{err: backend.StatusError{StatusCode: 404}, expected: "http_404"},
+		{err: backend.ErrNoUpstream, expected: "unavailable"},
+		{err: http.ErrHandlerTimeout, expected: "upstream"},
+	}
+	for _, tt := range tests {
+		if got := errorType(tt.err); got != tt.expected {
+			t.Errorf("errorType(%v) = %q, want %q", tt.err, got, tt.expected)
+		}
+	}
+}
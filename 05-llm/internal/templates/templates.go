@@ -0,0 +1,339 @@
+// Package templates is a library of named, versioned prompt templates
+// written in text/template syntax.
+//
+// Templates are stored in a directory, one subdirectory per template with
+// a file per version: <dir>/<name>/<version>.tmpl. The active version is
+// recorded in <dir>/<name>/active; without it the latest version is active.
+// A template may define a "system" block which becomes the system prompt;
+// the rest of the template is the user prompt.
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+const (
+	fileExt    = ".tmpl"
+	activeFile = "active"
+	systemName = "system"
+)
+
+var (
+	ErrNotFound = errors.New("template not found")
+	// ErrInvalid is returned when a template, its name or the requested
+	// change is rejected, as opposed to failures of the templates directory.
+	ErrInvalid = errors.New("invalid template")
+	// ErrRender is returned when the template can't be rendered with the given variables.
+	ErrRender = errors.New("failed to render template")
+)
+
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// Info describes a template and its versions.
+type Info struct {
+	Name     string `json:"name"`
+	Versions []int  `json:"versions"`
+	Active   int    `json:"active"`
+}
+
+// Prompt is a rendered template.
+type Prompt struct {
+	Name    string
+	Version int
+	System  string
+	User    string
+}
+
+type version struct {
+	text string
+	tmpl *template.Template
+}
+
+type entry struct {
+	versions map[int]version
+	active   int
+}
+
+func (e *entry) info(name string) Info {
+	versions := make([]int, 0, len(e.versions))
+	for v := range e.versions {
+		versions = append(versions, v)
+	}
+	slices.Sort(versions)
+	return Info{Name: name, Versions: versions, Active: e.active}
+}
+
+// Library holds the templates loaded from a directory.
+type Library struct {
+	mu        sync.RWMutex
+	dir       string
+	templates map[string]*entry
+}
+
+// Load reads all templates from dir, creating the directory if it doesn't exist.
+func Load(dir string) (*Library, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create templates directory: %w", err)
+	}
+
+	dirs, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read templates directory: %w", err)
+	}
+
+	lib := &Library{dir: dir, templates: make(map[string]*entry)}
+	for _, d := range dirs {
+		if !d.IsDir() || !namePattern.MatchString(d.Name()) {
+			continue
+		}
+		e, err := loadEntry(filepath.Join(dir, d.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %w", d.Name(), err)
+		}
+		if len(e.versions) > 0 {
+			lib.templates[d.Name()] = e
+		}
+	}
+
+	return lib, nil
+}
+
+func loadEntry(dir string) (*entry, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &entry{versions: make(map[int]version)}
+	for _, f := range files {
+		n, ok := strings.CutSuffix(f.Name(), fileExt)
+		if !ok {
+			continue
+		}
+		v, err := strconv.Atoi(n)
+		if err != nil || v <= 0 {
+			continue
+		}
+
+		b, err := os.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err := parse(string(b))
+		if err != nil {
+			return nil, fmt.Errorf("version %d: %w", v, err)
+		}
+		e.versions[v] = version{text: string(b), tmpl: tmpl}
+		e.active = max(e.active, v)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, activeFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return e, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	active, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid active version: %w", err)
+	}
+	if _, ok := e.versions[active]; !ok {
+		return nil, fmt.Errorf("active version %d does not exist", active)
+	}
+	e.active = active
+	return e, nil
+}
+
+func parse(text string) (*template.Template, error) {
+	return template.New("").Option("missingkey=error").Parse(text)
+}
+
+// List returns all templates sorted by name.
+func (l *Library) List() []Info {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	infos := make([]Info, 0, len(l.templates))
+	for name, e := range l.templates {
+		infos = append(infos, e.info(name))
+	}
+	slices.SortFunc(infos, func(a, b Info) int { return strings.Compare(a.Name, b.Name) })
+	return infos
+}
+
+func (l *Library) Get(name string) (Info, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	e, ok := l.templates[name]
+	if !ok {
+		return Info{}, ErrNotFound
+	}
+	return e.info(name), nil
+}
+
+// Text returns the source of the given version; version 0 means the active one.
+func (l *Library) Text(name string, v int) (string, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	ver, _, err := l.version(name, v)
+	if err != nil {
+		return "", err
+	}
+	return ver.text, nil
+}
+
+// Add stores text as a new version of the template and makes it active.
+func (l *Library) Add(name, text string) (Info, error) {
+	if !namePattern.MatchString(name) {
+		return Info{}, fmt.Errorf("%w name %q", ErrInvalid, name)
+	}
+	tmpl, err := parse(text)
+	if err != nil {
+		return Info{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.templates[name]
+	if !ok {
+		e = &entry{versions: make(map[int]version)}
+	}
+	v := 1
+	for existing := range e.versions {
+		v = max(v, existing+1)
+	}
+
+	dir := filepath.Join(l.dir, name)
+	err = os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return Info{}, err
+	}
+	err = writeFile(filepath.Join(dir, strconv.Itoa(v)+fileExt), text)
+	if err != nil {
+		return Info{}, err
+	}
+	err = writeFile(filepath.Join(dir, activeFile), strconv.Itoa(v))
+	if err != nil {
+		return Info{}, err
+	}
+
+	e.versions[v] = version{text: text, tmpl: tmpl}
+	e.active = v
+	l.templates[name] = e
+	return e.info(name), nil
+}
+
+// Rollback activates the given version; version 0 means the one preceding the active version.
+func (l *Library) Rollback(name string, v int) (Info, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.templates[name]
+	if !ok {
+		return Info{}, ErrNotFound
+	}
+
+	if v == 0 {
+		for existing := range e.versions {
+			if existing < e.active {
+				v = max(v, existing)
+			}
+		}
+		if v == 0 {
+			return Info{}, fmt.Errorf("%w: %s has no version before %d", ErrInvalid, name, e.active)
+		}
+	}
+	if _, ok = e.versions[v]; !ok {
+		return Info{}, fmt.Errorf("%w: %s version %d", ErrNotFound, name, v)
+	}
+
+	err := writeFile(filepath.Join(l.dir, name, activeFile), strconv.Itoa(v))
+	if err != nil {
+		return Info{}, err
+	}
+	e.active = v
+	return e.info(name), nil
+}
+
+// Render executes the given version of the template with vars; version 0
+// means the active one. Referencing a variable missing in vars is an error.
+func (l *Library) Render(name string, v int, vars map[string]any) (Prompt, error) {
+	l.mu.RLock()
+	ver, v, err := l.version(name, v)
+	l.mu.RUnlock()
+	if err != nil {
+		return Prompt{}, err
+	}
+	if vars == nil {
+		vars = map[string]any{}
+	}
+
+	p := Prompt{Name: name, Version: v}
+	var sb strings.Builder
+	err = ver.tmpl.Execute(&sb, vars)
+	if err != nil {
+		return Prompt{}, fmt.Errorf("%w: %v", ErrRender, err)
+	}
+	p.User = strings.TrimSpace(sb.String())
+
+	if system := ver.tmpl.Lookup(systemName); system != nil {
+		sb.Reset()
+		err = system.Execute(&sb, vars)
+		if err != nil {
+			return Prompt{}, fmt.Errorf("%w: %v", ErrRender, err)
+		}
+		p.System = strings.TrimSpace(sb.String())
+	}
+
+	return p, nil
+}
+
+// version must be called with l.mu held.
+func (l *Library) version(name string, v int) (version, int, error) {
+	e, ok := l.templates[name]
+	if !ok {
+		return version{}, 0, ErrNotFound
+	}
+	if v == 0 {
+		v = e.active
+	}
+	ver, ok := e.versions[v]
+	if !ok {
+		return version{}, 0, fmt.Errorf("%w: %s version %d", ErrNotFound, name, v)
+	}
+	return ver, v, nil
+}
+
+// writeFile replaces the file atomically.
+func writeFile(path, content string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.WriteString(content)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
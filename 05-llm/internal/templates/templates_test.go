@@ -0,0 +1,112 @@
+package templates
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const summarize = `{{define "system"}}You are a {{.tone}} editor.{{end}}
+Summarize in {{.words}} words:
+{{.text}}`
+
+func TestRender(t *testing.T) {
+	lib, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = lib.Add("summarize", summarize); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		template   string
+		vars       map[string]any
+		wantSystem string
+		wantUser   string
+		wantErr    error
+	}{
+		{
+			name:       "All variables",
+			template:   "summarize",
+			vars:       map[string]any{"tone": "strict", "words": 10, "text": "Go is fun."},
+			wantSystem: "You are a strict editor.",
+			wantUser:   "Summarize in 10 words:\nGo is fun.",
+		},
+		{
+			name:     "Missing variable",
+			template: "summarize",
+			vars:     map[string]any{"tone": "strict", "text": "Go is fun."},
+			wantErr:  ErrRender,
+		},
+		{
+			name:     "Unknown template",
+			template: "translate",
+			wantErr:  ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := lib.Render(tt.template, 0, tt.vars)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Render() error = %v, want %v", err, tt.wantErr)
+			}
+			if p.System != tt.wantSystem || p.User != tt.wantUser {
+				t.Errorf("Render() = %+v, want system %q and user %q", p, tt.wantSystem, tt.wantUser)
+			}
+		})
+	}
+}
+
+func TestVersioning(t *testing.T) {
+	dir := t.TempDir()
+	lib, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, text := range []string{"v1 {{.x}}", "v2 {{.x}}", "v3 {{.x}}"} {
+		if _, err = lib.Add("greet", text); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = lib.Add("greet", "{{.x"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected ErrInvalid for unparsable template, got %v", err)
+	}
+
+	info, err := lib.Rollback("greet", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Active != 2 || len(info.Versions) != 3 {
+		t.Errorf("unexpected info after rollback %+v", info)
+	}
+
+	lib, err = Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := lib.Render("greet", 0, map[string]any{"x": "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.User != "v2 hi" || p.Version != 2 {
+		t.Errorf("rollback should survive reload, got %+v", p)
+	}
+
+	if p, _ = lib.Render("greet", 3, map[string]any{"x": "hi"}); p.User != "v3 hi" {
+		t.Errorf("explicit version should be rendered, got %+v", p)
+	}
+	if _, err = lib.Rollback("greet", 7); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for unknown version, got %v", err)
+	}
+
+	if err = os.WriteFile(filepath.Join(dir, "greet", "4.tmpl"), []byte("{{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Load(dir); err == nil {
+		t.Error("expected error loading invalid template from disk")
+	}
+}
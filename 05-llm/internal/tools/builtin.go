@@ -0,0 +1,121 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const maxFetchSize = 64 << 10
+
+// CurrentTime reports the current time, optionally in the given IANA time zone.
+func CurrentTime(now func() time.Time) Tool {
+	return Tool{
+		Name:        "current_time",
+		Description: "Returns the current date and time in RFC 3339 format.",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"timezone": {"type": "string", "description": "IANA time zone, e.g. Europe/Moscow. Defaults to UTC."}
+			}
+		}`),
+		Handler: func(ctx context.Context, raw json.RawMessage) (string, error) {
+			var args struct {
+				Timezone string `json:"timezone"`
+			}
+			err := json.Unmarshal(raw, &args)
+			if err != nil {
+				return "", err
+			}
+
+			loc := time.UTC
+			if args.Timezone != "" {
+				loc, err = time.LoadLocation(args.Timezone)
+				if err != nil {
+					return "", fmt.Errorf("unknown time zone %q", args.Timezone)
+				}
+			}
+			return now().In(loc).Format(time.RFC3339), nil
+		},
+	}
+}
+
+// HTTPGet fetches a URL whose host is in the allowlist. Redirects are
+// followed only to allowed hosts and the body is truncated to 64KB.
+func HTTPGet(client *http.Client, allowlist []string) Tool {
+	hosts := make([]string, len(allowlist))
+	for i, host := range allowlist {
+		hosts[i] = strings.ToLower(host)
+	}
+
+	allowed := func(u *url.URL) error {
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("scheme %q is not allowed", u.Scheme)
+		}
+		if !slices.Contains(hosts, strings.ToLower(u.Hostname())) {
+			return fmt.Errorf("host %q is not allowed", u.Hostname())
+		}
+		return nil
+	}
+
+	c := *client
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 5 {
+			return errors.New("too many redirects")
+		}
+		return allowed(req.URL)
+	}
+
+	return Tool{
+		Name:        "http_get",
+		Description: "Fetches a web page by URL and returns the HTTP status and body. Only some hosts are allowed: " + strings.Join(hosts, ", "),
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"url": {"type": "string", "description": "Absolute http or https URL."}
+			},
+			"required": ["url"]
+		}`),
+		Handler: func(ctx context.Context, raw json.RawMessage) (string, error) {
+			var args struct {
+				URL string `json:"url"`
+			}
+			err := json.Unmarshal(raw, &args)
+			if err != nil {
+				return "", err
+			}
+
+			u, err := url.Parse(args.URL)
+			if err != nil {
+				return "", err
+			}
+			err = allowed(u)
+			if err != nil {
+				return "", err
+			}
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+			if err != nil {
+				return "", err
+			}
+			resp, err := c.Do(req)
+			if err != nil {
+				return "", err
+			}
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchSize))
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("status: %d\n\n%s", resp.StatusCode, body), nil
+		},
+	}
+}
@@ -0,0 +1,229 @@
+// Package tools runs the tool calling loop: the model gets definitions of
+// server-side tools, the proxy executes the calls it returns and feeds the
+// results back until the model produces a final answer.
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/structured"
+)
+
+var (
+	ErrUnknownTool = errors.New("unknown tool")
+	// ErrMaxIterations is returned when the model keeps calling tools after the iteration limit.
+	ErrMaxIterations = errors.New("tool calling did not finish within the iteration limit")
+)
+
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// Handler executes a tool with arguments matching its parameters schema.
+// The returned string is passed to the model as the tool result.
+type Handler func(ctx context.Context, args json.RawMessage) (string, error)
+
+type Tool struct {
+	Name        string
+	Description string
+	// Parameters is a JSON schema of the arguments object.
+	Parameters json.RawMessage
+	Handler    Handler
+}
+
+type registered struct {
+	Tool
+	schema *structured.Schema
+}
+
+// Registry holds tools available to the model.
+type Registry struct {
+	mu    sync.RWMutex
+	tools map[string]registered
+}
+
+func NewRegistry() *Registry {
+	return &Registry{tools: make(map[string]registered)}
+}
+
+// Register adds the tool, replacing a tool with the same name.
+func (r *Registry) Register(t Tool) error {
+	if !namePattern.MatchString(t.Name) {
+		return fmt.Errorf("invalid tool name %q", t.Name)
+	}
+	if t.Handler == nil {
+		return fmt.Errorf("tool %q has no handler", t.Name)
+	}
+	if len(t.Parameters) == 0 {
+		t.Parameters = json.RawMessage(`{"type":"object","properties":{}}`)
+	}
+	schema, err := structured.Compile(t.Parameters)
+	if err != nil {
+		return fmt.Errorf("tool %q: %w", t.Name, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.tools[t.Name] = registered{Tool: t, schema: schema}
+	return nil
+}
+
+// Names returns sorted names of the registered tools.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.tools))
+	for name := range r.tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Definitions returns tool definitions for the model. Without names all tools are returned.
+func (r *Registry) Definitions(names ...string) ([]backend.Tool, error) {
+	if len(names) == 0 {
+		names = r.Names()
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	defs := make([]backend.Tool, 0, len(names))
+	for _, name := range names {
+		t, ok := r.tools[name]
+		if !ok {
+			return nil, fmt.Errorf("%w %q", ErrUnknownTool, name)
+		}
+		defs = append(defs, backend.Tool{
+			Type: "function",
+			Function: backend.ToolFunction{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  t.Parameters,
+			},
+		})
+	}
+	return defs, nil
+}
+
+// Call validates the arguments and executes the tool.
+func (r *Registry) Call(ctx context.Context, call backend.ToolCall) (string, error) {
+	r.mu.RLock()
+	t, ok := r.tools[call.Function.Name]
+	r.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownTool, call.Function.Name)
+	}
+
+	args := call.Function.Arguments
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	_, problems := t.schema.Validate(string(args))
+	if problems != nil {
+		msgs := make([]string, len(problems))
+		for i, p := range problems {
+			msgs[i] = strings.TrimSpace(p.Path + " " + p.Message)
+		}
+		return "", fmt.Errorf("invalid arguments: %s", strings.Join(msgs, "; "))
+	}
+
+	return t.Handler(ctx, args)
+}
+
+// Call is a tool call executed during the loop.
+type Call struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+	Result    string          `json:"result,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+type Result struct {
+	Message    backend.Message
+	Model      string
+	Calls      []Call
+	Iterations int
+	Usage      backend.Usage
+}
+
+// Runner runs the tool calling loop against the backend.
+type Runner struct {
+	backend       backend.Backend
+	registry      *Registry
+	maxIterations int
+}
+
+func NewRunner(b backend.Backend, registry *Registry, maxIterations int) *Runner {
+	return &Runner{backend: b, registry: registry, maxIterations: max(maxIterations, 1)}
+}
+
+func (r *Runner) Registry() *Registry {
+	return r.registry
+}
+
+// Run sends req with the tools to the model and executes requested calls until
+// the model answers without calling tools. Failed calls are reported to the
+// model as results, so it can correct itself. On ErrMaxIterations the partial
+// result lists the calls made.
+func (r *Runner) Run(ctx context.Context, req backend.ChatRequest, names ...string) (Result, error) {
+	defs, err := r.registry.Definitions(names...)
+	if err != nil {
+		return Result{}, err
+	}
+	req.Tools = defs
+	req.Messages = slices.Clone(req.Messages)
+
+	var result Result
+	for result.Iterations < r.maxIterations {
+		result.Iterations++
+
+		resp, err := r.backend.Chat(ctx, req)
+		if err != nil {
+			return Result{}, err
+		}
+		result.Model = resp.Model
+		result.Usage.PromptTokens += resp.Usage.PromptTokens
+		result.Usage.CompletionTokens += resp.Usage.CompletionTokens
+
+		message := backend.Message{Role: "assistant", Content: resp.Content, ToolCalls: resp.ToolCalls}
+		if len(resp.ToolCalls) == 0 {
+			result.Message = message
+			return result, nil
+		}
+
+		req.Messages = append(req.Messages, message)
+		for _, tc := range resp.ToolCalls {
+			call := Call{Name: tc.Function.Name, Arguments: tc.Function.Arguments}
+			out, err := r.registry.Call(ctx, tc)
+			if err != nil {
+				if ctx.Err() != nil {
+					return Result{}, ctx.Err()
+				}
+				call.Error = err.Error()
+				out = "error: " + err.Error()
+			} else {
+				call.Result = out
+			}
+			result.Calls = append(result.Calls, call)
+			req.Messages = append(req.Messages, backend.Message{
+				Role:       "tool",
+				Content:    out,
+				ToolName:   tc.Function.Name,
+				ToolCallID: tc.ID,
+			})
+		}
+	}
+
+	return result, ErrMaxIterations
+}
@@ -0,0 +1,152 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+)
+
+// scripted answers with the next response on every call and records the requests.
+type scripted struct {
+	*backend.Fake
+	responses []backend.Response
+	requests  []backend.ChatRequest
+}
+
+func (s *scripted) Chat(ctx context.Context, req backend.ChatRequest) (backend.Response, error) {
+	s.requests = append(s.requests, req)
+	resp := s.responses[0]
+	if len(s.responses) > 1 {
+		s.responses = s.responses[1:]
+	}
+	return resp, nil
+}
+
+func toolCall(name, args string) backend.Response {
+	return backend.Response{ToolCalls: []backend.ToolCall{{
+		Function: backend.ToolCallFunction{Name: name, Arguments: json.RawMessage(args)},
+	}}}
+}
+
+func newTestRegistry(t *testing.T) *Registry {
+	t.Helper()
+
+	now := func() time.Time { return time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC) }
+	r := NewRegistry()
+	if err := r.Register(CurrentTime(now)); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRunnerLoop(t *testing.T) {
+	b := &scripted{responses: []backend.Response{
+		toolCall("current_time", `{"timezone":"Mars/Olympus"}`),
+		toolCall("current_time", `{"timezone":"Europe/Moscow"}`),
+		{Content: "It is 15:00 in Moscow."},
+	}}
+	runner := NewRunner(b, newTestRegistry(t), 5)
+
+	req := backend.ChatRequest{Model: "llama3.2", Messages: []backend.Message{{Role: "user", Content: "What time is it in Moscow?"}}}
+	result, err := runner.Run(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Iterations != 3 || result.Message.Content != "It is 15:00 in Moscow." {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if len(result.Calls) != 2 || result.Calls[0].Error == "" || result.Calls[1].Result != "2025-03-01T15:00:00+03:00" {
+		t.Errorf("unexpected calls %+v", result.Calls)
+	}
+	if len(b.requests[0].Tools) != 1 || b.requests[0].Tools[0].Function.Name != "current_time" {
+		t.Errorf("tool definitions should be sent, got %+v", b.requests[0].Tools)
+	}
+
+	last := b.requests[2].Messages
+	if msg := last[len(last)-1]; msg.Role != "tool" || msg.ToolName != "current_time" {
+		t.Errorf("tool result should be fed back, got %+v", msg)
+	}
+	if len(req.Messages) != 1 {
+		t.Errorf("request messages must not be modified")
+	}
+}
+
+func TestRunnerErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		tools   []string
+		wantErr error
+	}{
+		{name: "Unknown tool", tools: []string{"rm_rf"}, wantErr: ErrUnknownTool},
+		{name: "Iteration limit", wantErr: ErrMaxIterations},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &scripted{responses: []backend.Response{toolCall("current_time", `{}`)}}
+			result, err := NewRunner(b, newTestRegistry(t), 3).Run(context.Background(), backend.ChatRequest{}, tt.tools...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Run() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == ErrMaxIterations && len(result.Calls) != 3 {
+				t.Errorf("expected 3 calls before giving up, got %d", len(result.Calls))
+			}
+		})
+	}
+}
+
+func TestHTTPGet(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "http://example.com/", http.StatusFound)
+			return
+		}
+		w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	r := NewRegistry()
+	if err := r.Register(HTTPGet(srv.Client(), []string{u.Hostname()})); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		args    string
+		want    string
+		wantErr string
+	}{
+		{name: "Allowed host", args: `{"url":"` + srv.URL + `/"}`, want: "status: 200\n\nhello"},
+		{name: "Host not allowed", args: `{"url":"http://example.com/"}`, wantErr: "not allowed"},
+		{name: "Redirect to other host", args: `{"url":"` + srv.URL + `/redirect"}`, wantErr: "not allowed"},
+		{name: "Missing url", args: `{}`, wantErr: "invalid arguments"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.Call(context.Background(), backend.ToolCall{
+				Function: backend.ToolCallFunction{Name: "http_get", Arguments: json.RawMessage(tt.args)},
+			})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Call() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Call() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
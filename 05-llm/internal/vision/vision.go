@@ -0,0 +1,138 @@
+// Package vision validates images sent to vision models and shrinks them
+// before they are base64 encoded into the llm request.
+package vision
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"slices"
+
+	// Registered for image.Decode.
+	_ "image/gif"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	DefaultMaxBytes = 10 << 20
+	// maxPixels bounds the decoded size so that a small file cannot expand
+	// into a huge bitmap.
+	maxPixels   = 50_000_000
+	jpegQuality = 90
+)
+
+var (
+	ErrTooLarge    = errors.New("image is too large")
+	ErrUnsupported = errors.New("unsupported image type")
+	ErrInvalid     = errors.New("invalid image")
+)
+
+// Types lists the accepted MIME types. GIF and WebP images are converted,
+// as not every model accepts them.
+var Types = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+
+type Config struct {
+	// MaxBytes limits the size of an uploaded image; zero means DefaultMaxBytes.
+	MaxBytes int64
+	// MaxDimension is the largest width or height passed to the model.
+	// Larger images are downscaled keeping the aspect ratio; zero disables it.
+	MaxDimension int
+}
+
+// Image is an image ready to be sent to the model.
+type Image struct {
+	Data          []byte
+	MIME          string
+	Width, Height int
+	// Resized is set when the image was downscaled.
+	Resized bool
+}
+
+// Base64 returns the encoding used in llm requests.
+func (img Image) Base64() string {
+	return base64.StdEncoding.EncodeToString(img.Data)
+}
+
+// Limit returns the maximum size of an image in bytes.
+func (c Config) Limit() int64 {
+	if c.MaxBytes <= 0 {
+		return DefaultMaxBytes
+	}
+	return c.MaxBytes
+}
+
+// Prepare validates the image by its content rather than the declared type
+// and downscales it if it exceeds the maximum dimension. JPEG and PNG
+// images within the limits are passed as is.
+func (c Config) Prepare(data []byte) (Image, error) {
+	if int64(len(data)) > c.Limit() {
+		return Image{}, fmt.Errorf("%w: %d bytes, the limit is %d", ErrTooLarge, len(data), c.Limit())
+	}
+
+	mime := http.DetectContentType(data)
+	if !slices.Contains(Types, mime) {
+		return Image{}, fmt.Errorf("%w %s", ErrUnsupported, mime)
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return Image{}, fmt.Errorf("%w: %dx%d pixels", ErrTooLarge, cfg.Width, cfg.Height)
+	}
+
+	img := Image{Data: data, MIME: mime, Width: cfg.Width, Height: cfg.Height}
+	resize := c.MaxDimension > 0 && max(cfg.Width, cfg.Height) > c.MaxDimension
+	if !resize && (mime == "image/jpeg" || mime == "image/png") {
+		return img, nil
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if resize {
+		src = downscale(src, c.MaxDimension)
+		img.Resized = true
+	}
+	bounds := src.Bounds()
+	img.Width, img.Height = bounds.Dx(), bounds.Dy()
+
+	// PNG keeps transparency and sharp edges of screenshots and GIFs.
+	var buf bytes.Buffer
+	if mime == "image/jpeg" || mime == "image/webp" {
+		img.MIME = "image/jpeg"
+		err = jpeg.Encode(&buf, src, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		img.MIME = "image/png"
+		err = png.Encode(&buf, src)
+	}
+	if err != nil {
+		return Image{}, fmt.Errorf("failed to encode image: %w", err)
+	}
+	img.Data = buf.Bytes()
+	return img, nil
+}
+
+// downscale fits the image into a square of the given size.
+func downscale(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	w, h := size, size
+	if b.Dx() > b.Dy() {
+		h = max(1, b.Dy()*size/b.Dx())
+	} else {
+		w = max(1, b.Dx()*size/b.Dy())
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Src, nil)
+	return dst
+}
@@ -0,0 +1,122 @@
+package vision
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encode(t *testing.T, format string, w, h int) []byte {
+	t.Helper()
+
+	img := image.NewPaletted(image.Rect(0, 0, w, h), color.Palette{color.White, color.Black})
+	for x := range w {
+		img.SetColorIndex(x, x%h, 1)
+	}
+
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestPrepare(t *testing.T) {
+	small := encode(t, "png", 40, 20)
+
+	tests := []struct {
+		name           string
+		config         Config
+		data           []byte
+		expectedErr    error
+		expectedMIME   string
+		expectedWidth  int
+		expectedHeight int
+		expectedSame   bool
+	}{
+		{
+			name:           "PNG as is",
+			data:           small,
+			expectedMIME:   "image/png",
+			expectedWidth:  40,
+			expectedHeight: 20,
+			expectedSame:   true,
+		},
+		{
+			name:           "JPEG downscaled",
+			config:         Config{MaxDimension: 50},
+			data:           encode(t, "jpeg", 100, 200),
+			expectedMIME:   "image/jpeg",
+			expectedWidth:  25,
+			expectedHeight: 50,
+		},
+		{
+			name:           "Within the dimension",
+			config:         Config{MaxDimension: 40},
+			data:           small,
+			expectedMIME:   "image/png",
+			expectedWidth:  40,
+			expectedHeight: 20,
+			expectedSame:   true,
+		},
+		{
+			name:           "GIF converted",
+			data:           encode(t, "gif", 30, 30),
+			expectedMIME:   "image/png",
+			expectedWidth:  30,
+			expectedHeight: 30,
+		},
+		{
+			name:        "Too many bytes",
+			config:      Config{MaxBytes: 10},
+			data:        small,
+			expectedErr: ErrTooLarge,
+		},
+		{
+			name:        "Not an image",
+			data:        []byte("%PDF-1.7 hello"),
+			expectedErr: ErrUnsupported,
+		},
+		{
+			name:        "Truncated",
+			data:        small[:20],
+			expectedErr: ErrInvalid,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := tt.config.Prepare(tt.data)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tt.expectedErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			if img.MIME != tt.expectedMIME || img.Width != tt.expectedWidth || img.Height != tt.expectedHeight {
+				t.Errorf("unexpected image %s %dx%d", img.MIME, img.Width, img.Height)
+			}
+			if bytes.Equal(img.Data, tt.data) != tt.expectedSame {
+				t.Errorf("data unchanged = %v, want %v", !tt.expectedSame, tt.expectedSame)
+			}
+			decoded, _, err := image.DecodeConfig(bytes.NewReader(img.Data))
+			if err != nil || decoded.Width != img.Width || decoded.Height != img.Height {
+				t.Errorf("data does not decode to %dx%d: %+v %v", img.Width, img.Height, decoded, err)
+			}
+		})
+	}
+}
@@ -1,102 +1,252 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
-	"net/url"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/audit"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/auth"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/config"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/guard"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/telemetry"
 )
 
-const llmModel = "llama3.2"
+const (
+	defaultEmbedModel  = "nomic-embed-text"
+	defaultServiceName = "llm-proxy"
+)
 
-type ollamaRequest struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
-	Stream bool   `json:"stream"`
+// envInt returns the integer value of the environment variable or def if it is not set.
+func envInt(name string, def int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", name, err)
+	}
+	return n
 }
 
-type ollamaResponse struct {
-	Response string `json:"response"`
+// envDuration returns the duration value of the environment variable or def if it is not set.
+func envDuration(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", name, err)
+	}
+	return d
 }
 
 func main() {
-	targetURL := os.Getenv("TARGET_SERVER")
-	if targetURL == "" {
-		targetURL = "http://localhost:11434"
-		log.Printf("TARGET_SERVER is not set, using default %s", targetURL)
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1], os.Args[2:])
+		return
 	}
 
-	target, err := url.Parse(targetURL)
-	if err != nil {
-		log.Fatalf("Failed to parse target URL: %v", err)
+	shutdownTelemetry := func(context.Context) error { return nil }
+	if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint != "" {
+		serviceName := os.Getenv("OTEL_SERVICE_NAME")
+		if serviceName == "" {
+			serviceName = defaultServiceName
+		}
+		var err error
+		shutdownTelemetry, err = telemetry.SetupOTelSDK(context.Background(), endpoint, serviceName)
+		if err != nil {
+			log.Fatalf("Failed to set up OpenTelemetry: %v", err)
+		}
 	}
-	target.Path = "/api/generate"
 
-	client := &http.Client{Timeout: 30 * time.Second}
-
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("Received request: %s %s", r.Method, r.URL.Path)
+	cfgPath := os.Getenv("CONFIG_FILE")
+	cfg, err := loadConfig(cfgPath)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	svc, err := newService(cfg)
+	if err != nil {
+		log.Fatalf("Failed to start: %v", err)
+	}
 
-		prompt := r.URL.Query().Get("q")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// A second signal kills the process without waiting.
+		<-ctx.Done()
+		stop()
+	}()
+	go watchConfig(ctx, svc, cfgPath)
+
+	log.Printf("Starting server on :%s, forwarding to %s backend %s", cfg.Port, cfg.Backend.Kind, strings.Join(cfg.Backend.URLs, ","))
+
+	srv := &http.Server{Addr: ":" + cfg.Port, Handler: telemetry.TracingMiddleware(svc)}
+	err = serve(ctx, srv, svc)
+	svc.Close()
+	shutdownTelemetry(context.Background())
+	if err != nil {
+		log.Fatalf("Server failed: %v", err)
+	}
+	log.Print("Server stopped")
+}
 
-		if prompt == "" {
-			http.Error(w, "Prompt is empty", http.StatusBadRequest)
-			return
-		}
+// serve runs the server until ctx is done, then stops accepting connections
+// and waits for the in-flight requests, streamed replies and WebSocket
+// generations included, up to the shutdown timeout. The connections still
+// busy after it are closed.
+func serve(ctx context.Context, srv *http.Server, svc *service) error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
 
-		requestObject := ollamaRequest{
-			Model:  llmModel,
-			Prompt: prompt,
-			Stream: false,
-		}
-		requestBody, err := json.Marshal(requestObject)
+	timeout := svc.shutdownTimeout()
+	log.Printf("Shutting down, waiting up to %v for in-flight requests", timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// WebSocket connections are hijacked, so srv.Shutdown does not wait for them.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		err := svc.conns.Shutdown(ctx)
 		if err != nil {
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			log.Printf("Error marshalling request: %v", err)
-			return
+			log.Printf("Closed WebSocket connections still generating: %v", err)
 		}
+	}()
+	err := srv.Shutdown(ctx)
+	wg.Wait()
+	if err != nil {
+		log.Printf("Closing connections with requests still in flight: %v", err)
+		srv.Close()
+	}
+	return nil
+}
 
-		req, err := http.NewRequest(http.MethodPost, target.String(), bytes.NewBuffer(requestBody))
-		if err != nil {
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			log.Printf("Error creating request: %v", err)
-			return
-		}
+// watchConfig reloads the configuration on SIGHUP and, when CONFIG_FILE is
+// set, on changes of the file until ctx is done.
+func watchConfig(ctx context.Context, svc *service, path string) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
 
-		resp, err := client.Do(req)
-		if err != nil {
-			http.Error(w, "Error requesting llm server", http.StatusBadGateway)
-			log.Printf("Error requesting llm server: %v", err)
+	var changes <-chan struct{}
+	if path != "" {
+		changes = config.Watch(ctx, path, envDuration("CONFIG_WATCH_INTERVAL", 2*time.Second))
+	}
+	for {
+		select {
+		case <-hup:
+			svc.reload(path, "SIGHUP")
+		case _, ok := <-changes:
+			if !ok {
+				return
+			}
+			svc.reload(path, "change of "+path)
+		case <-ctx.Done():
 			return
 		}
+	}
+}
 
-		defer resp.Body.Close()
-
-		decoder := json.NewDecoder(resp.Body)
-		var resultBody ollamaResponse
-		err = decoder.Decode(&resultBody)
-		if err != nil {
-			http.Error(w, "Error parsing llm response", http.StatusInternalServerError)
-			log.Printf("Error parsing llm response: %v", err)
-			return
+func runCommand(name string, args []string) {
+	switch name {
+	case "hash-key":
+		if len(args) != 1 {
+			log.Fatal("Usage: llm hash-key <api key>")
 		}
+		fmt.Println(auth.HashKey(args[0]))
+	case "replay":
+		replay(args)
+	default:
+		log.Fatalf("Unknown command %q", name)
+	}
+}
 
-		fmt.Fprint(w, resultBody.Response)
-	})
+// auditRedactor returns a redactor of personal data listed in AUDIT_REDACT, e.g. "email,phone,card".
+func auditRedactor() func(string) string {
+	kinds := config.SplitList(os.Getenv("AUDIT_REDACT"))
+	if len(kinds) == 0 {
+		return nil
+	}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	redactor, err := guard.NewRedactor(kinds...)
+	if err != nil {
+		log.Fatalf("Invalid AUDIT_REDACT: %v", err)
+	}
+	return func(s string) string {
+		out, _, _ := redactor.Apply(s)
+		return out
 	}
+}
 
-	log.Printf("Starting server on :%s, forwarding to %s", port, targetURL)
+// replay re-runs logged generations against the current backend and prints
+// the difference between the logged and the new output.
+func replay(args []string) {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	dir := flags.String("dir", os.Getenv("AUDIT_DIR"), "audit log directory")
+	flags.Parse(args)
+	if flags.NArg() != 1 || *dir == "" {
+		log.Fatal("Usage: llm replay [-dir audit dir] <record or request id>")
+	}
+
+	records, err := audit.Find(*dir, flags.Arg(0))
+	if err != nil {
+		log.Fatalf("Failed to find audit record: %v", err)
+	}
 
-	err = http.ListenAndServe(":"+port, nil)
+	cfg, err := loadConfig(os.Getenv("CONFIG_FILE"))
 	if err != nil {
-		log.Fatal()
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	llm, err := newBackend(cfg.Backend)
+	if err != nil {
+		log.Fatalf("Failed to create llm backend: %v", err)
+	}
+
+	for _, rec := range records {
+		fmt.Printf("Record %s (%s %s at %s)\n", rec.ID, rec.Operation, rec.Model, rec.Time.Format(time.RFC3339))
+		if rec.Redacted {
+			fmt.Println("Warning: the prompt was redacted in the log, the replayed prompt differs from the original")
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+		resp, err := llm.Chat(ctx, backend.ChatRequest{
+			Model:    rec.Model,
+			Messages: rec.Messages,
+			Options:  rec.Options,
+			Format:   rec.Format,
+		})
+		cancel()
+		if err != nil {
+			fmt.Printf("Replay failed: %v\n\n", err)
+			continue
+		}
+
+		if diff := audit.Diff(rec.Response, resp.Content); diff == "" {
+			fmt.Print("Output is identical\n\n")
+		} else {
+			fmt.Printf("Output differs:\n%s\n", diff)
+		}
 	}
 }
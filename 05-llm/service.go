@@ -0,0 +1,452 @@
+package main
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/api"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/audit"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/auth"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/backend"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/batch"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/cache"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/config"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/guard"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/models"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/queue"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/rag"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/session"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/telemetry"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/templates"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/tools"
+	"github.com/charlie-wasp/go-masters-2025/llm/internal/vision"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+)
+
+// service serves the proxy API. The parts built from config.Config are
+// replaced on reload, while in-flight requests finish on the generation they
+// started with. Sessions, the cache, queue counters and token quotas are
+// shared by all generations.
+type service struct {
+	limiter    *queue.Limiter
+	auth       *auth.Manager
+	guard      *guard.Guard
+	audit      *audit.Logger
+	sessions   *session.Store
+	index      *rag.Index
+	embedModel string
+	chunker    rag.Chunker
+	tools      *tools.Registry
+	batches    *batch.Manager
+	cache      *cache.Cache
+	conns      *api.Connections
+	// stopBatches cancels the batch jobs, which closes batchesDone once they stop.
+	stopBatches context.CancelFunc
+	batchesDone chan struct{}
+
+	current atomic.Pointer[generation]
+
+	// mu serialises reloads and guards the fields below.
+	mu     sync.Mutex
+	config config.Config
+	// base is the upstream backend, rebuilt only when the backend section changes.
+	base       backend.Backend
+	stopHealth context.CancelFunc
+	templates  *templates.Library
+	published  sync.Once
+}
+
+type generation struct {
+	handler http.Handler
+	llm     backend.Backend
+	pool    *backend.Pool
+	auth    *auth.Manager
+}
+
+// loadConfig reads the environment and the configuration file on top of it.
+func loadConfig(path string) (config.Config, error) {
+	base, err := config.FromEnv(os.Getenv)
+	if err != nil {
+		return config.Config{}, err
+	}
+	cfg, err := config.Load(path, base)
+	if err != nil {
+		return config.Config{}, err
+	}
+	return cfg, cfg.Validate()
+}
+
+// newService builds the shared parts, which are configured by the
+// environment only, and the first generation from cfg.
+func newService(cfg config.Config) (*service, error) {
+	s := &service{
+		limiter:    queue.NewLimiter(cfg.Limits.Queue.MaxInFlight, cfg.Limits.Queue.MaxWaiting, cfg.Limits.Queue.Timeout),
+		sessions:   session.NewStore(envDuration("SESSION_TTL", 24*time.Hour)),
+		embedModel: os.Getenv("EMBED_MODEL"),
+		chunker:    rag.Chunker{Size: envInt("RAG_CHUNK_SIZE", 200), Overlap: envInt("RAG_CHUNK_OVERLAP", 40)},
+		tools:      tools.NewRegistry(),
+		conns:      api.NewConnections(),
+	}
+	expvar.Publish("llm_queue", expvar.Func(func() any { return s.limiter.Stats() }))
+	if s.embedModel == "" {
+		s.embedModel = defaultEmbedModel
+	}
+	if err := s.chunker.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid RAG_CHUNK_SIZE or RAG_CHUNK_OVERLAP: %w", err)
+	}
+
+	if path := os.Getenv("GUARDRAILS_CONFIG"); path != "" {
+		guardConfig, err := guard.LoadConfig(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load guardrails config: %w", err)
+		}
+		s.guard, err = guard.New(guardConfig)
+		if err != nil {
+			return nil, fmt.Errorf("invalid guardrails config: %w", err)
+		}
+	}
+
+	if dir := os.Getenv("AUDIT_DIR"); dir != "" {
+		var err error
+		s.audit, err = audit.Open(audit.Config{
+			Dir:      dir,
+			MaxBytes: int64(envInt("AUDIT_MAX_BYTES", 100<<20)),
+			MaxFiles: envInt("AUDIT_MAX_FILES", 10),
+			Redact:   auditRedactor(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to open audit log: %w", err)
+		}
+	}
+
+	var err error
+	s.index, err = rag.Open(os.Getenv("RAG_INDEX_PATH"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open RAG index: %w", err)
+	}
+
+	err = s.tools.Register(tools.CurrentTime(time.Now))
+	if err != nil {
+		return nil, fmt.Errorf("failed to register tool: %w", err)
+	}
+	if hosts := config.SplitList(os.Getenv("TOOLS_HTTP_ALLOWLIST")); len(hosts) > 0 {
+		err = s.tools.Register(tools.HTTPGet(&http.Client{Timeout: 10 * time.Second}, hosts))
+		if err != nil {
+			return nil, fmt.Errorf("failed to register tool: %w", err)
+		}
+	}
+
+	if dir := os.Getenv("BATCH_DIR"); dir != "" {
+		// Jobs outlive reloads, so they run on the latest generation.
+		s.batches, err = batch.Open(currentBackend{s}, batch.Config{
+			Dir:         dir,
+			Concurrency: envInt("BATCH_CONCURRENCY", 2),
+			ItemTimeout: envDuration("BATCH_ITEM_TIMEOUT", 10*time.Minute),
+			Limiter:     currentAuth{s},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to open batch jobs: %w", err)
+		}
+	}
+
+	if ttl := envDuration("CACHE_TTL", 10*time.Minute); ttl > 0 {
+		s.cache = cache.New(ttl, envInt("CACHE_MAX_ENTRIES", 1000), envInt("CACHE_MAX_BYTES", 64<<20))
+	}
+
+	err = s.apply(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if s.batches != nil {
+		var ctx context.Context
+		ctx, s.stopBatches = context.WithCancel(context.Background())
+		s.batchesDone = make(chan struct{})
+		go func() {
+			defer close(s.batchesDone)
+			s.batches.Run(ctx)
+		}()
+	}
+	return s, nil
+}
+
+// Close stops the batch jobs and the health checks and closes the audit
+// log. It is called once the server has stopped serving requests.
+func (s *service) Close() {
+	if s.stopBatches != nil {
+		s.stopBatches()
+		<-s.batchesDone
+	}
+
+	s.mu.Lock()
+	if s.stopHealth != nil {
+		s.stopHealth()
+	}
+	s.mu.Unlock()
+
+	if s.audit != nil {
+		err := s.audit.Close()
+		if err != nil {
+			log.Printf("Error closing audit log: %v", err)
+		}
+	}
+}
+
+// shutdownTimeout is the current bound of the wait for in-flight requests.
+func (s *service) shutdownTimeout() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.config.Timeouts.Shutdown
+}
+
+func (s *service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.current.Load().handler.ServeHTTP(w, r)
+}
+
+// reload applies the configuration again, keeping the current one if the
+// new one is invalid.
+func (s *service) reload(path, reason string) {
+	cfg, err := loadConfig(path)
+	if err == nil {
+		err = s.apply(cfg)
+	}
+	if err != nil {
+		log.Printf("Failed to reload configuration on %s, keeping the previous one: %v", reason, err)
+		return
+	}
+	log.Printf("Reloaded configuration on %s", reason)
+}
+
+// apply builds a generation from cfg and makes it serve new requests.
+// Nothing changes if it fails.
+func (s *service) apply(cfg config.Config) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.config.Port != "" && cfg.Port != s.config.Port {
+		log.Printf("Port change to %s requires a restart, still listening on %s", cfg.Port, s.config.Port)
+		cfg.Port = s.config.Port
+	}
+
+	base, stopHealth := s.base, s.stopHealth
+	if base == nil || !reflect.DeepEqual(cfg.Backend, s.config.Backend) {
+		var err error
+		base, stopHealth, err = startBackend(cfg.Backend)
+		if err != nil {
+			return fmt.Errorf("failed to create llm backend: %w", err)
+		}
+	}
+	gen, lib, err := s.build(cfg, base)
+	if err != nil {
+		if base != s.base {
+			stopHealth()
+		}
+		return err
+	}
+
+	if gen.pool != nil {
+		s.published.Do(func() {
+			expvar.Publish("llm_upstreams", expvar.Func(func() any {
+				if pool := s.current.Load().pool; pool != nil {
+					return pool.Status()
+				}
+				return nil
+			}))
+		})
+	}
+	s.limiter.SetLimits(cfg.Limits.Queue.MaxInFlight, cfg.Limits.Queue.MaxWaiting, cfg.Limits.Queue.Timeout)
+	s.current.Store(gen)
+	if base != s.base && s.stopHealth != nil {
+		s.stopHealth()
+	}
+	s.config, s.base, s.stopHealth, s.templates = cfg, base, stopHealth, lib
+	return nil
+}
+
+// build wraps the base backend into the decorators and creates the API
+// handler. The templates are loaded again only when the directory changes,
+// as the library keeps the versions added through the API.
+func (s *service) build(cfg config.Config, base backend.Backend) (*generation, *templates.Library, error) {
+	lib := s.templates
+	if lib == nil || cfg.Templates.Dir != s.config.Templates.Dir {
+		lib = nil
+		if cfg.Templates.Dir != "" {
+			var err error
+			lib, err = templates.Load(cfg.Templates.Dir)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to load prompt templates: %w", err)
+			}
+		}
+	}
+
+	router, err := models.NewRouter(cfg.Models)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid models config: %w", err)
+	}
+	var summaryModel string
+	if cfg.Context.SummaryModel != "" {
+		summaryModel, err = router.Resolve(cfg.Context.SummaryModel, "", nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid summary model: %w", err)
+		}
+	}
+
+	// An existing auth manager is updated last, as it keeps the usage of
+	// clients and cannot be rolled back.
+	llm := base
+	if cfg.Limits.Queue.MaxInFlight > 0 {
+		llm = queue.NewBackend(llm, s.limiter)
+	}
+	var authManager *auth.Manager
+	if cfg.APIKeys != nil {
+		authManager = s.auth
+		if authManager == nil {
+			authManager, err = auth.NewManager(*cfg.APIKeys)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid API keys config: %w", err)
+			}
+		}
+		llm = auth.NewBackend(llm, authManager)
+	}
+	if s.guard != nil {
+		llm = guard.NewBackend(llm, s.guard)
+	}
+	if s.audit != nil {
+		llm = audit.NewBackend(llm, s.audit)
+	}
+	// Metrics and spans go to the global providers, which are no-ops unless the SDK is set up.
+	llm, err = telemetry.NewBackend(llm, otel.GetMeterProvider(), otel.GetTracerProvider())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create llm metrics: %w", err)
+	}
+	if authManager != nil && authManager == s.auth {
+		err = authManager.Update(*cfg.APIKeys)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid API keys config: %w", err)
+		}
+	}
+	s.auth = authManager
+
+	var lookup func(ctx context.Context, model string) (int, error)
+	if sizer, ok := base.(backend.ContextSizer); ok {
+		lookup = sizer.ContextSize
+	}
+	window := session.Window{
+		Budget:       cfg.Context.Budget,
+		ContextSize:  models.NewContextSizes(cfg.Models.ContextSizes, lookup).Get,
+		Strategy:     cfg.Context.Strategy,
+		SummaryModel: summaryModel,
+	}
+	if cfg.Context.Strategy == session.Summarize {
+		window.Summarize = session.BackendSummarizer(llm)
+	}
+
+	options := []func(*api.API){
+		api.WithRouter(router),
+		api.WithBackend(llm),
+		api.WithSessions(s.sessions, window),
+		api.WithEmbedModel(s.embedModel),
+		api.WithRetriever(rag.NewRetriever(s.index, llm, s.embedModel, s.chunker)),
+		api.WithTools(tools.NewRunner(llm, s.tools, cfg.Limits.ToolIterations)),
+		api.WithVision(vision.Config{
+			MaxBytes:     cfg.Limits.Images.MaxBytes,
+			MaxDimension: cfg.Limits.Images.MaxDimension,
+		}),
+		api.WithRequestTimeout(cfg.Timeouts.Request, cfg.Timeouts.MaxRequest),
+		api.WithConnections(s.conns),
+		api.WithStructuredRetries(cfg.Limits.StructuredRetries),
+	}
+	if authManager != nil {
+		options = append(options, api.WithAuth(authManager))
+	}
+	if s.guard != nil {
+		options = append(options, api.WithGuardrails())
+	}
+	if lib != nil {
+		options = append(options, api.WithTemplates(lib))
+	}
+	if s.batches != nil {
+		options = append(options, api.WithBatches(s.batches))
+	}
+	if s.cache != nil {
+		options = append(options, api.WithCache(s.cache))
+	}
+
+	pool, _ := base.(*backend.Pool)
+	return &generation{handler: api.New(options...), llm: llm, pool: pool, auth: authManager}, lib, nil
+}
+
+// newBackend creates the upstream llm backend.
+func newBackend(cfg config.Backend) (backend.Backend, error) {
+	// Upstream calls are bounded by the request context deadline rather than
+	// a client timeout, which would also cut long streaming responses.
+	client := &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)}
+
+	return backend.New(backend.Config{
+		Kind:          cfg.Kind,
+		URLs:          cfg.URLs,
+		APIKey:        cfg.APIKey,
+		Models:        cfg.Models,
+		ContextLength: cfg.ContextLength,
+	}, client)
+}
+
+// startBackend creates the upstream llm backend and starts the health
+// checks of a pool, which run until stop is called.
+func startBackend(cfg config.Backend) (b backend.Backend, stop context.CancelFunc, err error) {
+	b, err = newBackend(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	if pool, ok := b.(*backend.Pool); ok {
+		go pool.Run(ctx, cfg.HealthCheckInterval)
+	}
+	return b, stop, nil
+}
+
+// currentBackend is the llm backend of the latest generation.
+type currentBackend struct {
+	s *service
+}
+
+func (b currentBackend) Generate(ctx context.Context, req backend.GenerateRequest) (backend.Response, error) {
+	return b.s.current.Load().llm.Generate(ctx, req)
+}
+
+func (b currentBackend) Chat(ctx context.Context, req backend.ChatRequest) (backend.Response, error) {
+	return b.s.current.Load().llm.Chat(ctx, req)
+}
+
+func (b currentBackend) Stream(ctx context.Context, req backend.ChatRequest, fn func(backend.Chunk) error) (backend.Response, error) {
+	return b.s.current.Load().llm.Stream(ctx, req, fn)
+}
+
+func (b currentBackend) Embed(ctx context.Context, req backend.EmbedRequest) (backend.EmbedResponse, error) {
+	return b.s.current.Load().llm.Embed(ctx, req)
+}
+
+func (b currentBackend) ListModels(ctx context.Context) ([]backend.Model, error) {
+	return b.s.current.Load().llm.ListModels(ctx)
+}
+
+// currentAuth enforces the API key limits of the latest generation, if any.
+type currentAuth struct {
+	s *service
+}
+
+func (a currentAuth) Allow(client string) (auth.Remaining, error) {
+	manager := a.s.current.Load().auth
+	if manager == nil {
+		return auth.Remaining{Daily: -1, Monthly: -1}, nil
+	}
+	return manager.Allow(client)
+}
@@ -71,6 +71,9 @@ func (s *Stats) Print() {
 
 	fmt.Println("\n=== Load Test Results ===")
 	fmt.Printf("Total Requests: %d\n", s.TotalRequests)
+	if s.TotalRequests == 0 {
+		return
+	}
 	fmt.Printf("Successful: %d\n", s.SuccessCount)
 	fmt.Printf("Failed: %d\n", s.ErrorCount)
 	fmt.Printf("Average Duration: %v\n", s.TotalDuration/time.Duration(s.TotalRequests))
@@ -82,8 +85,43 @@ func (s *Stats) Print() {
 	}
 }
 
-func makeRequest(config RequestConfig) Result {
-	start := time.Now()
+// Schedule records how closely the rate mode kept to the intended send
+// times. Late requests were sent more than one interval behind schedule,
+// so the target rate was not offered.
+type Schedule struct {
+	Rate     int
+	Interval time.Duration
+	Sent     int
+	Late     int
+	MaxLag   time.Duration
+	Elapsed  time.Duration
+}
+
+func (s *Schedule) Record(lag time.Duration) {
+	s.Sent++
+	if lag > s.Interval {
+		s.Late++
+	}
+	if lag > s.MaxLag {
+		s.MaxLag = lag
+	}
+}
+
+func (s *Schedule) Print() {
+	fmt.Println("Schedule:")
+	fmt.Printf("  Target Rate: %d req/s\n", s.Rate)
+	if s.Sent > 1 && s.Elapsed > 0 {
+		fmt.Printf("  Achieved Rate: %.1f req/s\n", float64(s.Sent-1)/s.Elapsed.Seconds())
+	}
+	fmt.Printf("  Max Send Lag: %v\n", s.MaxLag)
+	if s.Late > 0 {
+		fmt.Printf("  WARNING: %d of %d requests were sent more than %v late, the load tester could not keep up with the schedule\n", s.Late, s.Sent, s.Interval)
+	}
+}
+
+// makeRequest sends the request and measures its duration from start, which
+// in the rate mode is the intended send time rather than the actual one.
+func makeRequest(config RequestConfig, start time.Time) Result {
 	var result Result
 
 	// Create request body if provided
@@ -131,7 +169,7 @@ func makeRequest(config RequestConfig) Result {
 func worker(id int, config RequestConfig, requests chan struct{}, results chan Result, wg *sync.WaitGroup) {
 	defer wg.Done()
 	for range requests {
-		results <- makeRequest(config)
+		results <- makeRequest(config, time.Now())
 	}
 }
 
@@ -166,11 +204,39 @@ func loadTest(config RequestConfig, concurrency int, totalRequests int) {
 	stats.Print()
 }
 
+// rateTest sends the requests on a fixed schedule regardless of how long the
+// responses take, so slow responses do not lower the offered load.
+func rateTest(config RequestConfig, rate int, totalRequests int) {
+	stats := NewStats()
+	schedule := &Schedule{Rate: rate, Interval: time.Second / time.Duration(rate)}
+	var wg sync.WaitGroup
+
+	start := time.Now()
+	for i := 0; i < totalRequests; i++ {
+		intended := start.Add(time.Duration(i) * schedule.Interval)
+		time.Sleep(time.Until(intended))
+		schedule.Record(time.Since(intended))
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stats.AddResult(makeRequest(config, intended))
+		}()
+	}
+	schedule.Elapsed = time.Since(start)
+
+	wg.Wait()
+	stats.Print()
+	schedule.Print()
+}
+
 func main() {
 	url := flag.String("url", "", "Target URL to test")
 	method := flag.String("method", "GET", "HTTP method")
 	concurrency := flag.Int("concurrency", 10, "Number of concurrent workers")
 	requests := flag.Int("requests", 100, "Total number of requests")
+	rate := flag.Int("rate", 0, "Requests per second sent on a fixed schedule instead of by the worker pool")
+	duration := flag.Duration("duration", 0, "Test duration with -rate, overrides -requests")
 	body := flag.String("body", "", "Request body (JSON)")
 	header := flag.String("header", "", "Custom headers (JSON)")
 	flag.Parse()
@@ -178,6 +244,15 @@ func main() {
 	if *url == "" {
 		log.Fatal("URL is required")
 	}
+	if *rate < 0 || *rate > int(time.Second) {
+		log.Fatalf("Rate must be between 0 and %d", int(time.Second))
+	}
+	if *rate > 0 && *duration > 0 {
+		*requests = int(duration.Seconds() * float64(*rate))
+	}
+	if *requests < 1 {
+		log.Fatal("At least one request is required, increase -requests or -rate and -duration")
+	}
 
 	config := RequestConfig{
 		URL:    *url,
@@ -201,7 +276,11 @@ func main() {
 	fmt.Printf("Starting load test:\n")
 	fmt.Printf("  URL: %s\n", config.URL)
 	fmt.Printf("  Method: %s\n", config.Method)
-	fmt.Printf("  Concurrency: %d\n", *concurrency)
+	if *rate > 0 {
+		fmt.Printf("  Rate: %d req/s\n", *rate)
+	} else {
+		fmt.Printf("  Concurrency: %d\n", *concurrency)
+	}
 	fmt.Printf("  Total Requests: %d\n", *requests)
 	if len(config.Headers) > 0 {
 		fmt.Println("  Headers:", config.Headers)
@@ -210,5 +289,9 @@ func main() {
 		fmt.Println("  Body:", config.Body)
 	}
 
-	loadTest(config, *concurrency, *requests)
+	if *rate > 0 {
+		rateTest(config, *rate, *requests)
+	} else {
+		loadTest(config, *concurrency, *requests)
+	}
 }